@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type ApiKeyController interface {
+	GetAll(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Revoke(ctx *gin.Context)
+}
+
+func NewApiKeyController(service services.ApiKeyService) ApiKeyController {
+	log.Info().Msg("Creating new api key controller")
+
+	return &apiKeyController{
+		service: service,
+	}
+}
+
+type apiKeyController struct {
+	service services.ApiKeyService
+}
+
+// @Summary Get API keys
+// @Description Get the API keys of the current user
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.ApiKey
+// @Router /users/current/api-keys [get]
+func (c *apiKeyController) GetAll(ctx *gin.Context) {
+	keys, err := c.service.FindAll(ctx.GetString("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// @Summary Create API key
+// @Description Create an API key, the key is only returned once
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param key body models.CreateApiKey true "API key"
+// @Success 201 {object} models.ApiKeyCredentials
+// @Router /users/current/api-keys [post]
+func (c *apiKeyController) Create(ctx *gin.Context) {
+	var apiKey models.CreateApiKey
+	err := ctx.BindJSON(&apiKey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentials, err := c.service.Create(ctx.GetString("user_id"), apiKey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, credentials)
+}
+
+// @Summary Revoke API key
+// @Description Revoke an API key of the current user
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "API key ID"
+// @Success 204
+// @Router /users/current/api-keys/{id} [delete]
+func (c *apiKeyController) Revoke(ctx *gin.Context) {
+	err := c.service.Revoke(ctx.GetString("user_id"), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type AuditController interface {
+	GetAll(ctx *gin.Context)
+}
+
+func NewAuditController(service services.AuditService) AuditController {
+	log.Info().Msg("Creating new audit controller")
+
+	return &auditController{
+		service: service,
+	}
+}
+
+type auditController struct {
+	service services.AuditService
+}
+
+// @Summary Get audit events
+// @Description Get audit events, newest first
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param query query models.AuditQuery false "Filter"
+// @Success 200 {array} models.AuditEvent
+// @Router /audit-events [get]
+func (c *auditController) GetAll(ctx *gin.Context) {
+	query := models.AuditQuery{}
+	err := ctx.BindQuery(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := c.service.FindAll(query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type GroupController interface {
+	GetAll(ctx *gin.Context)
+	GetById(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+	GetMembers(ctx *gin.Context)
+	AddMember(ctx *gin.Context)
+	RemoveMember(ctx *gin.Context)
+}
+
+func NewGroupController(service services.GroupService) GroupController {
+	log.Info().Msg("Creating new group controller")
+
+	return &groupController{
+		service: service,
+	}
+}
+
+type groupController struct {
+	service services.GroupService
+}
+
+// @Summary Get groups
+// @Description Get all groups and their roles
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param pagination query models.PaginationQuery false "Pagination"
+// @Success 200 {array} models.Group
+// @Router /groups [get]
+func (c *groupController) GetAll(ctx *gin.Context) {
+	query := models.PaginationQuery{}
+	err := ctx.BindQuery(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	groups, err := c.service.FindAll(query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, groups)
+}
+
+// @Summary Get group
+// @Description Get a group and its roles
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Group ID"
+// @Success 200 {object} models.Group
+// @Router /groups/{id} [get]
+func (c *groupController) GetById(ctx *gin.Context) {
+	group, err := c.service.FindById(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, group)
+}
+
+// @Summary Create group
+// @Description Create a group whose members inherit its roles
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param group body models.CreateGroup true "Group"
+// @Success 201 {object} models.Group
+// @Router /groups [post]
+func (c *groupController) Create(ctx *gin.Context) {
+	var group models.CreateGroup
+	err := ctx.BindJSON(&group)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newGroup, err := c.service.Create(ctx.MustGet("claims").(*auth.Claims), group)
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrGroupExists) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newGroup)
+}
+
+// @Summary Update group
+// @Description Replace the description and roles of a group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Group ID"
+// @Param group body models.UpdateGroup true "Group"
+// @Success 200 {object} models.Group
+// @Router /groups/{id} [put]
+func (c *groupController) Update(ctx *gin.Context) {
+	var group models.UpdateGroup
+	err := ctx.BindJSON(&group)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := c.service.Update(ctx.MustGet("claims").(*auth.Claims), ctx.Param("id"), group)
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrUnknownGroup) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// @Summary Delete group
+// @Description Delete a group, its members lose the roles they inherited from it
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Group ID"
+// @Success 204
+// @Router /groups/{id} [delete]
+func (c *groupController) Delete(ctx *gin.Context) {
+	err := c.service.Delete(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Get group members
+// @Description Get the members of a group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Group ID"
+// @Success 200 {array} models.GroupMember
+// @Router /groups/{id}/members [get]
+func (c *groupController) GetMembers(ctx *gin.Context) {
+	members, err := c.service.FindMembers(ctx.Param("id"))
+	if errors.Is(err, services.ErrUnknownGroup) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, members)
+}
+
+// @Summary Add group member
+// @Description Add a user to a group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Group ID"
+// @Param userId path string true "User ID"
+// @Success 204
+// @Router /groups/{id}/members/{userId} [put]
+func (c *groupController) AddMember(ctx *gin.Context) {
+	err := c.service.AddMember(ctx.MustGet("claims").(*auth.Claims), ctx.Param("id"), ctx.Param("userId"))
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Remove group member
+// @Description Remove a user from a group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Group ID"
+// @Param userId path string true "User ID"
+// @Success 204
+// @Router /groups/{id}/members/{userId} [delete]
+func (c *groupController) RemoveMember(ctx *gin.Context) {
+	err := c.service.RemoveMember(ctx.Param("id"), ctx.Param("userId"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
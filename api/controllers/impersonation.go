@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type ImpersonationController interface {
+	Start(ctx *gin.Context)
+	Stop(ctx *gin.Context)
+}
+
+func NewImpersonationController(service services.ImpersonationService) ImpersonationController {
+	log.Info().Msg("Creating new impersonation controller")
+
+	return &impersonationController{
+		service: service,
+	}
+}
+
+type impersonationController struct {
+	service services.ImpersonationService
+}
+
+// @Summary Impersonate user
+// @Description Get a short lived access token for acting as the user, the reason is recorded in the audit log
+// @Tags impersonation
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Param impersonation body models.StartImpersonation true "Impersonation"
+// @Success 200 {object} models.ImpersonationToken
+// @Router /users/{id}/impersonate [post]
+func (c *impersonationController) Start(ctx *gin.Context) {
+	var start models.StartImpersonation
+	err := ctx.BindJSON(&start)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := c.service.Start(ctx.MustGet("claims").(*auth.Claims), ctx.Param("id"), start, clientInfo(ctx))
+	if errors.Is(err, services.ErrImpersonateSelf) || errors.Is(err, services.ErrImpersonatePrivileged) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+// @Summary Stop impersonation
+// @Description Revoke the impersonation token used for the request
+// @Tags impersonation
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 204
+// @Router /users/impersonate/stop [post]
+func (c *impersonationController) Stop(ctx *gin.Context) {
+	claims := ctx.MustGet("claims").(*auth.Claims)
+
+	err := c.service.Stop(claims, clientInfo(ctx))
+	if errors.Is(err, services.ErrNotImpersonating) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
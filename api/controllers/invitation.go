@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type InvitationController interface {
+	GetAll(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Resend(ctx *gin.Context)
+	Revoke(ctx *gin.Context)
+	Preview(ctx *gin.Context)
+	Accept(ctx *gin.Context)
+	Decline(ctx *gin.Context)
+}
+
+func NewInvitationController(service services.InvitationService) InvitationController {
+	log.Info().Msg("Creating new invitation controller")
+
+	return &invitationController{
+		service: service,
+	}
+}
+
+type invitationController struct {
+	service services.InvitationService
+}
+
+// @Summary Get invitations
+// @Description Get the invitations of the current organization
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Success 200 {array} models.Invitation
+// @Router /orgs/current/invitations [get]
+func (c *invitationController) GetAll(ctx *gin.Context) {
+	invitations, err := c.service.FindAll(ctx.GetString("organization_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+// @Summary Invite member
+// @Description Invite someone to the current organization by email
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Param invitation body models.CreateInvitation true "Invitation"
+// @Success 201 {object} models.Invitation
+// @Router /orgs/current/invitations [post]
+func (c *invitationController) Create(ctx *gin.Context) {
+	var invitation models.CreateInvitation
+	err := ctx.BindJSON(&invitation)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newInvitation, err := c.service.Create(ctx.GetString("organization_id"), currentMembership(ctx), invitation)
+	if respondInvitationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newInvitation)
+}
+
+// @Summary Resend invitation
+// @Description Mail a new link for a pending invitation and extend its expiry
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} models.Invitation
+// @Router /orgs/current/invitations/{id}/resend [post]
+func (c *invitationController) Resend(ctx *gin.Context) {
+	invitation, err := c.service.Resend(ctx.GetString("organization_id"), ctx.Param("id"))
+	if respondInvitationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invitation)
+}
+
+// @Summary Revoke invitation
+// @Description Revoke a pending invitation
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Param id path string true "Invitation ID"
+// @Success 204
+// @Router /orgs/current/invitations/{id} [delete]
+func (c *invitationController) Revoke(ctx *gin.Context) {
+	err := c.service.Revoke(ctx.GetString("organization_id"), ctx.Param("id"))
+	if respondInvitationError(ctx, err) {
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Preview invitation
+// @Description Get the organization and role of an invitation before answering it
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param token query string true "Invitation token"
+// @Success 200 {object} models.InvitationPreview
+// @Router /invitations [get]
+func (c *invitationController) Preview(ctx *gin.Context) {
+	var query models.InvitationQuery
+	err := ctx.BindQuery(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := c.service.Preview(query.Token)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, preview)
+}
+
+// @Summary Accept invitation
+// @Description Join the organization, registering an account for the invited email when there is none
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param invitation body models.AcceptInvitation true "Invitation"
+// @Success 200 {object} models.AcceptedInvitation
+// @Router /invitations/accept [post]
+func (c *invitationController) Accept(ctx *gin.Context) {
+	var accept models.AcceptInvitation
+	err := ctx.BindJSON(&accept)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted, err := c.service.Accept(accept, clientInfo(ctx))
+	if respondPolicyError(ctx, err) {
+		return
+	}
+	if respondInvitationError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, accepted)
+}
+
+// @Summary Decline invitation
+// @Description Decline an invitation
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param invitation body models.DeclineInvitation true "Invitation"
+// @Success 204
+// @Router /invitations/decline [post]
+func (c *invitationController) Decline(ctx *gin.Context) {
+	var decline models.DeclineInvitation
+	err := ctx.BindJSON(&decline)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.Decline(decline.Token)
+	if respondInvitationError(ctx, err) {
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func respondInvitationError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrUnknownInvitation):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrgRoleForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationExists), errors.Is(err, services.ErrAlreadyMember), errors.Is(err, services.ErrInvitationNotPending):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationThrottled):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+
+	return true
+}
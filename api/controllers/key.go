@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type KeyController interface {
+	JWKS(ctx *gin.Context)
+}
+
+func NewKeyController(service services.KeyService) KeyController {
+	log.Info().Msg("Creating new key controller")
+
+	return &keyController{
+		service: service,
+	}
+}
+
+type keyController struct {
+	service services.KeyService
+}
+
+// @Summary Get JSON Web Key Set
+// @Description Public keys used to verify access tokens
+// @Tags keys
+// @Produce json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (c *keyController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.service.JWKS())
+}
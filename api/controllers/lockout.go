@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type LockoutController interface {
+	GetLocked(ctx *gin.Context)
+	Unlock(ctx *gin.Context)
+}
+
+func NewLockoutController(service services.LockoutService) LockoutController {
+	log.Info().Msg("Creating new lockout controller")
+
+	return &lockoutController{
+		service: service,
+	}
+}
+
+type lockoutController struct {
+	service services.LockoutService
+}
+
+// @Summary Get lockouts
+// @Description Get the accounts and addresses currently locked out of login
+// @Tags lockouts
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.LoginAttempt
+// @Router /users/lockouts [get]
+func (c *lockoutController) GetLocked(ctx *gin.Context) {
+	attempts, err := c.service.FindLocked()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attempts)
+}
+
+// @Summary Clear lockout
+// @Description Clear the failed attempts and lockout of an account or address
+// @Tags lockouts
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param key path string true "Lockout key, e.g. account:user@example.com or ip:10.0.0.1"
+// @Success 204
+// @Router /users/lockouts/{key} [delete]
+func (c *lockoutController) Unlock(ctx *gin.Context) {
+	err := c.service.Unlock(ctx.Param("key"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const magicLinkCookie = "magic_link_nonce"
+
+type MagicLinkController interface {
+	Request(ctx *gin.Context)
+	Callback(ctx *gin.Context)
+}
+
+func NewMagicLinkController(service services.MagicLinkService, cfg config.Config) MagicLinkController {
+	log.Info().Msg("Creating new magic link controller")
+
+	return &magicLinkController{
+		service: service,
+		cfg:     cfg,
+	}
+}
+
+type magicLinkController struct {
+	service services.MagicLinkService
+	cfg     config.Config
+}
+
+// @Summary Request login link
+// @Description Email a single use login link, the response is the same whether or not the email is registered
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param email body models.RequestMagicLink true "Email"
+// @Success 202
+// @Router /users/login/magic [post]
+func (c *magicLinkController) Request(ctx *gin.Context) {
+	var req models.RequestMagicLink
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nonce, err := c.service.Request(req.Email)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(magicLinkCookie, nonce, int(c.cfg.MagicLinkLifespan.Seconds()), "/api/users/login/magic", "", c.cfg.Env == "prod", true)
+	ctx.Status(http.StatusAccepted)
+}
+
+// @Summary Login link callback
+// @Description Consume the login link in the browser that requested it and issue tokens
+// @Tags users
+// @Produce json
+// @Param callback query models.MagicLinkCallback true "Callback"
+// @Success 200 {object} models.Token
+// @Success 202 {object} models.MfaChallenge
+// @Router /users/login/magic/callback [get]
+func (c *magicLinkController) Callback(ctx *gin.Context) {
+	var callback models.MagicLinkCallback
+	err := ctx.BindQuery(&callback)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nonce, err := ctx.Cookie(magicLinkCookie)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrInvalidMagicLink.Error()})
+		return
+	}
+
+	token, err := c.service.Login(callback.Token, nonce, clientInfo(ctx))
+	var mfaErr *services.MfaRequiredError
+	if errors.As(err, &mfaErr) {
+		ctx.SetCookie(magicLinkCookie, "", -1, "/api/users/login/magic", "", c.cfg.Env == "prod", true)
+		ctx.JSON(http.StatusAccepted, mfaErr.Challenge)
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.SetCookie(magicLinkCookie, "", -1, "/api/users/login/magic", "", c.cfg.Env == "prod", true)
+
+	ctx.JSON(http.StatusOK, token)
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type MfaController interface {
+	LoginMfa(ctx *gin.Context)
+	EnrollTotp(ctx *gin.Context)
+	ConfirmTotp(ctx *gin.Context)
+	DisableTotp(ctx *gin.Context)
+	RegenerateRecoveryCodes(ctx *gin.Context)
+}
+
+func NewMfaController(service services.MfaService) MfaController {
+	log.Info().Msg("Creating new mfa controller")
+
+	return &mfaController{
+		service: service,
+	}
+}
+
+type mfaController struct {
+	service services.MfaService
+}
+
+// @Summary Complete login with a second factor
+// @Description Exchange the mfa token returned by login and a TOTP or recovery code for a token pair
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param login body models.LoginMfa true "MFA login"
+// @Success 200 {object} models.Token
+// @Router /users/login/mfa [post]
+func (c *mfaController) LoginMfa(ctx *gin.Context) {
+	var login models.LoginMfa
+	err := ctx.BindJSON(&login)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := c.service.Verify(login, clientInfo(ctx))
+	var lockedErr *services.LockedError
+	if errors.As(err, &lockedErr) {
+		ctx.Header("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+// @Summary Start TOTP enrollment
+// @Description Generate a TOTP secret for the current user, it is enforced once confirmed
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.TotpEnrollment
+// @Router /users/current/mfa/totp [post]
+func (c *mfaController) EnrollTotp(ctx *gin.Context) {
+	id := ctx.GetString("user_id")
+
+	enrollment, err := c.service.EnrollTotp(id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Enable TOTP with a code from the authenticator and get recovery codes
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param code body models.MfaCode true "TOTP code"
+// @Success 200 {object} models.RecoveryCodes
+// @Router /users/current/mfa/totp/confirm [post]
+func (c *mfaController) ConfirmTotp(ctx *gin.Context) {
+	var code models.MfaCode
+	err := ctx.BindJSON(&code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := c.service.ConfirmTotp(ctx.GetString("user_id"), code.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, codes)
+}
+
+// @Summary Disable TOTP
+// @Description Disable TOTP with a TOTP or recovery code
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param code body models.MfaCode true "TOTP or recovery code"
+// @Success 204
+// @Router /users/current/mfa/totp [delete]
+func (c *mfaController) DisableTotp(ctx *gin.Context) {
+	var code models.MfaCode
+	err := ctx.BindJSON(&code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.DisableTotp(ctx.GetString("user_id"), code.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes, requires a TOTP code
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param code body models.MfaCode true "TOTP code"
+// @Success 200 {object} models.RecoveryCodes
+// @Router /users/current/mfa/recovery-codes [post]
+func (c *mfaController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var code models.MfaCode
+	err := ctx.BindJSON(&code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := c.service.RegenerateRecoveryCodes(ctx.GetString("user_id"), code.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, codes)
+}
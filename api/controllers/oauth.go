@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type OAuthController interface {
+	CreateClient(ctx *gin.Context)
+	CreateMachineClient(ctx *gin.Context)
+	GetAllClients(ctx *gin.Context)
+	DeleteClient(ctx *gin.Context)
+	Authorize(ctx *gin.Context)
+	Decide(ctx *gin.Context)
+	Token(ctx *gin.Context)
+	UserInfo(ctx *gin.Context)
+	Introspect(ctx *gin.Context)
+	Configuration(ctx *gin.Context)
+}
+
+func NewOAuthController(service services.OAuthService) OAuthController {
+	log.Info().Msg("Creating new oauth controller")
+
+	return &oauthController{
+		service: service,
+	}
+}
+
+type oauthController struct {
+	service services.OAuthService
+}
+
+// @Summary Register OAuth client
+// @Description Register an application allowed to sign users in. The secret is only returned once.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param client body models.CreateOAuthClient true "Client"
+// @Success 201 {object} models.OAuthClientCredentials
+// @Router /oauth/clients [post]
+func (c *oauthController) CreateClient(ctx *gin.Context) {
+	var client models.CreateOAuthClient
+	err := ctx.BindJSON(&client)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentials, err := c.service.CreateClient(ctx.GetString("user_id"), client)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, credentials)
+}
+
+// @Summary Register machine client
+// @Description Register a client for service to service calls through the client credentials grant. The secret is only returned once.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param client body models.CreateMachineClient true "Client"
+// @Success 201 {object} models.OAuthClientCredentials
+// @Router /oauth/clients/machine [post]
+func (c *oauthController) CreateMachineClient(ctx *gin.Context) {
+	var client models.CreateMachineClient
+	err := ctx.BindJSON(&client)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentials, err := c.service.CreateMachineClient(ctx.MustGet("claims").(*auth.Claims), client)
+	if errors.Is(err, services.ErrScopeNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, credentials)
+}
+
+// @Summary Get all OAuth clients
+// @Description Get all OAuth clients
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param pagination query models.PaginationQuery false "Pagination"
+// @Success 200 {array} models.OAuthClient
+// @Router /oauth/clients [get]
+func (c *oauthController) GetAllClients(ctx *gin.Context) {
+	query := models.PaginationQuery{}
+	err := ctx.BindQuery(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clients, err := c.service.FindAllClients(query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, clients)
+}
+
+// @Summary Delete OAuth client
+// @Description Delete OAuth client and revoke the tokens issued to it
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Client ID"
+// @Success 200
+// @Router /oauth/clients/{id} [delete]
+func (c *oauthController) DeleteClient(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	err := c.service.DeleteClient(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// @Summary Validate authorization request
+// @Description Validate an authorization request and return the consent screen data
+// @Tags oauth
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request query models.AuthorizeRequest true "Authorization request"
+// @Success 200 {object} models.ConsentScreen
+// @Router /oauth/authorize [get]
+func (c *oauthController) Authorize(ctx *gin.Context) {
+	var req models.AuthorizeRequest
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, services.OAuthError{Code: "invalid_request", Description: err.Error()})
+		return
+	}
+
+	screen, err := c.service.Authorize(req)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, screen)
+}
+
+// @Summary Approve or deny authorization request
+// @Description Record the user's consent and return where to redirect the browser
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param decision body models.AuthorizeDecision true "Decision"
+// @Success 200 {object} models.AuthorizeResponse
+// @Router /oauth/authorize [post]
+func (c *oauthController) Decide(ctx *gin.Context) {
+	var decision models.AuthorizeDecision
+	err := ctx.ShouldBindJSON(&decision)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, services.OAuthError{Code: "invalid_request", Description: err.Error()})
+		return
+	}
+
+	res, err := c.service.Decide(ctx.GetString("user_id"), decision)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Token endpoint
+// @Description Exchange an authorization code, refresh token or client credentials for tokens
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request formData models.OAuthTokenRequest true "Token request"
+// @Success 200 {object} models.OAuthTokenResponse
+// @Router /oauth/token [post]
+func (c *oauthController) Token(ctx *gin.Context) {
+	var req models.OAuthTokenRequest
+	err := ctx.ShouldBind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, services.OAuthError{Code: "invalid_request", Description: err.Error()})
+		return
+	}
+
+	if id, secret, ok := ctx.Request.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	res, err := c.service.Token(req)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary User info
+// @Description Claims about the authenticated user
+// @Tags oauth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.UserInfo
+// @Router /oauth/userinfo [get]
+func (c *oauthController) UserInfo(ctx *gin.Context) {
+	claims := ctx.MustGet("claims").(*auth.Claims)
+
+	info, err := c.service.UserInfo(claims)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
+
+// @Summary Token introspection
+// @Description Report whether an access or refresh token is active, the caller authenticates as a confidential client
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request formData models.IntrospectionRequest true "Introspection request"
+// @Success 200 {object} models.IntrospectionResponse
+// @Router /oauth/introspect [post]
+func (c *oauthController) Introspect(ctx *gin.Context) {
+	var req models.IntrospectionRequest
+	err := ctx.ShouldBind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, services.OAuthError{Code: "invalid_request", Description: err.Error()})
+		return
+	}
+
+	if id, secret, ok := ctx.Request.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	res, err := c.service.Introspect(req)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary OpenID Connect discovery
+// @Description OpenID Provider metadata
+// @Tags oauth
+// @Produce json
+// @Success 200 {object} models.OpenIDConfiguration
+// @Router /.well-known/openid-configuration [get]
+func (c *oauthController) Configuration(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.service.Configuration())
+}
+
+func respondOAuthError(ctx *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		ctx.JSON(http.StatusInternalServerError, services.OAuthError{Code: "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case "invalid_client", "invalid_token":
+		status = http.StatusUnauthorized
+	case "insufficient_scope":
+		status = http.StatusForbidden
+	}
+
+	ctx.JSON(status, oauthErr)
+}
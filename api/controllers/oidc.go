@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const oidcStateCookie = "oidc_state"
+
+type OidcController interface {
+	Login(ctx *gin.Context)
+	Callback(ctx *gin.Context)
+}
+
+func NewOidcController(service services.OidcService, cfg config.Config) OidcController {
+	log.Info().Msg("Creating new oidc controller")
+
+	return &oidcController{
+		service: service,
+		cfg:     cfg,
+	}
+}
+
+type oidcController struct {
+	service services.OidcService
+	cfg     config.Config
+}
+
+// @Summary Login with external provider
+// @Description Redirect to the OpenID Connect provider
+// @Tags users
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Router /users/oidc/{provider}/login [get]
+func (c *oidcController) Login(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+
+	authURL, state, err := c.service.Login(provider)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, state, int(c.cfg.OidcStateLifespan.Seconds()), "/api/users/oidc", "", c.cfg.Env == "prod", true)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary External provider callback
+// @Description Finish the OpenID Connect login and issue tokens, or an mfa token when a second factor is required
+// @Tags users
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param callback query models.OidcCallback true "Callback"
+// @Success 200 {object} models.Token
+// @Success 202 {object} models.MfaChallenge
+// @Router /users/oidc/{provider}/callback [get]
+func (c *oidcController) Callback(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+
+	var callback models.OidcCallback
+	err := ctx.BindQuery(&callback)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, err := ctx.Cookie(oidcStateCookie)
+	if err != nil || state != callback.State {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrInvalidState.Error()})
+		return
+	}
+	ctx.SetCookie(oidcStateCookie, "", -1, "/api/users/oidc", "", c.cfg.Env == "prod", true)
+
+	token, err := c.service.Callback(provider, callback.State, callback.Code, clientInfo(ctx))
+	var mfaErr *services.MfaRequiredError
+	if errors.As(err, &mfaErr) {
+		ctx.JSON(http.StatusAccepted, mfaErr.Challenge)
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type OrganizationController interface {
+	Create(ctx *gin.Context)
+	GetAll(ctx *gin.Context)
+	GetCurrent(ctx *gin.Context)
+	GetMembers(ctx *gin.Context)
+	UpdateMember(ctx *gin.Context)
+	RemoveMember(ctx *gin.Context)
+}
+
+func NewOrganizationController(service services.OrganizationService) OrganizationController {
+	log.Info().Msg("Creating new organization controller")
+
+	return &organizationController{
+		service: service,
+	}
+}
+
+type organizationController struct {
+	service services.OrganizationService
+}
+
+// @Summary Create organization
+// @Description Create an organization owned by the current user
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param organization body models.CreateOrganization true "Organization"
+// @Success 201 {object} models.Organization
+// @Router /orgs [post]
+func (c *organizationController) Create(ctx *gin.Context) {
+	var organization models.CreateOrganization
+	err := ctx.BindJSON(&organization)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newOrganization, err := c.service.Create(ctx.GetString("user_id"), organization)
+	if errors.Is(err, services.ErrSlugTaken) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newOrganization)
+}
+
+// @Summary Get organizations
+// @Description Get the organizations the current user is a member of
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Organization
+// @Router /orgs [get]
+func (c *organizationController) GetAll(ctx *gin.Context) {
+	organizations, err := c.service.FindAll(ctx.GetString("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, organizations)
+}
+
+// @Summary Get current organization
+// @Description Get the organization selected by the X-Organization-ID header
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Success 200 {object} models.Organization
+// @Router /orgs/current [get]
+func (c *organizationController) GetCurrent(ctx *gin.Context) {
+	organization, err := c.service.FindById(ctx.GetString("organization_id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization.Role = currentMembership(ctx).Role
+	ctx.JSON(http.StatusOK, organization)
+}
+
+// @Summary Get members
+// @Description Get the members of the current organization
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Success 200 {array} models.Membership
+// @Router /orgs/current/members [get]
+func (c *organizationController) GetMembers(ctx *gin.Context) {
+	members, err := c.service.FindMembers(ctx.GetString("organization_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, members)
+}
+
+// @Summary Change member role
+// @Description Change the role of a member of the current organization
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Param id path string true "User ID"
+// @Param membership body models.UpdateMembership true "Membership"
+// @Success 200 {object} models.Membership
+// @Router /orgs/current/members/{id} [patch]
+func (c *organizationController) UpdateMember(ctx *gin.Context) {
+	var update models.UpdateMembership
+	err := ctx.BindJSON(&update)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	membership, err := c.service.UpdateMember(ctx.GetString("organization_id"), currentMembership(ctx), ctx.Param("id"), update)
+	if respondMembershipError(ctx, err) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, membership)
+}
+
+// @Summary Remove member
+// @Description Remove a member from the current organization, members may remove themselves
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string true "Organization ID"
+// @Param id path string true "User ID"
+// @Success 204
+// @Router /orgs/current/members/{id} [delete]
+func (c *organizationController) RemoveMember(ctx *gin.Context) {
+	err := c.service.RemoveMember(ctx.GetString("organization_id"), currentMembership(ctx), ctx.Param("id"))
+	if respondMembershipError(ctx, err) {
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func currentMembership(ctx *gin.Context) models.Membership {
+	membership, _ := ctx.MustGet("membership").(models.Membership)
+	return membership
+}
+
+func respondMembershipError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrUnknownMember):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrgRoleForbidden), errors.Is(err, services.ErrOrgAdminRequired):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLastOwner):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	return true
+}
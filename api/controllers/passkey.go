@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth/webauthn"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type PasskeyController interface {
+	GetAll(ctx *gin.Context)
+	BeginRegistration(ctx *gin.Context)
+	FinishRegistration(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+	BeginLogin(ctx *gin.Context)
+	FinishLogin(ctx *gin.Context)
+}
+
+func NewPasskeyController(service services.PasskeyService) PasskeyController {
+	log.Info().Msg("Creating new passkey controller")
+
+	return &passkeyController{
+		service: service,
+	}
+}
+
+type passkeyController struct {
+	service services.PasskeyService
+}
+
+// @Summary Get passkeys
+// @Description Get the passkeys of the current user
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Passkey
+// @Router /users/current/passkeys [get]
+func (c *passkeyController) GetAll(ctx *gin.Context) {
+	passkeys, err := c.service.FindAll(ctx.GetString("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, passkeys)
+}
+
+// @Summary Begin passkey registration
+// @Description Get the options for navigator.credentials.create
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} webauthn.CreationOptions
+// @Router /users/current/passkeys/begin [post]
+func (c *passkeyController) BeginRegistration(ctx *gin.Context) {
+	options, err := c.service.BeginRegistration(ctx.GetString("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, options)
+}
+
+// @Summary Finish passkey registration
+// @Description Store the credential returned by navigator.credentials.create
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param passkey body models.RegisterPasskey true "Passkey"
+// @Success 201 {object} models.Passkey
+// @Router /users/current/passkeys [post]
+func (c *passkeyController) FinishRegistration(ctx *gin.Context) {
+	var passkey models.RegisterPasskey
+	err := ctx.BindJSON(&passkey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newPasskey, err := c.service.FinishRegistration(ctx.GetString("user_id"), passkey)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newPasskey)
+}
+
+// @Summary Delete passkey
+// @Description Remove a passkey of the current user
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Passkey ID"
+// @Success 204
+// @Router /users/current/passkeys/{id} [delete]
+func (c *passkeyController) Delete(ctx *gin.Context) {
+	err := c.service.Delete(ctx.GetString("user_id"), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Begin passkey login
+// @Description Get the options for navigator.credentials.get
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Success 200 {object} webauthn.RequestOptions
+// @Router /users/login/passkey/begin [post]
+func (c *passkeyController) BeginLogin(ctx *gin.Context) {
+	options, err := c.service.BeginLogin()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, options)
+}
+
+// @Summary Finish passkey login
+// @Description Exchange the assertion returned by navigator.credentials.get for a token pair
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Param credential body webauthn.AssertionResponse true "Assertion"
+// @Success 200 {object} models.Token
+// @Success 202 {object} models.MfaChallenge
+// @Router /users/login/passkey [post]
+func (c *passkeyController) FinishLogin(ctx *gin.Context) {
+	var res webauthn.AssertionResponse
+	err := ctx.BindJSON(&res)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := c.service.FinishLogin(res, clientInfo(ctx))
+	var mfaErr *services.MfaRequiredError
+	if errors.As(err, &mfaErr) {
+		ctx.JSON(http.StatusAccepted, mfaErr.Challenge)
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
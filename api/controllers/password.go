@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/password"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type PasswordController interface {
+	Forgot(ctx *gin.Context)
+	Reset(ctx *gin.Context)
+	Change(ctx *gin.Context)
+}
+
+func NewPasswordController(service services.PasswordService) PasswordController {
+	log.Info().Msg("Creating new password controller")
+
+	return &passwordController{
+		service: service,
+	}
+}
+
+type passwordController struct {
+	service services.PasswordService
+}
+
+// @Summary Forgot password
+// @Description Email a password reset link, the response is the same whether or not the email is registered
+// @Tags password
+// @Accept json
+// @Produce json
+// @Param email body models.ForgotPassword true "Email"
+// @Success 202
+// @Router /users/password/forgot [post]
+func (c *passwordController) Forgot(ctx *gin.Context) {
+	var forgot models.ForgotPassword
+	err := ctx.BindJSON(&forgot)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.Forgot(forgot.Email)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// @Summary Reset password
+// @Description Set a new password with a reset token, all sessions of the user are revoked
+// @Tags password
+// @Accept json
+// @Produce json
+// @Param reset body models.ResetPassword true "Reset"
+// @Success 204
+// @Router /users/password/reset [post]
+func (c *passwordController) Reset(ctx *gin.Context) {
+	var reset models.ResetPassword
+	err := ctx.BindJSON(&reset)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.Reset(reset)
+	if respondPolicyError(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Change password
+// @Description Change the password of the current user, all sessions of the user are revoked. Accounts without a password need a recent sign in to set one
+// @Tags password
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param change body models.ChangePassword true "Change"
+// @Success 204
+// @Router /users/current/password [post]
+func (c *passwordController) Change(ctx *gin.Context) {
+	var change models.ChangePassword
+	err := ctx.BindJSON(&change)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.Change(ctx.MustGet("claims").(*auth.Claims), change)
+	if respondPolicyError(ctx, err) {
+		return
+	}
+	if errors.Is(err, services.ErrReauthRequired) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// respondPolicyError writes the violated password rules and reports whether
+// it handled the error.
+func respondPolicyError(ctx *gin.Context, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "violations": policyErr.Violations})
+	return true
+}
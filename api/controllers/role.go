@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type RoleController interface {
+	GetAll(ctx *gin.Context)
+	GetByName(ctx *gin.Context)
+	GetPermissions(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+	AddPermission(ctx *gin.Context)
+	RemovePermission(ctx *gin.Context)
+}
+
+func NewRoleController(service services.RoleService) RoleController {
+	log.Info().Msg("Creating new role controller")
+
+	return &roleController{
+		service: service,
+	}
+}
+
+type roleController struct {
+	service services.RoleService
+}
+
+// @Summary Get roles
+// @Description Get all roles with their permissions, inherited roles and member counts
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Role
+// @Router /roles [get]
+func (c *roleController) GetAll(ctx *gin.Context) {
+	roles, err := c.service.FindAll()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, roles)
+}
+
+// @Summary Get role
+// @Description Get a role and its permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} models.Role
+// @Router /roles/{name} [get]
+func (c *roleController) GetByName(ctx *gin.Context) {
+	role, err := c.service.FindByName(ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, role)
+}
+
+// @Summary Get permissions
+// @Description Get the permissions roles can grant
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} string
+// @Router /roles/permissions [get]
+func (c *roleController) GetPermissions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, models.Permissions)
+}
+
+// @Summary Create role
+// @Description Create a role with a set of permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param role body models.CreateRole true "Role"
+// @Success 201 {object} models.Role
+// @Router /roles [post]
+func (c *roleController) Create(ctx *gin.Context) {
+	var role models.CreateRole
+	err := ctx.BindJSON(&role)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newRole, err := c.service.Create(ctx.MustGet("claims").(*auth.Claims), role)
+	if errors.Is(err, services.ErrRoleExists) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newRole)
+}
+
+// @Summary Update role
+// @Description Replace the description and permissions of a role
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Param role body models.UpdateRole true "Role"
+// @Success 200 {object} models.Role
+// @Router /roles/{name} [put]
+func (c *roleController) Update(ctx *gin.Context) {
+	var role models.UpdateRole
+	err := ctx.BindJSON(&role)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := c.service.Update(ctx.MustGet("claims").(*auth.Claims), ctx.Param("name"), role)
+	respondRole(ctx, updated, err)
+}
+
+// @Summary Delete role
+// @Description Delete a role that is not assigned to any user
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Success 204
+// @Router /roles/{name} [delete]
+func (c *roleController) Delete(ctx *gin.Context) {
+	err := c.service.Delete(ctx.Param("name"))
+	if errors.Is(err, services.ErrUnknownRole) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrRoleInUse) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Add permission
+// @Description Grant a permission to a role
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Param permission path string true "Permission, e.g. users:delete"
+// @Success 200 {object} models.Role
+// @Router /roles/{name}/permissions/{permission} [put]
+func (c *roleController) AddPermission(ctx *gin.Context) {
+	role, err := c.service.AddPermission(ctx.MustGet("claims").(*auth.Claims), ctx.Param("name"), ctx.Param("permission"))
+	respondRole(ctx, role, err)
+}
+
+// @Summary Remove permission
+// @Description Revoke a permission from a role
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Param permission path string true "Permission, e.g. users:delete"
+// @Success 200 {object} models.Role
+// @Router /roles/{name}/permissions/{permission} [delete]
+func (c *roleController) RemovePermission(ctx *gin.Context) {
+	role, err := c.service.RemovePermission(ctx.MustGet("claims").(*auth.Claims), ctx.Param("name"), ctx.Param("permission"))
+	respondRole(ctx, role, err)
+}
+
+func respondRole(ctx *gin.Context, role models.Role, err error) {
+	if errors.Is(err, services.ErrUnknownRole) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, role)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type SessionController interface {
+	GetAll(ctx *gin.Context)
+	Revoke(ctx *gin.Context)
+	RevokeOthers(ctx *gin.Context)
+}
+
+func NewSessionController(service services.SessionService) SessionController {
+	log.Info().Msg("Creating new session controller")
+
+	return &sessionController{
+		service: service,
+	}
+}
+
+type sessionController struct {
+	service services.SessionService
+}
+
+// @Summary Get sessions
+// @Description Get the devices the current user is signed in on
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Session
+// @Router /users/current/sessions [get]
+func (c *sessionController) GetAll(ctx *gin.Context) {
+	sessions, err := c.service.FindAll(ctx.GetString("user_id"), currentSessionId(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+// @Summary Revoke session
+// @Description Sign the current user out of a device
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Session ID"
+// @Success 204
+// @Router /users/current/sessions/{id} [delete]
+func (c *sessionController) Revoke(ctx *gin.Context) {
+	err := c.service.Revoke(ctx.GetString("user_id"), ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary Revoke other sessions
+// @Description Sign the current user out of every device except this one
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 204
+// @Router /users/current/sessions [delete]
+func (c *sessionController) RevokeOthers(ctx *gin.Context) {
+	err := c.service.RevokeOthers(ctx.GetString("user_id"), currentSessionId(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func currentSessionId(ctx *gin.Context) string {
+	claims, ok := ctx.MustGet("claims").(*auth.Claims)
+	if !ok {
+		return ""
+	}
+
+	return claims.SessionID
+}
+
+// clientInfo describes the client of a request for the session it signs in.
+func clientInfo(ctx *gin.Context) models.ClientInfo {
+	return models.ClientInfo{IP: ctx.ClientIP(), UserAgent: ctx.Request.UserAgent()}
+}
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/policy"
 	"github.com/Marcel-MD/clean-api/models"
 	"github.com/Marcel-MD/clean-api/services"
 	"github.com/gin-gonic/gin"
@@ -16,6 +20,10 @@ type UserController interface {
 	Register(ctx *gin.Context)
 	Login(ctx *gin.Context)
 	RefreshToken(ctx *gin.Context)
+	Logout(ctx *gin.Context)
+	LogoutAll(ctx *gin.Context)
+	Verify(ctx *gin.Context)
+	ResendVerification(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 	AssignRole(ctx *gin.Context)
 	RemoveRole(ctx *gin.Context)
@@ -38,6 +46,7 @@ type userController struct {
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param pagination query models.PaginationQuery false "Pagination"
 // @Success 200 {array} models.User
 // @Router /users [get]
@@ -49,7 +58,10 @@ func (c *userController) GetAll(ctx *gin.Context) {
 		return
 	}
 
-	users, err := c.service.FindAll(query)
+	users, err := c.service.FindAll(ctx.MustGet("claims").(*auth.Claims), query)
+	if respondForbidden(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -63,13 +75,17 @@ func (c *userController) GetAll(ctx *gin.Context) {
 // @Tags users
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param id path string true "User ID"
 // @Success 200 {object} models.User
 // @Router /users/{id} [get]
 func (c *userController) GetById(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	user, err := c.service.FindById(id)
+	user, err := c.service.FindById(ctx.MustGet("claims").(*auth.Claims), id)
+	if respondForbidden(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -89,7 +105,7 @@ func (c *userController) GetById(ctx *gin.Context) {
 func (c *userController) GetCurrent(ctx *gin.Context) {
 	id := ctx.GetString("user_id")
 
-	user, err := c.service.FindById(id)
+	user, err := c.service.FindById(ctx.MustGet("claims").(*auth.Claims), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -114,7 +130,10 @@ func (c *userController) Register(ctx *gin.Context) {
 		return
 	}
 
-	token, err := c.service.Register(user)
+	token, err := c.service.Register(user, clientInfo(ctx))
+	if respondPolicyError(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -130,6 +149,7 @@ func (c *userController) Register(ctx *gin.Context) {
 // @Produce json
 // @Param user body models.LoginUser true "User"
 // @Success 200 {object} models.Token
+// @Success 202 {object} models.MfaChallenge
 // @Router /users/login [post]
 func (c *userController) Login(ctx *gin.Context) {
 	var user models.LoginUser
@@ -139,7 +159,18 @@ func (c *userController) Login(ctx *gin.Context) {
 		return
 	}
 
-	token, err := c.service.Login(user)
+	token, err := c.service.Login(user, clientInfo(ctx))
+	var mfaErr *services.MfaRequiredError
+	if errors.As(err, &mfaErr) {
+		ctx.JSON(http.StatusAccepted, mfaErr.Challenge)
+		return
+	}
+	var lockedErr *services.LockedError
+	if errors.As(err, &lockedErr) {
+		ctx.Header("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -164,7 +195,7 @@ func (c *userController) RefreshToken(ctx *gin.Context) {
 		return
 	}
 
-	token, err := c.service.RefreshToken(refreshToken.Token)
+	token, err := c.service.RefreshToken(refreshToken.Token, clientInfo(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -173,8 +204,58 @@ func (c *userController) RefreshToken(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, token)
 }
 
+// @Summary Logout user
+// @Description Revoke the current access token and, if given, its refresh token
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param token body models.RefreshToken false "Refresh token"
+// @Success 200
+// @Router /users/logout [post]
+func (c *userController) Logout(ctx *gin.Context) {
+	var refreshToken models.RefreshToken
+	if ctx.Request.ContentLength > 0 {
+		err := ctx.BindJSON(&refreshToken)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	claims := ctx.MustGet("claims").(*auth.Claims)
+
+	err := c.service.Logout(claims, refreshToken.Token)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// @Summary Logout user from all sessions
+// @Description Revoke every access and refresh token of the current user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200
+// @Router /users/logout/all [post]
+func (c *userController) LogoutAll(ctx *gin.Context) {
+	id := ctx.GetString("user_id")
+
+	err := c.service.LogoutAll(id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
 // @Summary Delete user
-// @Description Delete user
+// @Description Delete a user, users may delete their own account
 // @Tags users
 // @Accept json
 // @Produce json
@@ -185,7 +266,10 @@ func (c *userController) RefreshToken(ctx *gin.Context) {
 func (c *userController) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	err := c.service.Delete(id)
+	err := c.service.Delete(ctx.MustGet("claims").(*auth.Claims), id)
+	if respondForbidden(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -195,20 +279,38 @@ func (c *userController) Delete(ctx *gin.Context) {
 }
 
 // @Summary Assign role to user
-// @Description Assign role to user
+// @Description Assign a known role to user, optionally until an expiry
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Param id path string true "User ID"
 // @Param role path string true "Role"
+// @Param grant body models.AssignRole false "Grant"
 // @Success 200
 // @Router /users/{id}/roles/{role} [patch]
 func (c *userController) AssignRole(ctx *gin.Context) {
 	id := ctx.Param("id")
 	role := ctx.Param("role")
 
-	err := c.service.AssignRole(id, role)
+	var assign models.AssignRole
+	if ctx.Request.ContentLength > 0 {
+		err := ctx.BindJSON(&assign)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := c.service.AssignRole(ctx.MustGet("claims").(*auth.Claims), id, role, assign)
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrUnknownRole) || errors.Is(err, services.ErrInvalidExpiry) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -231,7 +333,11 @@ func (c *userController) RemoveRole(ctx *gin.Context) {
 	id := ctx.Param("id")
 	role := ctx.Param("role")
 
-	err := c.service.RemoveRole(id, role)
+	err := c.service.RemoveRole(ctx.MustGet("claims").(*auth.Claims), id, role)
+	if errors.Is(err, services.ErrRoleNotHeld) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -239,3 +345,63 @@ func (c *userController) RemoveRole(ctx *gin.Context) {
 
 	ctx.Status(http.StatusOK)
 }
+
+// @Summary Verify email
+// @Description Verify the email address with the link sent after registration
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200
+// @Router /users/verify [get]
+func (c *userController) Verify(ctx *gin.Context) {
+	var query models.VerifyEmail
+	err := ctx.BindQuery(&query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.Verify(query.Token)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// @Summary Resend verification email
+// @Description Send a new verification link, the response does not reveal whether the email is registered
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param email body models.ResendVerification true "Email"
+// @Success 202
+// @Router /users/verify/resend [post]
+func (c *userController) ResendVerification(ctx *gin.Context) {
+	var resend models.ResendVerification
+	err := ctx.BindJSON(&resend)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.ResendVerification(resend.Email)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+// respondForbidden answers requests the authorization policy denied.
+func respondForbidden(ctx *gin.Context, err error) bool {
+	if !errors.Is(err, policy.ErrDenied) {
+		return false
+	}
+
+	ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	return true
+}
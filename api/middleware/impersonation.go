@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// RejectImpersonation keeps admins acting as a user away from sensitive
+// actions such as changing credentials. It must run after JwtAuth.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ctx.MustGet("claims").(*auth.Claims)
+		if !ok || claims.Impersonated() {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "forbidden while impersonating"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -1,61 +1,135 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func JwtAuth(secret string) gin.HandlerFunc {
+var (
+	errMissingScope    = errors.New("forbidden, api key is missing required scope")
+	errClientPrincipal = errors.New("forbidden, machine clients can not be used here")
+	errDelegated       = errors.New("forbidden, tokens issued to oauth clients can not be used here")
+)
+
+// JwtAuth accepts tokens of users, machine clients and OAuth clients acting
+// for a user are rejected.
+func JwtAuth(tokenService services.TokenService) gin.HandlerFunc {
+	return JwtAuthDelegated(tokenService, "")
+}
+
+// JwtAuthDelegated is JwtAuth that also accepts tokens of OAuth clients
+// acting for a user, when they were granted the scope.
+func JwtAuthDelegated(tokenService services.TokenService, scope string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token, err := extract(ctx, secret)
+		claims, err := extractUser(ctx, tokenService, scope)
+		if isForbidden(err) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
 		if err != nil {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			ctx.Abort()
 			return
 		}
 
-		id, err := auth.ExtractId(token)
+		ctx.Set("user_id", claims.UserID)
+		ctx.Set("roles", claims.Roles)
+		ctx.Set("claims", claims)
+		ctx.Next()
+	}
+}
+
+func JwtAuthRoles(tokenService services.TokenService, requiredRoles []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, err := extractUser(ctx, tokenService, "")
+		if isForbidden(err) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
 		if err != nil {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			ctx.Abort()
 			return
 		}
-		ctx.Set("user_id", id)
+
+		if !contains(claims.Roles, requiredRoles) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized, missing required role"})
+			ctx.Abort()
+			return
+		}
+		ctx.Set("user_id", claims.UserID)
+		ctx.Set("roles", claims.Roles)
+		ctx.Set("claims", claims)
 		ctx.Next()
 	}
 }
 
-func JwtAuthRoles(secret string, requiredRoles []string) gin.HandlerFunc {
+// JwtAuthPrincipal accepts users with one of the required roles, or any
+// user when no roles are given, and machine clients and API keys granted
+// the required scope. Clients also need the read or write scope matching
+// the request method, like API keys. OAuth clients acting for a user are
+// rejected.
+func JwtAuthPrincipal(tokenService services.TokenService, requiredRoles []string, requiredScope string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token, err := extract(ctx, secret)
+		claims, err := extract(ctx, tokenService)
+		if isForbidden(err) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
 		if err != nil {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			ctx.Abort()
 			return
 		}
 
-		id, roles, err := auth.ExtractIdAndRoles(token)
-		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		if claims.Delegated() {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": errDelegated.Error()})
 			ctx.Abort()
 			return
 		}
 
-		if !contains(roles, requiredRoles) {
+		if claims.Type == auth.TokenTypeApiKey && !hasScope(claims.Scope, requiredScope) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": errMissingScope.Error()})
+			ctx.Abort()
+			return
+		}
+
+		if claims.IsClient() {
+			if !hasScope(claims.Scope, requiredScope) || !hasScope(claims.Scope, methodScope(ctx.Request.Method)) {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": "forbidden, client is missing required scope"})
+				ctx.Abort()
+				return
+			}
+
+			ctx.Set("client_id", claims.ClientID)
+		} else if len(requiredRoles) > 0 && !contains(claims.Roles, requiredRoles) {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized, missing required role"})
 			ctx.Abort()
 			return
 		}
-		ctx.Set("user_id", id)
-		ctx.Set("roles", roles)
+
+		ctx.Set("user_id", claims.UserID)
+		ctx.Set("roles", claims.Roles)
+		ctx.Set("claims", claims)
 		ctx.Next()
 	}
 }
 
+// isForbidden reports whether a valid credential was rejected by policy
+// rather than being invalid.
+func isForbidden(err error) bool {
+	return errors.Is(err, errMissingScope) || errors.Is(err, errClientPrincipal) || errors.Is(err, errDelegated) || errors.Is(err, services.ErrUnverifiedEmail)
+}
+
 func contains(s []string, e []string) bool {
 	for _, a := range e {
 		for _, b := range s {
@@ -67,7 +141,27 @@ func contains(s []string, e []string) bool {
 	return false
 }
 
-func extract(ctx *gin.Context, secret string) (*jwt.Token, error) {
+// extractUser returns the claims of a user token. Tokens of OAuth clients
+// acting for the user are only accepted with the delegated scope, an empty
+// scope rejects them.
+func extractUser(ctx *gin.Context, tokenService services.TokenService, delegatedScope string) (*auth.Claims, error) {
+	claims, err := extract(ctx, tokenService)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.IsClient() {
+		return nil, errClientPrincipal
+	}
+
+	if claims.Delegated() && (delegatedScope == "" || !hasScope(claims.Scope, delegatedScope)) {
+		return nil, errDelegated
+	}
+
+	return claims, nil
+}
+
+func extract(ctx *gin.Context, tokenService services.TokenService) (*auth.Claims, error) {
 	tokenString := ctx.Query("token")
 	if tokenString == "" {
 		bearerToken := ctx.Request.Header.Get("Authorization")
@@ -76,5 +170,54 @@ func extract(ctx *gin.Context, secret string) (*jwt.Token, error) {
 		}
 	}
 
-	return auth.Validate(tokenString, secret)
+	if !strings.HasPrefix(tokenString, models.ApiKeyPrefix) {
+		return tokenService.ValidateAccessToken(tokenString)
+	}
+
+	claims, err := tokenService.ValidateApiKey(tokenString, ctx.ClientIP())
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasScope(claims.Scope, methodScope(ctx.Request.Method)) {
+		return nil, errMissingScope
+	}
+
+	return claims, nil
+}
+
+// methodScope is the API key scope needed for a request, safe methods only
+// read while everything else writes.
+func methodScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return models.ApiScopeRead
+	}
+
+	return models.ApiScopeWrite
+}
+
+func hasScope(scope, required string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == required {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RejectApiKeys limits a route to interactive sessions. It must run after
+// JwtAuth.
+func RejectApiKeys() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ctx.MustGet("claims").(*auth.Claims)
+		if !ok || claims.Type == auth.TokenTypeApiKey {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "forbidden, api keys can not be used here"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
 }
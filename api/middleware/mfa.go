@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMfa rejects tokens of users with one of the given roles that were
+// not issued after a second factor. It must run after JwtAuth.
+func RequireMfa(roles []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ctx.MustGet("claims").(*auth.Claims)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			ctx.Abort()
+			return
+		}
+
+		if contains(claims.Roles, roles) && !claims.HasAuthMethod(auth.AuthMethodMFA) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "multi-factor authentication required"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects users none of whose roles grant the
+// permission and machine clients none of whose scopes do. It must run
+// after JwtAuth or JwtAuthPrincipal.
+func RequirePermission(roleService services.RoleService, permission string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ctx.MustGet("claims").(*auth.Claims)
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			ctx.Abort()
+			return
+		}
+
+		allowed, err := roleService.Can(claims, permission)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+
+		if !allowed {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "forbidden, missing permission " + permission})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
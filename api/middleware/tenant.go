@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant selects the organization named by the X-Organization-ID header
+// as the active tenant of the request, rejecting users that are not a
+// member of it. It must run after JwtAuth.
+func Tenant(organizationService services.OrganizationService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		organizationId := ctx.GetHeader(models.OrganizationHeader)
+		if organizationId == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing " + models.OrganizationHeader + " header"})
+			ctx.Abort()
+			return
+		}
+
+		membership, err := organizationService.FindMembership(organizationId, ctx.GetString("user_id"))
+		if err != nil {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set("organization_id", organizationId)
+		ctx.Set("membership", membership)
+		ctx.Next()
+	}
+}
+
+// RequireOrgRole rejects members without one of the organization roles.
+// It must run after Tenant.
+func RequireOrgRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		membership, ok := ctx.MustGet("membership").(models.Membership)
+		if !ok {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			ctx.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if membership.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "forbidden, missing required organization role"})
+		ctx.Abort()
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Marcel-MD/clean-api/config"
 	docs "github.com/Marcel-MD/clean-api/docs"
 	"github.com/Marcel-MD/clean-api/models"
+	"github.com/Marcel-MD/clean-api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -15,7 +16,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func NewServer(cfg config.Config, userController controllers.UserController) *http.Server {
+func NewServer(cfg config.Config, tokenService services.TokenService, roleService services.RoleService, organizationService services.OrganizationService, keyController controllers.KeyController, userController controllers.UserController, mfaController controllers.MfaController, passkeyController controllers.PasskeyController, apiKeyController controllers.ApiKeyController, sessionController controllers.SessionController, passwordController controllers.PasswordController, magicLinkController controllers.MagicLinkController, lockoutController controllers.LockoutController, impersonationController controllers.ImpersonationController, auditController controllers.AuditController, oidcController controllers.OidcController, oauthController controllers.OAuthController, roleController controllers.RoleController, organizationController controllers.OrganizationController, invitationController controllers.InvitationController, groupController controllers.GroupController) *http.Server {
 	log.Info().Msg("Creating new server")
 
 	e := gin.Default()
@@ -25,7 +26,25 @@ func NewServer(cfg config.Config, userController controllers.UserController) *ht
 
 	// Register routes
 	registerSwaggerRoutes(r, cfg)
-	registerUserRoutes(r, cfg, userController)
+	registerKeyRoutes(r, keyController)
+	registerUserRoutes(r, cfg, tokenService, roleService, userController)
+	registerMfaRoutes(r, tokenService, mfaController)
+	registerPasskeyRoutes(r, tokenService, passkeyController)
+	registerApiKeyRoutes(r, tokenService, apiKeyController)
+	registerSessionRoutes(r, tokenService, sessionController)
+	registerPasswordRoutes(r, tokenService, passwordController)
+	registerMagicLinkRoutes(r, magicLinkController)
+	registerLockoutRoutes(r, cfg, tokenService, roleService, lockoutController)
+	registerImpersonationRoutes(r, cfg, tokenService, roleService, impersonationController)
+	registerAuditRoutes(r, cfg, tokenService, roleService, auditController)
+	registerOidcRoutes(r, oidcController)
+	if oauthController != nil {
+		registerOAuthRoutes(r, cfg, tokenService, roleService, oauthController)
+	}
+	registerRoleRoutes(r, cfg, tokenService, roleService, roleController)
+	registerOrganizationRoutes(r, tokenService, organizationService, organizationController)
+	registerInvitationRoutes(r, tokenService, organizationService, invitationController)
+	registerGroupRoutes(r, cfg, tokenService, roleService, groupController)
 
 	return &http.Server{
 		Addr:    ":" + cfg.Port,
@@ -42,19 +61,194 @@ func registerSwaggerRoutes(router *gin.RouterGroup, cfg config.Config) {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 }
 
-func registerUserRoutes(router *gin.RouterGroup, cfg config.Config, c controllers.UserController) {
+func registerKeyRoutes(router *gin.RouterGroup, c controllers.KeyController) {
+	r := router.Group("/.well-known")
+	r.GET("/jwks.json", c.JWKS)
+}
+
+func registerUserRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.UserController) {
 	r := router.Group("/users")
 	r.POST("/register", c.Register)
 	r.POST("/login", c.Login)
 	r.POST("/refresh", c.RefreshToken)
-	r.GET("/", c.GetAll)
-	r.GET("/:id", c.GetById)
+	r.GET("/verify", c.Verify)
+	r.POST("/verify/resend", c.ResendVerification)
 
-	pr := r.Use(middleware.JwtAuth(cfg.AccessTokenSecret))
+	pr := r.Use(middleware.JwtAuth(tokenService))
 	pr.GET("/current", c.GetCurrent)
+	pr.POST("/logout", middleware.RejectApiKeys(), middleware.RejectImpersonation(), c.Logout)
+	pr.POST("/logout/all", middleware.RejectApiKeys(), middleware.RejectImpersonation(), c.LogoutAll)
+
+	// Separate groups, the user routes above reject machine clients. Who
+	// may read or delete which user is decided by the authorization policy.
+	rr := router.Group("/users")
+	rr.Use(middleware.JwtAuthPrincipal(tokenService, nil, models.ApiScopeRead))
+	rr.GET("/", c.GetAll)
+	rr.GET("/:id", c.GetById)
 
-	ar := r.Use(middleware.JwtAuthRoles(cfg.AccessTokenSecret, []string{models.AdminRole}))
+	ar := router.Group("/users")
+	ar.Use(middleware.JwtAuthPrincipal(tokenService, nil, models.ApiScopeAdmin), middleware.RequireMfa(cfg.MfaRequiredRoles))
 	ar.DELETE("/:id", c.Delete)
-	ar.PATCH("/:id/roles/:role", c.AssignRole)
-	ar.DELETE("/:id/roles/:role", c.RemoveRole)
+	ar.PATCH("/:id/roles/:role", middleware.RequirePermission(roleService, models.PermissionUsersRoles), c.AssignRole)
+	ar.DELETE("/:id/roles/:role", middleware.RequirePermission(roleService, models.PermissionUsersRoles), c.RemoveRole)
+}
+
+func registerMfaRoutes(router *gin.RouterGroup, tokenService services.TokenService, c controllers.MfaController) {
+	r := router.Group("/users")
+	r.POST("/login/mfa", c.LoginMfa)
+
+	pr := r.Use(middleware.JwtAuth(tokenService), middleware.RejectApiKeys(), middleware.RejectImpersonation())
+	pr.POST("/current/mfa/totp", c.EnrollTotp)
+	pr.POST("/current/mfa/totp/confirm", c.ConfirmTotp)
+	pr.DELETE("/current/mfa/totp", c.DisableTotp)
+	pr.POST("/current/mfa/recovery-codes", c.RegenerateRecoveryCodes)
+}
+
+func registerPasskeyRoutes(router *gin.RouterGroup, tokenService services.TokenService, c controllers.PasskeyController) {
+	r := router.Group("/users")
+	r.POST("/login/passkey/begin", c.BeginLogin)
+	r.POST("/login/passkey", c.FinishLogin)
+
+	pr := r.Use(middleware.JwtAuth(tokenService), middleware.RejectApiKeys(), middleware.RejectImpersonation())
+	pr.GET("/current/passkeys", c.GetAll)
+	pr.POST("/current/passkeys/begin", c.BeginRegistration)
+	pr.POST("/current/passkeys", c.FinishRegistration)
+	pr.DELETE("/current/passkeys/:id", c.Delete)
+}
+
+func registerApiKeyRoutes(router *gin.RouterGroup, tokenService services.TokenService, c controllers.ApiKeyController) {
+	r := router.Group("/users/current/api-keys")
+	r.Use(middleware.JwtAuth(tokenService), middleware.RejectApiKeys(), middleware.RejectImpersonation())
+	r.GET("/", c.GetAll)
+	r.POST("/", c.Create)
+	r.DELETE("/:id", c.Revoke)
+}
+
+func registerSessionRoutes(router *gin.RouterGroup, tokenService services.TokenService, c controllers.SessionController) {
+	r := router.Group("/users/current/sessions")
+	r.Use(middleware.JwtAuth(tokenService), middleware.RejectApiKeys(), middleware.RejectImpersonation())
+	r.GET("/", c.GetAll)
+	r.DELETE("/", c.RevokeOthers)
+	r.DELETE("/:id", c.Revoke)
+}
+
+func registerPasswordRoutes(router *gin.RouterGroup, tokenService services.TokenService, c controllers.PasswordController) {
+	r := router.Group("/users")
+	r.POST("/password/forgot", c.Forgot)
+	r.POST("/password/reset", c.Reset)
+
+	pr := r.Use(middleware.JwtAuth(tokenService), middleware.RejectApiKeys(), middleware.RejectImpersonation())
+	pr.POST("/current/password", c.Change)
+}
+
+func registerMagicLinkRoutes(router *gin.RouterGroup, c controllers.MagicLinkController) {
+	r := router.Group("/users/login/magic")
+	r.POST("", c.Request)
+	r.GET("/callback", c.Callback)
+}
+
+func registerLockoutRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.LockoutController) {
+	r := router.Group("/users/lockouts")
+	r.Use(middleware.JwtAuthPrincipal(tokenService, nil, models.ApiScopeAdmin), middleware.RequirePermission(roleService, models.PermissionLockoutsManage), middleware.RequireMfa(cfg.MfaRequiredRoles))
+	r.GET("/", c.GetLocked)
+	r.DELETE("/:key", c.Unlock)
+}
+
+func registerImpersonationRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.ImpersonationController) {
+	r := router.Group("/users")
+	r.POST("/impersonate/stop", middleware.JwtAuth(tokenService), c.Stop)
+
+	ar := r.Use(middleware.JwtAuth(tokenService), middleware.RequirePermission(roleService, models.PermissionUsersImpersonate), middleware.RequireMfa(cfg.MfaRequiredRoles), middleware.RejectApiKeys())
+	ar.POST("/:id/impersonate", c.Start)
+}
+
+func registerAuditRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.AuditController) {
+	r := router.Group("/audit-events")
+	r.Use(middleware.JwtAuthPrincipal(tokenService, nil, models.ApiScopeAdmin), middleware.RequirePermission(roleService, models.PermissionAuditRead), middleware.RequireMfa(cfg.MfaRequiredRoles))
+	r.GET("/", c.GetAll)
+}
+
+func registerOidcRoutes(router *gin.RouterGroup, c controllers.OidcController) {
+	r := router.Group("/users/oidc/:provider")
+	r.GET("/login", c.Login)
+	r.GET("/callback", c.Callback)
+}
+
+func registerOAuthRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.OAuthController) {
+	router.GET("/.well-known/openid-configuration", c.Configuration)
+
+	r := router.Group("/oauth")
+	r.POST("/token", c.Token)
+	r.POST("/introspect", c.Introspect)
+
+	// Separate group, userinfo is the one route OAuth clients may call
+	// with the tokens they were issued for a user.
+	ur := router.Group("/oauth")
+	ur.Use(middleware.JwtAuthDelegated(tokenService, models.ScopeOpenID))
+	ur.GET("/userinfo", c.UserInfo)
+	ur.POST("/userinfo", c.UserInfo)
+
+	pr := r.Use(middleware.JwtAuth(tokenService))
+	pr.GET("/authorize", middleware.RejectApiKeys(), middleware.RejectImpersonation(), c.Authorize)
+	pr.POST("/authorize", middleware.RejectApiKeys(), middleware.RejectImpersonation(), c.Decide)
+
+	ar := r.Use(middleware.RequirePermission(roleService, models.PermissionOAuthClients), middleware.RequireMfa(cfg.MfaRequiredRoles))
+	ar.POST("/clients", c.CreateClient)
+	ar.POST("/clients/machine", c.CreateMachineClient)
+	ar.GET("/clients", c.GetAllClients)
+	ar.DELETE("/clients/:id", c.DeleteClient)
+}
+
+func registerRoleRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.RoleController) {
+	r := router.Group("/roles")
+	r.Use(middleware.JwtAuth(tokenService), middleware.RequirePermission(roleService, models.PermissionRolesManage), middleware.RequireMfa(cfg.MfaRequiredRoles))
+	r.GET("/", c.GetAll)
+	r.POST("/", c.Create)
+	r.GET("/permissions", c.GetPermissions)
+	r.GET("/:name", c.GetByName)
+	r.PUT("/:name", c.Update)
+	r.DELETE("/:name", c.Delete)
+	r.PUT("/:name/permissions/:permission", c.AddPermission)
+	r.DELETE("/:name/permissions/:permission", c.RemovePermission)
+}
+
+func registerOrganizationRoutes(router *gin.RouterGroup, tokenService services.TokenService, organizationService services.OrganizationService, c controllers.OrganizationController) {
+	r := router.Group("/orgs")
+	r.Use(middleware.JwtAuth(tokenService))
+	r.GET("/", c.GetAll)
+	r.POST("/", c.Create)
+
+	tr := r.Group("/current")
+	tr.Use(middleware.Tenant(organizationService))
+	tr.GET("", c.GetCurrent)
+	tr.GET("/members", c.GetMembers)
+	tr.PATCH("/members/:id", middleware.RequireOrgRole(models.OrgRoleOwner, models.OrgRoleAdmin), c.UpdateMember)
+	tr.DELETE("/members/:id", c.RemoveMember)
+}
+
+func registerInvitationRoutes(router *gin.RouterGroup, tokenService services.TokenService, organizationService services.OrganizationService, c controllers.InvitationController) {
+	r := router.Group("/invitations")
+	r.GET("", c.Preview)
+	r.POST("/accept", c.Accept)
+	r.POST("/decline", c.Decline)
+
+	ar := router.Group("/orgs/current/invitations")
+	ar.Use(middleware.JwtAuth(tokenService), middleware.Tenant(organizationService), middleware.RequireOrgRole(models.OrgRoleOwner, models.OrgRoleAdmin))
+	ar.GET("/", c.GetAll)
+	ar.POST("/", c.Create)
+	ar.POST("/:id/resend", c.Resend)
+	ar.DELETE("/:id", c.Revoke)
+}
+
+func registerGroupRoutes(router *gin.RouterGroup, cfg config.Config, tokenService services.TokenService, roleService services.RoleService, c controllers.GroupController) {
+	r := router.Group("/groups")
+	r.Use(middleware.JwtAuthPrincipal(tokenService, nil, models.ApiScopeAdmin), middleware.RequirePermission(roleService, models.PermissionGroupsManage), middleware.RequireMfa(cfg.MfaRequiredRoles))
+	r.GET("/", c.GetAll)
+	r.POST("/", c.Create)
+	r.GET("/:id", c.GetById)
+	r.PUT("/:id", c.Update)
+	r.DELETE("/:id", c.Delete)
+	r.GET("/:id/members", c.GetMembers)
+	r.PUT("/:id/members/:userId", c.AddMember)
+	r.DELETE("/:id/members/:userId", c.RemoveMember)
 }
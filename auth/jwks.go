@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK is the public part of a key as described in RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWK(key *Key) (JWK, error) {
+	jwk := JWK{
+		Kid: key.ID,
+		Use: "sig",
+		Alg: key.Method.Alg(),
+	}
+
+	switch k := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encode(k.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = encode(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encode(k)
+	default:
+		return jwk, fmt.Errorf("unsupported public key type: %T", k)
+	}
+
+	return jwk, nil
+}
+
+// PublicKey converts the JWK back to an RSA, ECDSA or Ed25519 public key.
+func (j JWK) PublicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode(j.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", j.Crv)
+		}
+
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode(j.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", j.Crv)
+		}
+
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %s", j.Kty)
+}
+
+// Thumbprint computes the RFC 7638 thumbprint of the key.
+func (j JWK) Thumbprint() string {
+	var members string
+
+	switch j.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, j.Crv, j.X, j.Y)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, j.Crv, j.X)
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return encode(sum[:])
+}
+
+// JWKS returns the public keys of the set. HMAC keys are never included.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, k := range ks.Keys() {
+		if k.ID == "" {
+			continue
+		}
+
+		jwk, err := NewJWK(k)
+		if err != nil {
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -1,106 +1,223 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func GenerateAccessToken(userId string, roles []string, lifespan time.Duration, secret string) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["user_id"] = userId
-	claims["roles"] = roles
-	claims["exp"] = time.Now().Add(lifespan).Unix()
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeMfa     = "mfa"
+	TokenTypeApiKey  = "api_key"
+	TokenTypeVerify  = "verify_email"
+)
 
-	token, err := t.SignedString([]byte(secret))
-	if err != nil {
-		return "", err
-	}
+// Authentication method references as registered in RFC 8176.
+const (
+	AuthMethodPassword  = "pwd"
+	AuthMethodOTP       = "otp"
+	AuthMethodMFA       = "mfa"
+	AuthMethodFederated = "fed"
+	AuthMethodHardware  = "hwk"
+)
+
+// Claims is the claim set of every token issued by the API. The subject is
+// either a user or, for the client credentials grant, an OAuth client.
+type Claims struct {
+	jwt.RegisteredClaims
+
+	Authorized bool     `json:"authorized"`
+	UserID     string   `json:"user_id"`
+	Roles      []string `json:"roles,omitempty"`
+	Type       string   `json:"token_type"`
+
+	EmailVerified bool `json:"email_verified,omitempty"`
+
+	AuthMethods []string `json:"amr,omitempty"`
 
-	return token, nil
+	// AuthTime is when the user last signed in, refreshed tokens keep it.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+
+	// SessionID is the device session the token belongs to, tokens issued
+	// to OAuth clients have none.
+	SessionID string `json:"sid,omitempty"`
+
+	// Set on tokens issued to OAuth clients.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	// Actor is set when an admin acts as the user, see RFC 8693.
+	Actor *Actor `json:"act,omitempty"`
+}
+
+// Actor identifies who is acting on behalf of the subject of a token.
+type Actor struct {
+	Subject string `json:"sub"`
 }
 
-func GenerateRefreshToken(userId string, lifespan time.Duration, secret string) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["user_id"] = userId
-	claims["exp"] = time.Now().Add(lifespan).Unix()
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// IsClient reports whether the token was issued to a machine client acting
+// on its own behalf rather than for a user.
+func (c *Claims) IsClient() bool {
+	return c.UserID == "" && c.ClientID != ""
+}
 
-	token, err := t.SignedString([]byte(secret))
-	if err != nil {
-		return "", err
+// Delegated reports whether the token was issued to an OAuth client acting
+// for the user.
+func (c *Claims) Delegated() bool {
+	return c.UserID != "" && c.ClientID != ""
+}
+
+// Impersonated reports whether the token was issued to someone acting as
+// the user.
+func (c *Claims) Impersonated() bool {
+	return c.Actor != nil
+}
+
+func (c *Claims) HasAuthMethod(method string) bool {
+	for _, m := range c.AuthMethods {
+		if m == method {
+			return true
+		}
 	}
 
-	return token, nil
+	return false
 }
 
-func GenerateTokenPair(userId string, roles []string, lifespan time.Duration, accessSecret, refreshSecret string) (string, string, error) {
-	accessToken, err := GenerateAccessToken(userId, roles, lifespan, accessSecret)
-	if err != nil {
-		return "", "", err
+// Validate is called by the parser after the registered claims were checked.
+func (c *Claims) Validate() error {
+	if c.ExpiresAt == nil {
+		return errors.New("token has no expiration time")
 	}
 
-	refreshToken, err := GenerateRefreshToken(userId, lifespan, refreshSecret)
-	if err != nil {
-		return "", "", err
+	// Revocation compares the issue time against the cutoff.
+	if c.IssuedAt == nil {
+		return errors.New("token has no issued at time")
 	}
 
-	return accessToken, refreshToken, nil
+	switch {
+	case c.UserID != "":
+		if c.Subject != c.UserID {
+			return errors.New("token has an invalid subject")
+		}
+	case c.ClientID != "":
+		if c.Subject != c.ClientID {
+			return errors.New("token has an invalid subject")
+		}
+	default:
+		return errors.New("token has no subject")
+	}
+
+	if c.Type == "" {
+		return errors.New("token has no type")
+	}
+
+	return nil
 }
 
-func Validate(tokenString string, secret string) (*jwt.Token, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+// TokenOptions are the registered claims set on issued tokens and checked
+// when validating them.
+type TokenOptions struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
 
-	if err != nil {
-		return nil, err
+func NewClaims(userId string, roles []string, tokenType string, lifespan time.Duration, opts TokenOptions) *Claims {
+	now := time.Now()
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   userId,
+			Issuer:    opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifespan)),
+		},
+		Authorized: true,
+		UserID:     userId,
+		Roles:      roles,
+		Type:       tokenType,
+	}
+
+	if opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{opts.Audience}
 	}
 
-	return token, nil
+	return claims
 }
 
-func ExtractId(token *jwt.Token) (string, error) {
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, ok := claims["user_id"].(string)
-		if !ok {
-			return "0", fmt.Errorf("invalid user_id: %v", claims["user_id"])
-		}
+// NewClientClaims are the claims of an access token issued to a machine
+// client through the client credentials grant.
+func NewClientClaims(clientId, scope string, lifespan time.Duration, opts TokenOptions) *Claims {
+	claims := NewClaims("", nil, TokenTypeAccess, lifespan, opts)
+	claims.Subject = clientId
+	claims.ClientID = clientId
+	claims.Scope = scope
+
+	return claims
+}
 
-		return uid, nil
+func GenerateAccessToken(userId string, roles []string, lifespan time.Duration, keys *KeySet, opts TokenOptions) (string, error) {
+	return keys.Sign(NewClaims(userId, roles, TokenTypeAccess, lifespan, opts))
+}
+
+func GenerateRefreshToken(userId string, lifespan time.Duration, keys *KeySet, opts TokenOptions) (string, error) {
+	return keys.Sign(NewClaims(userId, nil, TokenTypeRefresh, lifespan, opts))
+}
+
+// GenerateTokenPair issues an access and refresh token that both belong to
+// the session.
+func GenerateTokenPair(userId, sessionId string, roles []string, accessLifespan, refreshLifespan time.Duration, accessKeys, refreshKeys *KeySet, opts TokenOptions) (string, string, error) {
+	accessClaims := NewClaims(userId, roles, TokenTypeAccess, accessLifespan, opts)
+	accessClaims.SessionID = sessionId
+
+	accessToken, err := accessKeys.Sign(accessClaims)
+	if err != nil {
+		return "", "", err
 	}
 
-	return "0", nil
+	refreshClaims := NewClaims(userId, nil, TokenTypeRefresh, refreshLifespan, opts)
+	refreshClaims.SessionID = sessionId
+
+	refreshToken, err := refreshKeys.Sign(refreshClaims)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
 }
 
-func ExtractIdAndRoles(token *jwt.Token) (string, []string, error) {
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, ok := claims["user_id"].(string)
-		if !ok {
-			return "0", []string{}, fmt.Errorf("invalid user_id: %v", claims["user_id"])
-		}
+// Validate parses the token and checks its signature, time based claims,
+// issuer, audience and that it is of the expected type.
+func Validate(tokenString string, tokenType string, keys *KeySet, opts TokenOptions) (*Claims, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithIssuedAt(),
+	}
 
-		roles, ok := claims["roles"].([]interface{})
-		if !ok {
-			return uid, []string{}, fmt.Errorf("invalid roles: %v", claims["roles"])
-		}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
 
-		rolesStr := make([]string, len(roles))
-		for i, v := range roles {
-			rolesStr[i] = fmt.Sprint(v)
-		}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc, parserOpts...)
+	if err != nil {
+		return nil, err
+	}
 
-		return uid, rolesStr, nil
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("unexpected token type: %s", claims.Type)
 	}
 
-	return "0", []string{}, nil
+	return claims, nil
 }
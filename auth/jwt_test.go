@@ -7,13 +7,20 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var testOpts = TokenOptions{
+	Issuer:   "clean-api",
+	Audience: "clean-api",
+	Leeway:   30 * time.Second,
+}
+
 func TestGenerateJWT(t *testing.T) {
 	userId := "123"
 	roles := []string{"admin", "user"}
 	lifespan := time.Hour
 	secret := "mysecret"
+	keys := NewSecretKeySet(secret)
 
-	token, err := GenerateAccessToken(userId, roles, lifespan, secret)
+	token, err := GenerateAccessToken(userId, roles, lifespan, keys, testOpts)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -38,73 +45,233 @@ func TestGenerateJWT(t *testing.T) {
 		t.Errorf("token authorization claim is incorrect")
 	}
 
-	if claims["user_id"].(string) != userId {
+	if claims["user_id"].(string) != userId || claims["sub"].(string) != userId {
 		t.Errorf("token user_id claim is incorrect")
 	}
 
 	if len(claims["roles"].([]interface{})) != len(roles) {
 		t.Errorf("token roles claim is incorrect")
 	}
+
+	if claims["iss"].(string) != testOpts.Issuer {
+		t.Errorf("token iss claim is incorrect")
+	}
+
+	if claims["token_type"].(string) != TokenTypeAccess {
+		t.Errorf("token token_type claim is incorrect")
+	}
+
+	for _, c := range []string{"jti", "iat", "nbf", "exp", "aud"} {
+		if _, ok := claims[c]; !ok {
+			t.Errorf("token %s claim is missing", c)
+		}
+	}
 }
 
-func TestExtractId(t *testing.T) {
+func TestValidate(t *testing.T) {
 	userId := "123"
 	roles := []string{"admin", "user"}
 	lifespan := time.Hour
 	secret := "mysecret"
+	keys := NewSecretKeySet(secret)
 
-	token, err := GenerateAccessToken(userId, roles, lifespan, secret)
+	token, err := GenerateAccessToken(userId, roles, lifespan, keys, testOpts)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	parsedToken, err := Validate(token, secret)
+	claims, err := Validate(token, TokenTypeAccess, keys, testOpts)
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if claims.UserID != userId {
+		t.Errorf("extracted user_id is incorrect")
+	}
+
+	if len(claims.Roles) != len(roles) {
+		t.Errorf("extracted roles are incorrect")
+	}
+
+	for i, v := range roles {
+		if claims.Roles[i] != v {
+			t.Errorf("extracted roles are incorrect")
+		}
+	}
+
+	if claims.ExpiresAt.Sub(claims.IssuedAt.Time) != lifespan {
+		t.Errorf("extracted iat and exp are incorrect")
 	}
+}
+
+func TestValidateRejectsWrongTokenType(t *testing.T) {
+	keys := NewSecretKeySet("mysecret")
 
-	extractedId, err := ExtractId(parsedToken)
+	access, refresh, err := GenerateTokenPair("123", "session", []string{"user"}, time.Hour, time.Hour, keys, keys, testOpts)
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if extractedId != userId {
-		t.Errorf("extracted user_id is incorrect")
+	if _, err := Validate(access, TokenTypeRefresh, keys, testOpts); err == nil {
+		t.Errorf("access token must not be accepted as a refresh token")
+	}
+
+	if _, err := Validate(refresh, TokenTypeAccess, keys, testOpts); err == nil {
+		t.Errorf("refresh token must not be accepted as an access token")
+	}
+}
+
+func TestGenerateTokenPairCarriesSessionId(t *testing.T) {
+	keys := NewSecretKeySet("mysecret")
+
+	access, refresh, err := GenerateTokenPair("123", "session", []string{"user"}, time.Hour, time.Hour, keys, keys, testOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for token, tokenType := range map[string]string{access: TokenTypeAccess, refresh: TokenTypeRefresh} {
+		claims, err := Validate(token, tokenType, keys, testOpts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if claims.SessionID != "session" {
+			t.Errorf("%s token sid = %q, want %q", tokenType, claims.SessionID, "session")
+		}
+	}
+}
+
+func TestValidateClientClaims(t *testing.T) {
+	keys := NewSecretKeySet("mysecret")
+
+	token, err := keys.Sign(NewClientClaims("client", "read", time.Hour, testOpts))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := Validate(token, TokenTypeAccess, keys, testOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !claims.IsClient() || claims.Subject != "client" || claims.UserID != "" {
+		t.Errorf("expected client claims, got sub %q user_id %q", claims.Subject, claims.UserID)
+	}
+
+	spoofed := NewClientClaims("client", "read", time.Hour, testOpts)
+	spoofed.Subject = "123"
+	token, err = keys.Sign(spoofed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err == nil {
+		t.Errorf("client token with a foreign subject must be rejected")
+	}
+}
+
+func TestValidateIssuerAndAudience(t *testing.T) {
+	keys := NewSecretKeySet("mysecret")
+
+	token, err := GenerateAccessToken("123", []string{"user"}, time.Hour, keys, testOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts TokenOptions
+	}{
+		{"issuer", TokenOptions{Issuer: "other", Audience: testOpts.Audience}},
+		{"audience", TokenOptions{Issuer: testOpts.Issuer, Audience: "other"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := Validate(token, TokenTypeAccess, keys, tt.opts); err == nil {
+			t.Errorf("%s: token with a different %s must not validate", tt.name, tt.name)
+		}
+	}
+}
+
+func TestValidateLeeway(t *testing.T) {
+	keys := NewSecretKeySet("mysecret")
+
+	claims := NewClaims("123", []string{"user"}, TokenTypeAccess, time.Hour, testOpts)
+	claims.NotBefore = jwt.NewNumericDate(time.Now().Add(10 * time.Second))
+	claims.IssuedAt = claims.NotBefore
+
+	token, err := keys.Sign(claims)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err != nil {
+		t.Errorf("token within leeway should validate: %v", err)
+	}
+
+	strict := testOpts
+	strict.Leeway = 0
+	if _, err := Validate(token, TokenTypeAccess, keys, strict); err == nil {
+		t.Errorf("token not yet valid should not validate without leeway")
 	}
 }
 
-func TestExtractIdAndRoles(t *testing.T) {
+func TestGenerateRefreshTokenIsUnique(t *testing.T) {
 	userId := "123"
-	roles := []string{"admin", "user"}
 	lifespan := time.Hour
 	secret := "mysecret"
+	keys := NewSecretKeySet(secret)
 
-	token, err := GenerateAccessToken(userId, roles, lifespan, secret)
+	first, err := GenerateRefreshToken(userId, lifespan, keys, testOpts)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	parsedToken, err := Validate(token, secret)
+	second, err := GenerateRefreshToken(userId, lifespan, keys, testOpts)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	extractedId, extractedRoles, err := ExtractIdAndRoles(parsedToken)
+	if first == second {
+		t.Errorf("refresh tokens issued in the same second must differ")
+	}
+
+	if HashToken(first) == HashToken(second) {
+		t.Errorf("refresh token hashes must differ")
+	}
+}
+
+func TestValidateRejectsMissingIssuedAt(t *testing.T) {
+	keys := NewSecretKeySet("mysecret")
+
+	claims := NewClaims("123", nil, TokenTypeAccess, time.Hour, testOpts)
+	claims.IssuedAt = nil
+
+	token, err := keys.Sign(claims)
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if extractedId != userId {
-		t.Errorf("extracted user_id is incorrect")
+	if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err == nil {
+		t.Errorf("token without iat must be rejected")
 	}
+}
 
-	if len(extractedRoles) != len(roles) {
-		t.Errorf("extracted roles are incorrect")
+func TestDelegatedClaims(t *testing.T) {
+	delegated := NewClaims("123", nil, TokenTypeAccess, time.Hour, testOpts)
+	delegated.ClientID = "client"
+
+	if !delegated.Delegated() || delegated.IsClient() {
+		t.Errorf("token of a client acting for a user must be delegated")
 	}
 
-	for i, v := range roles {
-		if extractedRoles[i] != v {
-			t.Errorf("extracted roles are incorrect")
-		}
+	machine := NewClientClaims("client", "read", time.Hour, testOpts)
+	if machine.Delegated() {
+		t.Errorf("token of a machine client must not be delegated")
+	}
+
+	user := NewClaims("123", nil, TokenTypeAccess, time.Hour, testOpts)
+	if user.Delegated() {
+		t.Errorf("token of a user must not be delegated")
 	}
 }
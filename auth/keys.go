@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is a single signing or verification key. Keys loaded from a public key
+// can only verify tokens.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	private interface{}
+	public  interface{}
+}
+
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// Symmetric reports whether the key is an HMAC secret, which can not be
+// verified by anyone but its holder.
+func (k *Key) Symmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// NewSecretKey creates an HMAC key. HMAC keys have no ID and are never
+// published in a JWKS.
+func NewSecretKey(secret string) *Key {
+	return &Key{
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// NewKey wraps an RSA, ECDSA or Ed25519 private or public key. The key ID is
+// its RFC 7638 thumbprint.
+func NewKey(k interface{}) (*Key, error) {
+	key := &Key{}
+
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case *ecdsa.PrivateKey:
+		key.Method, key.private, key.public = ecdsaMethod(k.Curve), k, &k.PublicKey
+	case *ecdsa.PublicKey:
+		key.Method, key.public = ecdsaMethod(k.Curve), k
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", k)
+	}
+
+	if key.Method == nil {
+		return nil, errors.New("unsupported elliptic curve")
+	}
+
+	jwk, err := NewJWK(key)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.Thumbprint()
+
+	return key, nil
+}
+
+// GenerateKey creates a new private key for the given algorithm.
+func GenerateKey(alg string) (*Key, error) {
+	var k interface{}
+	var err error
+
+	switch alg {
+	case AlgRS256:
+		k, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		k, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, k, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(k)
+}
+
+// LoadKeyFile reads a PEM encoded private or public key.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	var k interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		k, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		k, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		k, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(k)
+}
+
+// WriteKeyFile stores the private key as a PKCS #8 PEM file.
+func WriteKeyFile(path string, key *Key) error {
+	if !key.CanSign() {
+		return errors.New("only private keys can be written")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(path, data, 0600)
+}
+
+// KeySet holds the key used to sign new tokens and every key that is still
+// accepted when verifying them. It is safe for concurrent use so keys can be
+// rotated while the server is running.
+type KeySet struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    []*Key
+}
+
+// NewSecretKeySet creates a key set with a single HMAC key.
+func NewSecretKeySet(secret string) *KeySet {
+	key := NewSecretKey(secret)
+	return &KeySet{signing: key, keys: []*Key{key}}
+}
+
+// NewKeySet creates a key set that signs with the given key and verifies
+// with all keys.
+func NewKeySet(signing *Key, keys []*Key) (*KeySet, error) {
+	ks := &KeySet{}
+	return ks, ks.Replace(signing, keys)
+}
+
+// Replace swaps the keys of the set.
+func (ks *KeySet) Replace(signing *Key, keys []*Key) error {
+	if signing == nil || !signing.CanSign() {
+		return errors.New("signing key must be a private key")
+	}
+
+	found := false
+	for _, k := range keys {
+		if k.ID == signing.ID {
+			found = true
+		}
+	}
+
+	if !found {
+		keys = append(keys, signing)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.signing = signing
+	ks.keys = keys
+
+	return nil
+}
+
+// Sign signs the claims with the current signing key and sets its ID in the
+// kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	key := ks.signing
+	ks.mu.RUnlock()
+
+	t := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		t.Header["kid"] = key.ID
+	}
+
+	return t.SignedString(key.private)
+}
+
+// Keyfunc resolves the verification key of a parsed token by its kid header.
+// The algorithm of the token must match the algorithm of the key.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, k := range ks.keys {
+		if k.ID != kid {
+			continue
+		}
+
+		if k.Method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return k.public, nil
+	}
+
+	return nil, fmt.Errorf("unknown key id: %q", kid)
+}
+
+// Keys returns a copy of the verification keys.
+func (ks *KeySet) Keys() []*Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]*Key, len(ks.keys))
+	copy(keys, ks.keys)
+
+	return keys
+}
+
+// SigningKey returns the key currently used to sign tokens.
+func (ks *KeySet) SigningKey() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.signing
+}
+
+func ecdsaMethod(curve elliptic.Curve) jwt.SigningMethod {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256
+	case elliptic.P384():
+		return jwt.SigningMethodES384
+	case elliptic.P521():
+		return jwt.SigningMethodES512
+	}
+
+	return nil
+}
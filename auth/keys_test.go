@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSignAndValidateAsymmetric(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgES256, AlgEdDSA} {
+		key, err := GenerateKey(alg)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", alg, err)
+		}
+
+		keys, err := NewKeySet(key, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", alg, err)
+		}
+
+		token, err := GenerateAccessToken("123", []string{"user"}, time.Hour, keys, testOpts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", alg, err)
+		}
+
+		if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err != nil {
+			t.Fatalf("%s: unexpected error: %v", alg, err)
+		}
+
+		parsedToken, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", alg, err)
+		}
+
+		if parsedToken.Header["kid"] != key.ID {
+			t.Errorf("%s: token kid header is incorrect", alg)
+		}
+
+		if parsedToken.Method.Alg() != alg {
+			t.Errorf("%s: token alg header is incorrect", alg)
+		}
+	}
+}
+
+func TestValidateRotatedKeys(t *testing.T) {
+	oldKey, _ := GenerateKey(AlgES256)
+	newKey, _ := GenerateKey(AlgES256)
+
+	keys, _ := NewKeySet(oldKey, nil)
+	token, err := GenerateAccessToken("123", []string{"user"}, time.Hour, keys, testOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = keys.Replace(newKey, []*Key{oldKey})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err != nil {
+		t.Errorf("token signed by a retired key should still validate: %v", err)
+	}
+
+	err = keys.Replace(newKey, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err == nil {
+		t.Errorf("token signed by a removed key should not validate")
+	}
+}
+
+func TestValidateRejectsAlgorithmConfusion(t *testing.T) {
+	key, _ := GenerateKey(AlgRS256)
+	keys, _ := NewKeySet(key, nil)
+
+	claims := NewClaims("123", []string{"admin"}, TokenTypeAccess, time.Hour, testOpts)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = key.ID
+
+	token, err := forged.SignedString([]byte("guessed"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Validate(token, TokenTypeAccess, keys, testOpts); err == nil {
+		t.Errorf("HMAC token must not validate against an RSA key")
+	}
+}
+
+func TestKeyFileRoundTrip(t *testing.T) {
+	key, _ := GenerateKey(AlgEdDSA)
+	path := filepath.Join(t.TempDir(), "key.pem")
+
+	if err := WriteKeyFile(path, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.ID != key.ID || !loaded.CanSign() {
+		t.Errorf("loaded key does not match written key")
+	}
+}
+
+func TestJWKSExcludesSecretKeys(t *testing.T) {
+	if len(NewSecretKeySet("mysecret").JWKS().Keys) != 0 {
+		t.Errorf("secret keys must not be published")
+	}
+
+	key, _ := GenerateKey(AlgES256)
+	keys, _ := NewKeySet(key, nil)
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != key.ID || jwks.Keys[0].Crv != "P-256" {
+		t.Errorf("published key set is incorrect: %+v", jwks)
+	}
+}
+
+func TestSymmetric(t *testing.T) {
+	if !NewSecretKey("mysecret").Symmetric() {
+		t.Errorf("secret keys must be symmetric")
+	}
+
+	for _, alg := range []string{AlgRS256, AlgES256, AlgEdDSA} {
+		key, err := GenerateKey(alg)
+		if err != nil {
+			t.Fatalf("GenerateKey(%s) error = %v", alg, err)
+		}
+
+		if key.Symmetric() {
+			t.Errorf("%s keys must not be symmetric", alg)
+		}
+	}
+}
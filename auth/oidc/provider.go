@@ -0,0 +1,299 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes an external OpenID Connect provider we act as a relying
+// party for.
+type Config struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+// Discovery is the subset of the provider metadata we rely on.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// IDTokenClaims are the claims of a verified ID token.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+
+	Nonce           string `json:"nonce,omitempty"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   bool   `json:"email_verified,omitempty"`
+	Name            string `json:"name,omitempty"`
+}
+
+// minKeyRefresh is how long the keys are kept before a token with an
+// unknown key ID may fetch them again, so forged key IDs can not make us
+// hammer the provider.
+const minKeyRefresh = time.Minute
+
+// Provider talks to a single OpenID Connect provider. Its metadata and keys
+// are fetched on first use and the keys are refreshed when a token is signed
+// with an unknown key ID, at most once per minKeyRefresh.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *Discovery
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL builds the authorization request URL using PKCE with S256.
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", auth.CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint.
+func (p *Provider) Exchange(code, codeVerifier string) (TokenResponse, error) {
+	var token TokenResponse
+
+	d, err := p.Discover()
+	if err != nil {
+		return token, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	err = p.do(req, &token)
+	if err != nil {
+		return token, err
+	}
+
+	if token.IDToken == "" {
+		return token, errors.New("token response has no id_token")
+	}
+
+	return token, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// the ID token.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDTokenClaims, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, p.keyfunc,
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("id token has no expiration time")
+	}
+
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, errors.New("id token authorized party mismatch")
+	}
+
+	return claims, nil
+}
+
+// Discover fetches the provider metadata once.
+func (p *Provider) Discover() (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Discovery
+	err = p.do(req, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected %s, got %s", p.cfg.Issuer, d.Issuer)
+	}
+
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksURI == "" {
+		return nil, errors.New("provider metadata is incomplete")
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *Provider) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := p.key(kid, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		key, err = p.key(kid, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+
+	k, err := auth.NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if k.Method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key, nil
+}
+
+func (p *Provider) key(kid string, refresh bool) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys == nil || (refresh && time.Since(p.keysFetchedAt) >= minKeyRefresh) {
+		req, err := http.NewRequest(http.MethodGet, p.discovery.JwksURI, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var jwks auth.JWKS
+		err = p.do(req, &jwks)
+		if err != nil {
+			return nil, err
+		}
+
+		p.keys = make(map[string]interface{})
+		for _, jwk := range jwks.Keys {
+			if jwk.Use != "" && jwk.Use != "sig" {
+				continue
+			}
+
+			key, err := jwk.PublicKey()
+			if err != nil {
+				continue
+			}
+
+			p.keys[jwk.Kid] = key
+		}
+		p.keysFetchedAt = time.Now()
+	}
+
+	return p.keys[kid], nil
+}
+
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: unexpected status %d", req.Method, req.URL.Path, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
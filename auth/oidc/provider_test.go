@@ -0,0 +1,255 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeProvider is an in-process OpenID Connect provider that issues a single
+// authorization code bound to the PKCE challenge and nonce it was given.
+type fakeProvider struct {
+	server *httptest.Server
+	keys   *auth.KeySet
+
+	clientID      string
+	code          string
+	codeChallenge string
+	nonce         string
+	audience      string
+	jwksFetches   atomic.Int32
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := auth.GenerateKey(auth.AlgRS256)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys, err := auth.NewKeySet(key, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f := &fakeProvider{keys: keys, clientID: "client", code: "code"}
+	f.audience = f.clientID
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                f.server.URL,
+			AuthorizationEndpoint: f.server.URL + "/authorize",
+			TokenEndpoint:         f.server.URL + "/token",
+			JwksURI:               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.jwksFetches.Add(1)
+		json.NewEncoder(w).Encode(f.keys.JWKS())
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != f.code || auth.CodeChallenge(r.Form.Get("code_verifier")) != f.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		idToken, err := f.keys.Sign(&IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    f.server.URL,
+				Subject:   "external-id",
+				Audience:  jwt.ClaimStrings{f.audience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			Nonce:         f.nonce,
+			Email:         "user@example.com",
+			EmailVerified: true,
+			Name:          "User",
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(TokenResponse{AccessToken: "access", TokenType: "Bearer", IDToken: idToken})
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeProvider) provider() *Provider {
+	return NewProvider(Config{
+		Name:        "fake",
+		Issuer:      f.server.URL,
+		ClientID:    f.clientID,
+		RedirectURL: "http://localhost/callback",
+	}, f.server.Client())
+}
+
+// authorize plays the user approving the request at the provider.
+func (f *fakeProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != f.clientID || q.Get("state") == "" {
+		t.Fatalf("authorization request is incorrect: %s", authURL)
+	}
+
+	f.codeChallenge = q.Get("code_challenge")
+	f.nonce = q.Get("nonce")
+}
+
+func TestLoginFlow(t *testing.T) {
+	f := newFakeProvider(t)
+	p := f.provider()
+
+	authURL, err := p.AuthCodeURL("state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.authorize(t, authURL)
+
+	token, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := p.VerifyIDToken(token.IDToken, "nonce")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if claims.Email != "user@example.com" || !claims.EmailVerified {
+		t.Errorf("id token claims are incorrect: %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	f := newFakeProvider(t)
+	p := f.provider()
+
+	authURL, err := p.AuthCodeURL("state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.authorize(t, authURL)
+
+	if _, err := p.Exchange(f.code, "other"); err == nil {
+		t.Errorf("exchange with a wrong code verifier should fail")
+	}
+}
+
+func TestVerifyIDTokenRejectsWrongNonce(t *testing.T) {
+	f := newFakeProvider(t)
+	p := f.provider()
+
+	authURL, _ := p.AuthCodeURL("state", "nonce", "verifier")
+	f.authorize(t, authURL)
+
+	token, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.VerifyIDToken(token.IDToken, "other"); err == nil {
+		t.Errorf("id token with a different nonce should not verify")
+	}
+}
+
+func TestVerifyIDTokenRejectsWrongAudience(t *testing.T) {
+	f := newFakeProvider(t)
+	f.audience = "someone-else"
+	p := f.provider()
+
+	authURL, _ := p.AuthCodeURL("state", "nonce", "verifier")
+	f.authorize(t, authURL)
+
+	token, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.VerifyIDToken(token.IDToken, "nonce"); err == nil {
+		t.Errorf("id token for another client should not verify")
+	}
+}
+
+func TestVerifyIDTokenRefreshesRotatedKeys(t *testing.T) {
+	f := newFakeProvider(t)
+	p := f.provider()
+
+	authURL, _ := p.AuthCodeURL("state", "nonce", "verifier")
+	f.authorize(t, authURL)
+
+	first, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.VerifyIDToken(first.IDToken, "nonce"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key, _ := auth.GenerateKey(auth.AlgES256)
+	f.keys.Replace(key, nil)
+	p.keysFetchedAt = p.keysFetchedAt.Add(-minKeyRefresh)
+
+	second, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.VerifyIDToken(second.IDToken, "nonce"); err != nil {
+		t.Errorf("id token signed by a new provider key should verify: %v", err)
+	}
+}
+
+func TestVerifyIDTokenLimitsKeyRefreshes(t *testing.T) {
+	f := newFakeProvider(t)
+	p := f.provider()
+
+	authURL, _ := p.AuthCodeURL("state", "nonce", "verifier")
+	f.authorize(t, authURL)
+
+	first, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.VerifyIDToken(first.IDToken, "nonce"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key, _ := auth.GenerateKey(auth.AlgES256)
+	f.keys.Replace(key, nil)
+
+	second, err := p.Exchange(f.code, "verifier")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.VerifyIDToken(second.IDToken, "nonce"); err == nil {
+			t.Errorf("id token with an unknown key id should not verify before the keys may be refreshed")
+		}
+	}
+
+	if fetches := f.jwksFetches.Load(); fetches != 1 {
+		t.Errorf("keys were fetched %d times, want 1", fetches)
+	}
+}
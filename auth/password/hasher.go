@@ -0,0 +1,186 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("password: unknown hash algorithm")
+	ErrMalformedHash    = errors.New("password: malformed hash")
+)
+
+// Argon2idParams are the cost parameters of argon2id, Memory is in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type HasherConfig struct {
+	Algorithm  string
+	Argon2id   Argon2idParams
+	BcryptCost int
+}
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes of every supported algorithm, so stored hashes can be upgraded
+// one login at a time.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+func NewHasher(cfg HasherConfig) (Hasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		p := cfg.Argon2id
+		if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || p.SaltLength < 8 || p.KeyLength < 16 {
+			return nil, errors.New("password: invalid argon2id parameters")
+		}
+	case AlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, errors.New("password: invalid bcrypt cost")
+		}
+	default:
+		return nil, ErrUnknownAlgorithm
+	}
+
+	return &hasher{cfg: cfg}, nil
+}
+
+type hasher struct {
+	cfg HasherConfig
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(b), err
+	}
+
+	p := h.cfg.Argon2id
+	salt := make([]byte, p.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return encodeArgon2id(p, salt, key), nil
+}
+
+func (h *hasher) Verify(password, encoded string) (bool, error) {
+	switch algorithm(encoded) {
+	case AlgorithmArgon2id:
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+
+	case AlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	return false, ErrUnknownAlgorithm
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or
+// other parameters than the configured ones.
+func (h *hasher) NeedsRehash(encoded string) bool {
+	if algorithm(encoded) != h.cfg.Algorithm {
+		return true
+	}
+
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.cfg.BcryptCost
+	}
+
+	p, salt, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	p.SaltLength = uint32(len(salt))
+	return p != h.cfg.Argon2id
+}
+
+func algorithm(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return AlgorithmBcrypt
+	}
+
+	return ""
+}
+
+// encodeArgon2id writes the PHC string format used by the reference
+// implementation: $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>.
+func encodeArgon2id(p Argon2idParams, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil || p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,101 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+var testArgon2id = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHasher(t *testing.T) {
+	argon, err := NewHasher(HasherConfig{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2id})
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+
+	bcryptHasher, err := NewHasher(HasherConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 4})
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+
+	argonHash, err := argon.Hash("Correct7Horse")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("Hash() = %q, want PHC argon2id string", argonHash)
+	}
+
+	bcryptHash, err := bcryptHasher.Hash("Correct7Horse")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	// Either hasher verifies hashes of both algorithms.
+	for _, h := range []Hasher{argon, bcryptHasher} {
+		for _, encoded := range []string{argonHash, bcryptHash} {
+			if ok, err := h.Verify("Correct7Horse", encoded); !ok || err != nil {
+				t.Errorf("Verify(%q) = %v, %v, want true", encoded, ok, err)
+			}
+			if ok, _ := h.Verify("wrong", encoded); ok {
+				t.Errorf("Verify(wrong, %q) = true, want false", encoded)
+			}
+		}
+	}
+
+	if _, err := argon.Verify("Correct7Horse", "plain"); err != ErrUnknownAlgorithm {
+		t.Errorf("Verify(plain) error = %v, want %v", err, ErrUnknownAlgorithm)
+	}
+	if _, err := argon.Verify("Correct7Horse", "$argon2id$v=19$m=1024$x$y"); err != ErrMalformedHash {
+		t.Errorf("Verify(malformed) error = %v, want %v", err, ErrMalformedHash)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon, _ := NewHasher(HasherConfig{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2id})
+	stronger := testArgon2id
+	stronger.Iterations = 2
+	argonStronger, _ := NewHasher(HasherConfig{Algorithm: AlgorithmArgon2id, Argon2id: stronger})
+	bcrypt4, _ := NewHasher(HasherConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 4})
+	bcrypt5, _ := NewHasher(HasherConfig{Algorithm: AlgorithmBcrypt, BcryptCost: 5})
+
+	argonHash, _ := argon.Hash("Correct7Horse")
+	bcryptHash, _ := bcrypt4.Hash("Correct7Horse")
+
+	tests := []struct {
+		name    string
+		hasher  Hasher
+		encoded string
+		want    bool
+	}{
+		{"same argon2id params", argon, argonHash, false},
+		{"raised argon2id cost", argonStronger, argonHash, true},
+		{"bcrypt to argon2id", argon, bcryptHash, true},
+		{"same bcrypt cost", bcrypt4, bcryptHash, false},
+		{"raised bcrypt cost", bcrypt5, bcryptHash, true},
+		{"argon2id to bcrypt", bcrypt4, argonHash, true},
+		{"unknown", argon, "plain", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHasherInvalid(t *testing.T) {
+	configs := []HasherConfig{
+		{Algorithm: "md5"},
+		{Algorithm: AlgorithmArgon2id},
+		{Algorithm: AlgorithmBcrypt, BcryptCost: 99},
+	}
+
+	for _, cfg := range configs {
+		if _, err := NewHasher(cfg); err == nil {
+			t.Errorf("NewHasher(%+v) error = nil, want error", cfg)
+		}
+	}
+}
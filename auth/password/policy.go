@@ -0,0 +1,197 @@
+// Package password checks new passwords against a configurable policy.
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule names reported in violations.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUpper     = "upper"
+	RuleLower     = "lower"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleBanned    = "banned_word"
+	RuleBreached  = "breached"
+)
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+
+	return "password does not meet the policy: " + strings.Join(messages, ", ")
+}
+
+type Policy struct {
+	MinLength int
+
+	// MaxLength is counted in bytes, bcrypt ignores everything past 72.
+	MaxLength int
+
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// BannedWords may not appear in the password, ignoring case.
+	BannedWords []string
+
+	// breached holds upper case hex SHA-1 digests of known leaked passwords.
+	breached map[string]struct{}
+}
+
+// LoadBreached reads a breached password list. Each line is either a
+// plain password or the SHA-1 digest of one, optionally followed by
+// ":count" as in the Have I Been Pwned downloads.
+func (p *Policy) LoadBreached(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	breached := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if digest, _, _ := strings.Cut(line, ":"); isSHA1(digest) {
+			breached[strings.ToUpper(digest)] = struct{}{}
+			continue
+		}
+
+		breached[digest(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	p.breached = breached
+	return nil
+}
+
+// Validate checks the password. Context words such as the email and name
+// of the user are banned in addition to the configured ones.
+func (p *Policy) Validate(password string, context ...string) error {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(RuleMinLength, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add(RuleMaxLength, fmt.Sprintf("must be at most %d bytes long", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		add(RuleUpper, "must contain an upper case letter")
+	}
+
+	if p.RequireLower && !lower {
+		add(RuleLower, "must contain a lower case letter")
+	}
+
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "must contain a digit")
+	}
+
+	if p.RequireSymbol && !symbol {
+		add(RuleSymbol, "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	for _, word := range p.bannedWords(context) {
+		if strings.Contains(lowered, word) {
+			add(RuleBanned, "must not contain your name, email or other easy to guess words")
+			break
+		}
+	}
+
+	if _, ok := p.breached[digest(password)]; ok {
+		add(RuleBreached, "appears in a list of breached passwords")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// bannedWords splits the configured and context words into lower case
+// parts, an email contributes its local part and domain name. Parts shorter
+// than four characters are ignored to avoid false positives.
+func (p *Policy) bannedWords(context []string) []string {
+	var words []string
+	for _, w := range append(append([]string{}, p.BannedWords...), context...) {
+		parts := strings.FieldsFunc(strings.ToLower(w), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= 4 {
+				words = append(words, part)
+			}
+		}
+	}
+
+	return words
+}
+
+func digest(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,75 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "breached.txt")
+	// "password1" and the SHA-1 of "letmein123" in the HIBP format.
+	content := "# comment\npassword1\n" + digest("letmein123") + ":42\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Policy{
+		MinLength:    8,
+		MaxLength:    20,
+		RequireUpper: true,
+		RequireDigit: true,
+		BannedWords:  []string{"clean-api"},
+	}
+	if err := p.LoadBreached(list); err != nil {
+		t.Fatalf("LoadBreached() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		context  []string
+		want     []string
+	}{
+		{"valid", "Correct7Horse", []string{"jane@example.com", "Jane Doe"}, nil},
+		{"too short", "Ab1", nil, []string{RuleMinLength}},
+		{"too long", "Abcdefghij1234567890x", nil, []string{RuleMaxLength}},
+		{"too long in bytes", "ÄÖcdefghij123456789", nil, []string{RuleMaxLength}},
+		{"missing classes", "lowercaseonly", nil, []string{RuleUpper, RuleDigit}},
+		{"email", "Jane1234Pass", []string{"jane@example.com"}, []string{RuleBanned}},
+		{"name", "XDoexyz99Ab", []string{"jane@x.io", "John Doexyz"}, []string{RuleBanned}},
+		{"configured word", "My1Clean", nil, []string{RuleBanned}},
+		{"breached plain", "password1", nil, []string{RuleUpper, RuleBreached}},
+		{"breached digest", "letmein123", nil, []string{RuleUpper, RuleBreached}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Validate(tt.password, tt.context...)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var pe *PolicyError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Validate() error = %v, want a PolicyError", err)
+			}
+
+			if len(pe.Violations) != len(tt.want) {
+				t.Fatalf("violations = %+v, want %v", pe.Violations, tt.want)
+			}
+
+			for i, rule := range tt.want {
+				if pe.Violations[i].Rule != rule {
+					t.Errorf("violation %d = %s, want %s", i, pe.Violations[i].Rule, rule)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package policy decides whether a principal may perform an action on a
+// resource, using rules such as "owner or holder of a permission".
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrDenied = errors.New("forbidden")
+
+// Conditions a rule can allow an action under.
+const (
+	// ConditionOwner holds when the principal owns the resource.
+	ConditionOwner = "owner"
+	// ConditionSelf holds when the principal owns the resource and signed
+	// in themselves, not through an API key or someone acting as them.
+	ConditionSelf = "self"
+	// ConditionAuthenticated holds for every signed in user or client.
+	ConditionAuthenticated = "authenticated"
+	// ConditionClient holds for machine clients, they were already
+	// authorized by scope when the request was accepted.
+	ConditionClient = "client"
+
+	// Prefixes of conditions that take an argument, e.g. "role:admin".
+	rolePrefix       = "role:"
+	permissionPrefix = "permission:"
+)
+
+// Rules maps an action to the conditions allowing it, any one of which is
+// enough. Actions without rules are denied.
+type Rules map[string][]string
+
+// Principal is who asks. Can reports whether the roles, or for clients the
+// scopes, of the principal grant a permission, permissions are kept outside
+// of this package. Actor is set when someone acts as the principal, ApiKey
+// when the principal authenticated with an API key.
+type Principal struct {
+	ID     string
+	Roles  []string
+	Client bool
+	Actor  string
+	ApiKey bool
+	Can    func(permission string) bool
+}
+
+// Resource is what the action is performed on.
+type Resource struct {
+	Type    string
+	ID      string
+	OwnerID string
+}
+
+type Policy struct {
+	rules Rules
+}
+
+// New checks every condition of the rules so typos in a policy file fail
+// on startup rather than silently denying requests.
+func New(rules Rules) (*Policy, error) {
+	for action, conditions := range rules {
+		if action == "" {
+			return nil, errors.New("policy rule without an action")
+		}
+
+		for _, c := range conditions {
+			if !valid(c) {
+				return nil, fmt.Errorf("policy rule %q has unknown condition %q", action, c)
+			}
+		}
+	}
+
+	return &Policy{rules: rules}, nil
+}
+
+// Load reads rules from a JSON file of the form
+// {"users:delete": ["owner", "permission:users:delete"]} on top of the
+// defaults. Actions in the file replace the default rules for them.
+func Load(path string, defaults Rules) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file Rules
+	err = json.Unmarshal(b, &file)
+	if err != nil {
+		return nil, fmt.Errorf("policy file %s: %w", path, err)
+	}
+
+	rules := make(Rules, len(defaults)+len(file))
+	for action, conditions := range defaults {
+		rules[action] = conditions
+	}
+	for action, conditions := range file {
+		rules[action] = conditions
+	}
+
+	return New(rules)
+}
+
+// Allowed reports whether any condition of the rule for the action holds.
+func (p *Policy) Allowed(principal Principal, action string, resource Resource) bool {
+	for _, c := range p.rules[action] {
+		if holds(c, principal, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authorize is Allowed returning ErrDenied.
+func (p *Policy) Authorize(principal Principal, action string, resource Resource) error {
+	if !p.Allowed(principal, action, resource) {
+		return ErrDenied
+	}
+
+	return nil
+}
+
+func holds(condition string, principal Principal, resource Resource) bool {
+	switch {
+	case condition == ConditionOwner:
+		return !principal.Client && principal.ID != "" && principal.ID == resource.OwnerID
+	case condition == ConditionSelf:
+		return holds(ConditionOwner, principal, resource) && principal.Actor == "" && !principal.ApiKey
+	case condition == ConditionAuthenticated:
+		return principal.ID != ""
+	case condition == ConditionClient:
+		return principal.Client
+	case strings.HasPrefix(condition, rolePrefix):
+		role := strings.TrimPrefix(condition, rolePrefix)
+		for _, r := range principal.Roles {
+			if r == role {
+				return true
+			}
+		}
+		return false
+	case strings.HasPrefix(condition, permissionPrefix):
+		return principal.Can != nil && principal.Can(strings.TrimPrefix(condition, permissionPrefix))
+	}
+
+	return false
+}
+
+func valid(condition string) bool {
+	switch condition {
+	case ConditionOwner, ConditionSelf, ConditionAuthenticated, ConditionClient:
+		return true
+	}
+
+	for _, prefix := range []string{rolePrefix, permissionPrefix} {
+		if strings.HasPrefix(condition, prefix) && len(condition) > len(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	p, err := New(Rules{
+		"users:read":   {ConditionOwner, "permission:users:read"},
+		"users:delete": {ConditionSelf, "permission:users:delete", ConditionClient},
+		"users:list":   {"role:support"},
+		"health:read":  {ConditionAuthenticated},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	grants := func(permissions ...string) func(string) bool {
+		return func(permission string) bool {
+			for _, p := range permissions {
+				if p == permission {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	owner := Principal{ID: "u1", Can: grants()}
+	impersonated := Principal{ID: "u1", Actor: "a1", Can: grants()}
+	ownerApiKey := Principal{ID: "u1", ApiKey: true, Can: grants()}
+	other := Principal{ID: "u2", Can: grants()}
+	admin := Principal{ID: "a1", Roles: []string{"admin"}, Can: grants("users:read", "users:delete")}
+	support := Principal{ID: "s1", Roles: []string{"support"}, Can: grants()}
+	client := Principal{ID: "c1", Client: true, Can: grants("users:read")}
+	anonymous := Principal{}
+
+	resource := Resource{Type: "user", ID: "u1", OwnerID: "u1"}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		action    string
+		want      bool
+	}{
+		{"owner reads", owner, "users:read", true},
+		{"owner deletes", owner, "users:delete", true},
+		{"impersonator reads as owner", impersonated, "users:read", true},
+		{"impersonator deletes as owner", impersonated, "users:delete", false},
+		{"owner api key deletes", ownerApiKey, "users:delete", false},
+		{"other reads", other, "users:read", false},
+		{"other deletes", other, "users:delete", false},
+		{"admin reads", admin, "users:read", true},
+		{"admin deletes", admin, "users:delete", true},
+		{"role allows", support, "users:list", true},
+		{"role missing", admin, "users:list", false},
+		{"client rule", client, "users:delete", true},
+		{"client permission", client, "users:read", true},
+		{"client without permission", Principal{ID: "c2", Client: true, Can: grants()}, "users:read", false},
+		{"authenticated user", other, "health:read", true},
+		{"authenticated client", client, "health:read", true},
+		{"anonymous", anonymous, "health:read", false},
+		{"anonymous owner of nothing", anonymous, "users:read", false},
+		{"unknown action", admin, "users:create", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Allowed(tt.principal, tt.action, resource); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := p.Authorize(other, "users:delete", resource); !errors.Is(err, ErrDenied) {
+		t.Errorf("Authorize() error = %v, want ErrDenied", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		wantErr bool
+	}{
+		{"valid", Rules{"a": {ConditionOwner, "role:admin", "permission:users:read"}}, false},
+		{"no conditions", Rules{"a": {}}, false},
+		{"unknown condition", Rules{"a": {"ownr"}}, true},
+		{"empty role", Rules{"a": {"role:"}}, true},
+		{"empty action", Rules{"": {ConditionOwner}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policy.json")
+	content := `{"users:delete": ["permission:users:delete"]}`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := Rules{
+		"users:read":   {ConditionOwner},
+		"users:delete": {ConditionOwner},
+	}
+
+	p, err := Load(file, defaults)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	owner := Principal{ID: "u1"}
+	resource := Resource{OwnerID: "u1"}
+
+	if !p.Allowed(owner, "users:read", resource) {
+		t.Error("default rule was not kept")
+	}
+	if p.Allowed(owner, "users:delete", resource) {
+		t.Error("default rule was not replaced by the file")
+	}
+
+	if err := os.WriteFile(file, []byte(`{"users:read": ["nobody"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(file, defaults); err == nil {
+		t.Error("Load() accepted an unknown condition")
+	}
+}
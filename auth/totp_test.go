@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors for SHA-1.
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		if got := hotp(key, tt.unix/totpPeriod, 8); got != tt.code {
+			t.Errorf("time %d: expected %s, got %s", tt.unix, tt.code, got)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	code, err := TOTPCode(secret, TOTPStep(now))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	step, ok := ValidateTOTP(secret, code, now, 1)
+	if !ok || step != TOTPStep(now) {
+		t.Errorf("current code should validate")
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now.Add(30*time.Second), 1); !ok {
+		t.Errorf("code of the previous step should validate within skew")
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now.Add(2*time.Minute), 1); ok {
+		t.Errorf("code outside of skew should not validate")
+	}
+
+	if _, ok := ValidateTOTP(secret, "abc", now, 1); ok {
+		t.Errorf("malformed code should not validate")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("SECRET", "Clean API", "user@example.com")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Clean%20API:user@example.com?") {
+		t.Errorf("provisioning uri label is incorrect: %s", uri)
+	}
+
+	if !strings.Contains(uri, "secret=SECRET") || !strings.Contains(uri, "issuer=Clean+API") {
+		t.Errorf("provisioning uri parameters are incorrect: %s", uri)
+	}
+}
@@ -0,0 +1,154 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errCBOR = errors.New("webauthn: malformed cbor")
+
+// maxCBORDepth limits nesting so a hostile payload can not exhaust the stack.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the subset of CBOR used by WebAuthn authenticators:
+// integers, byte and text strings, arrays, maps, booleans and null.
+// Integers decode to int64, maps to map[interface{}]interface{}. It returns
+// the number of bytes consumed so trailing data can be located.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := cborDecoder{data: data}
+	v, err := d.value(0)
+	return v, d.pos, err
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errCBOR
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		return d.bytes(arg)
+	case 3:
+		b, err := d.bytes(arg)
+		return string(b), err
+	case 4:
+		if arg > uint64(len(d.data)) {
+			return nil, errCBOR
+		}
+		arr := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case 5:
+		if arg > uint64(len(d.data)) {
+			return nil, errCBOR
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errCBOR
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+	}
+
+	return nil, errCBOR
+}
+
+// head reads the initial byte and argument of a data item. Indefinite
+// lengths are not allowed in WebAuthn and are rejected.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errCBOR
+	}
+
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, 0, errCBOR
+	}
+
+	if d.pos+size > len(d.data) {
+		return 0, 0, errCBOR
+	}
+
+	buf := make([]byte, 8)
+	copy(buf[8-size:], d.data[d.pos:d.pos+size])
+	d.pos += size
+
+	return major, binary.BigEndian.Uint64(buf), nil
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBOR
+	}
+
+	b := make([]byte, n)
+	copy(b, d.data[d.pos:])
+	d.pos += int(n)
+
+	return b, nil
+}
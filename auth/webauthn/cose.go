@@ -0,0 +1,120 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for credentials.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms are advertised to the browser in order of preference.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+var ErrUnsupportedKey = errors.New("webauthn: unsupported credential public key")
+
+// PublicKey is a credential public key decoded from its COSE encoding.
+type PublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as found in the attested credential data.
+func ParsePublicKey(cose []byte) (*PublicKey, error) {
+	v, _, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+	crv, _ := m[int64(-1)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256 && crv == coseCrvP256:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: alg, key: pub}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA && crv == coseCrvEd25519:
+		x, _ := m[int64(-2)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{
+			Algorithm: alg,
+			key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			},
+		}, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+// Verify checks an assertion signature over the given data.
+func (k *PublicKey) Verify(data, sig []byte) bool {
+	switch pub := k.key.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(pub, sum[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, data, sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+	}
+
+	return false
+}
@@ -0,0 +1,419 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies for passkeys.
+//
+// Attestation statements are not verified, the API asks for "none"
+// conveyance and does not restrict which authenticators may be used.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var (
+	ErrInvalidClientData = errors.New("webauthn: invalid client data")
+	ErrInvalidAuthData   = errors.New("webauthn: invalid authenticator data")
+	ErrChallenge         = errors.New("webauthn: challenge mismatch")
+	ErrOrigin            = errors.New("webauthn: origin not allowed")
+	ErrRelyingParty      = errors.New("webauthn: relying party id mismatch")
+	ErrUserPresence      = errors.New("webauthn: user not present")
+	ErrSignature         = errors.New("webauthn: invalid signature")
+	ErrSignCount         = errors.New("webauthn: sign count did not increase, the authenticator may be cloned")
+)
+
+// RelyingParty describes the site credentials are scoped to. ID is the
+// effective domain and Origins the exact origins the browser may report.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions,
+// binary values are base64url encoded.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type AttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AttestationObject string `json:"attestationObject" binding:"required"`
+}
+
+// RegistrationResponse is the credential returned by navigator.credentials.create.
+type RegistrationResponse struct {
+	ID       string              `json:"id" binding:"required"`
+	Type     string              `json:"type" binding:"required"`
+	Response AttestationResponse `json:"response" binding:"required"`
+}
+
+type AssertionData struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+// AssertionResponse is the credential returned by navigator.credentials.get.
+type AssertionResponse struct {
+	ID       string        `json:"id" binding:"required"`
+	Type     string        `json:"type" binding:"required"`
+	Response AssertionData `json:"response" binding:"required"`
+}
+
+// Credential is a verified new credential to be stored for the user.
+type Credential struct {
+	ID           []byte
+	PublicKey    []byte
+	Algorithm    int64
+	SignCount    uint32
+	AAGUID       []byte
+	UserVerified bool
+}
+
+// Assertion is the result of a verified authentication ceremony.
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewCreationOptions builds the options for registering a new passkey.
+// Existing credential IDs are excluded so an authenticator is not
+// registered twice.
+func (rp *RelyingParty) NewCreationOptions(challenge, userId, name, displayName string, exclude []string, timeout time.Duration) CreationOptions {
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User: UserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString([]byte(userId)),
+			Name:        name,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+// NewRequestOptions builds the options for a login. Without allowed
+// credentials the browser offers any discoverable passkey for the site.
+func (rp *RelyingParty) NewRequestOptions(challenge string, allow []string, timeout time.Duration) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: "preferred",
+	}
+}
+
+// Challenge returns the challenge echoed in the client data so the pending
+// ceremony can be looked up before the response is verified.
+func Challenge(clientDataJSON string) (string, error) {
+	cd, _, err := parseClientData(clientDataJSON)
+	if err != nil {
+		return "", err
+	}
+
+	return cd.Challenge, nil
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// that was issued and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge string, res RegistrationResponse) (*Credential, error) {
+	_, _, err := rp.verifyClientData(res.Response.ClientDataJSON, ceremonyCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawObject, err := decodeBase64(res.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidAuthData
+	}
+
+	v, _, err := decodeCBOR(rawObject)
+	if err != nil {
+		return nil, err
+	}
+
+	object, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidAuthData
+	}
+
+	rawAuthData, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad, err := rp.verifyAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if ad.credentialID == nil {
+		return nil, ErrInvalidAuthData
+	}
+
+	id, err := decodeBase64(res.ID)
+	if err != nil || !bytes.Equal(id, ad.credentialID) {
+		return nil, ErrInvalidAuthData
+	}
+
+	key, err := ParsePublicKey(ad.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:           ad.credentialID,
+		PublicKey:    ad.publicKey,
+		Algorithm:    key.Algorithm,
+		SignCount:    ad.signCount,
+		AAGUID:       ad.aaguid,
+		UserVerified: ad.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion checks a login response signed by a stored credential.
+// A sign count that does not increase is rejected unless the authenticator
+// never reports one.
+func (rp *RelyingParty) VerifyAssertion(challenge string, res AssertionResponse, publicKey []byte, signCount uint32) (*Assertion, error) {
+	_, rawClientData, err := rp.verifyClientData(res.Response.ClientDataJSON, ceremonyGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := decodeBase64(res.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad, err := rp.verifyAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := decodeBase64(res.Response.Signature)
+	if err != nil {
+		return nil, ErrSignature
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if !key.Verify(signed, sig) {
+		return nil, ErrSignature
+	}
+
+	if (ad.signCount != 0 || signCount != 0) && ad.signCount <= signCount {
+		return nil, ErrSignCount
+	}
+
+	return &Assertion{
+		SignCount:    ad.signCount,
+		UserVerified: ad.flags&flagUserVerified != 0,
+	}, nil
+}
+
+func (rp *RelyingParty) verifyClientData(encoded, ceremony, challenge string) (*clientData, []byte, error) {
+	cd, raw, err := parseClientData(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cd.Type != ceremony {
+		return nil, nil, ErrInvalidClientData
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return nil, nil, ErrChallenge
+	}
+
+	for _, origin := range rp.Origins {
+		if cd.Origin == origin {
+			return cd, raw, nil
+		}
+	}
+
+	return nil, nil, ErrOrigin
+}
+
+func (rp *RelyingParty) verifyAuthData(raw []byte) (*authData, error) {
+	ad, err := parseAuthData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(ad.rpIDHash, rpIDHash[:]) != 1 {
+		return nil, ErrRelyingParty
+	}
+
+	if ad.flags&flagUserPresent == 0 {
+		return nil, ErrUserPresence
+	}
+
+	return ad, nil
+}
+
+func parseClientData(encoded string) (*clientData, []byte, error) {
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidClientData
+	}
+
+	var cd clientData
+	err = json.Unmarshal(raw, &cd)
+	if err != nil {
+		return nil, nil, ErrInvalidClientData
+	}
+
+	return &cd, raw, nil
+}
+
+// parseAuthData splits the authenticator data into its fields, see
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data.
+func parseAuthData(raw []byte) (*authData, error) {
+	if len(raw) < 37 {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad := &authData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if ad.flags&flagAttestedData == 0 {
+		return ad, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad.aaguid = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	_, n, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad.publicKey = rest[:n]
+
+	return ad, nil
+}
+
+func descriptors(ids []string) []CredentialDescriptor {
+	list := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, CredentialDescriptor{Type: "public-key", ID: id})
+	}
+
+	return list
+}
+
+// decodeBase64 accepts base64url with or without padding as sent by the
+// various browser helper libraries.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
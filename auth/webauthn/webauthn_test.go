@@ -0,0 +1,205 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testRP = &RelyingParty{ID: "localhost", Name: "Test", Origins: []string{"http://localhost:3000"}}
+
+// authenticator is a software authenticator holding a single P-256 key.
+type authenticator struct {
+	t         *testing.T
+	key       *ecdsa.PrivateKey
+	id        []byte
+	signCount uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authenticator{t: t, key: key, id: []byte("credential-1")}
+}
+
+func (a *authenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	b := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	b = append(b, x...)
+	b = append(b, 0x22, 0x58, 0x20)
+	return append(b, y...)
+}
+
+func (a *authenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	b := append([]byte{}, rpIDHash[:]...)
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, a.signCount)
+
+	if attested {
+		b = append(b, make([]byte, 16)...)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.id)))
+		b = append(b, a.id...)
+		b = append(b, a.coseKey()...)
+	}
+
+	return b
+}
+
+func clientDataJSON(t *testing.T, typ, challenge, origin string) []byte {
+	b, err := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func (a *authenticator) register(challenge, origin string) RegistrationResponse {
+	authData := a.authData("localhost", flagUserPresent|flagUserVerified|flagAttestedData, true)
+
+	// {"fmt": "none", "attStmt": {}, "authData": authData}
+	object := []byte{0xa3, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0}
+	object = append(object, 0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x59)
+	object = binary.BigEndian.AppendUint16(object, uint16(len(authData)))
+	object = append(object, authData...)
+
+	return RegistrationResponse{
+		ID:   encode(a.id),
+		Type: "public-key",
+		Response: AttestationResponse{
+			ClientDataJSON:    encode(clientDataJSON(a.t, ceremonyCreate, challenge, origin)),
+			AttestationObject: encode(object),
+		},
+	}
+}
+
+func (a *authenticator) assert(rpID, challenge string) AssertionResponse {
+	a.signCount++
+
+	authData := a.authData(rpID, flagUserPresent|flagUserVerified, false)
+	cd := clientDataJSON(a.t, ceremonyGet, challenge, "http://localhost:3000")
+	cdHash := sha256.Sum256(cd)
+	sum := sha256.Sum256(append(append([]byte{}, authData...), cdHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, sum[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return AssertionResponse{
+		ID:   encode(a.id),
+		Type: "public-key",
+		Response: AssertionData{
+			ClientDataJSON:    encode(cd),
+			AuthenticatorData: encode(authData),
+			Signature:         encode(sig),
+		},
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestRegisterAndAssert(t *testing.T) {
+	a := newAuthenticator(t)
+
+	cred, err := testRP.VerifyRegistration("challenge-1", a.register("challenge-1", "http://localhost:3000"))
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error = %v", err)
+	}
+
+	if string(cred.ID) != string(a.id) || cred.Algorithm != AlgES256 || !cred.UserVerified {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	res := a.assert("localhost", "challenge-2")
+
+	challenge, err := Challenge(res.Response.ClientDataJSON)
+	if err != nil || challenge != "challenge-2" {
+		t.Fatalf("Challenge() = %q, %v", challenge, err)
+	}
+
+	assertion, err := testRP.VerifyAssertion("challenge-2", res, cred.PublicKey, cred.SignCount)
+	if err != nil {
+		t.Fatalf("VerifyAssertion() error = %v", err)
+	}
+
+	if assertion.SignCount != 1 {
+		t.Fatalf("SignCount = %d, want 1", assertion.SignCount)
+	}
+
+	_, err = testRP.VerifyAssertion("challenge-2", res, cred.PublicKey, assertion.SignCount)
+	if !errors.Is(err, ErrSignCount) {
+		t.Fatalf("replayed assertion error = %v, want %v", err, ErrSignCount)
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	a := newAuthenticator(t)
+
+	_, err := testRP.VerifyRegistration("other", a.register("challenge", "http://localhost:3000"))
+	if !errors.Is(err, ErrChallenge) {
+		t.Errorf("wrong challenge error = %v, want %v", err, ErrChallenge)
+	}
+
+	_, err = testRP.VerifyRegistration("challenge", a.register("challenge", "https://evil.example"))
+	if !errors.Is(err, ErrOrigin) {
+		t.Errorf("wrong origin error = %v, want %v", err, ErrOrigin)
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	a := newAuthenticator(t)
+	cred, err := testRP.VerifyRegistration("challenge", a.register("challenge", "http://localhost:3000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testRP.VerifyAssertion("challenge", a.assert("evil.example", "challenge"), cred.PublicKey, 0)
+	if !errors.Is(err, ErrRelyingParty) {
+		t.Errorf("wrong rp id error = %v, want %v", err, ErrRelyingParty)
+	}
+
+	other := newAuthenticator(t)
+	_, err = testRP.VerifyAssertion("challenge", other.assert("localhost", "challenge"), cred.PublicKey, 0)
+	if !errors.Is(err, ErrSignature) {
+		t.Errorf("wrong key error = %v, want %v", err, ErrSignature)
+	}
+}
+
+func TestDecodeCBORRejectsTruncated(t *testing.T) {
+	for _, data := range [][]byte{{}, {0x59, 0x01}, {0xa1, 0x01}, {0x5f}} {
+		if _, _, err := decodeCBOR(data); err == nil {
+			t.Errorf("decodeCBOR(%x) expected error", data)
+		}
+	}
+}
+
+func TestNewCreationOptions(t *testing.T) {
+	opts := testRP.NewCreationOptions("c", "user-1", "a@b.c", "A", []string{"id"}, time.Minute)
+
+	if opts.Timeout != 60000 || opts.RP.ID != "localhost" || len(opts.ExcludeCredentials) != 1 {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+
+	if opts.User.ID != encode([]byte("user-1")) {
+		t.Fatalf("User.ID = %q", opts.User.ID)
+	}
+}
@@ -12,12 +12,12 @@ import (
 func NewDB(cfg config.Config) (*gorm.DB, error) {
 	log.Info().Msg("Creating new database connection")
 
-	db, err := gorm.Open(postgres.Open(cfg.DatabaseUrl), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseUrl), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
 
-	db.AutoMigrate(&models.User{})
+	db.AutoMigrate(&models.User{}, &models.RefreshTokenRecord{}, &models.RevokedToken{}, &models.OidcState{}, &models.OAuthClient{}, &models.OAuthAuthorizationCode{}, &models.RecoveryCode{}, &models.Passkey{}, &models.WebauthnSession{}, &models.ApiKey{}, &models.PasswordResetToken{}, &models.MagicLink{}, &models.LoginAttempt{}, &models.Session{}, &models.AuditEvent{}, &models.Role{}, &models.Organization{}, &models.Membership{}, &models.Invitation{}, &models.Group{}, &models.GroupMember{}, &models.RoleGrant{})
 
 	return db, nil
 }
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type ApiKeyRepository interface {
+	Create(t *models.ApiKey) error
+
+	FindByUserId(userId string) ([]models.ApiKey, error)
+	FindByHash(hash string) (models.ApiKey, error)
+	Revoke(userId, id string) (bool, error)
+	Touch(id, ip string) error
+}
+
+func NewApiKeyRepository(db *gorm.DB) ApiKeyRepository {
+	log.Info().Msg("Creating new api key repository")
+
+	return &apiKeyRepository{
+		BaseRepository: NewBaseRepository[models.ApiKey](db),
+		db:             db,
+	}
+}
+
+type apiKeyRepository struct {
+	BaseRepository[models.ApiKey]
+	db *gorm.DB
+}
+
+func (r *apiKeyRepository) FindByUserId(userId string) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+	err := r.db.Where("user_id = ?", userId).Order("created_at").Find(&keys).Error
+
+	return keys, err
+}
+
+func (r *apiKeyRepository) FindByHash(hash string) (models.ApiKey, error) {
+	var key models.ApiKey
+	err := r.db.First(&key, "key_hash = ?", hash).Error
+
+	return key, err
+}
+
+func (r *apiKeyRepository) Revoke(userId, id string) (bool, error) {
+	res := r.db.Model(&models.ApiKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *apiKeyRepository) Touch(id, ip string) error {
+	return r.db.Model(&models.ApiKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": ip}).Error
+}
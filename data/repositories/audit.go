@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	Create(t *models.AuditEvent) error
+
+	Find(query models.AuditQuery) ([]models.AuditEvent, error)
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	log.Info().Msg("Creating new audit repository")
+
+	return &auditRepository{
+		BaseRepository: NewBaseRepository[models.AuditEvent](db),
+		db:             db,
+	}
+}
+
+type auditRepository struct {
+	BaseRepository[models.AuditEvent]
+	db *gorm.DB
+}
+
+// Find returns the newest events first, filtered by the non-empty fields of
+// the query.
+func (r *auditRepository) Find(query models.AuditQuery) ([]models.AuditEvent, error) {
+	db := r.db.Scopes(paginate(query.Page, query.Size)).Order("created_at DESC")
+
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+
+	if query.ActorID != "" {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+
+	if query.SubjectID != "" {
+		db = db.Where("subject_id = ?", query.SubjectID)
+	}
+
+	var events []models.AuditEvent
+	err := db.Find(&events).Error
+
+	return events, err
+}
@@ -1,8 +1,16 @@
 package repositories
 
 import (
+	"errors"
+
 	"github.com/Marcel-MD/clean-api/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotTenantScoped = errors.New("model is not owned by an organization")
+	ErrDuplicate       = errors.New("record already exists")
 )
 
 type BaseRepository[T any] interface {
@@ -19,33 +27,116 @@ func NewBaseRepository[T any](db *gorm.DB) BaseRepository[T] {
 	}
 }
 
+// NewTenantRepository returns a BaseRepository that only sees the rows of
+// one organization. T must implement models.TenantScoped, created rows are assigned
+// to the organization and rows of other organizations can neither be read,
+// updated nor deleted.
+func NewTenantRepository[T any](db *gorm.DB, organizationId string) BaseRepository[T] {
+	return &baseRepository[T]{
+		db:       db,
+		tenantId: organizationId,
+	}
+}
+
 type baseRepository[T any] struct {
-	db *gorm.DB
+	db       *gorm.DB
+	tenantId string
 }
 
 func (r *baseRepository[T]) FindAll(query models.PaginationQuery) ([]T, error) {
 	var ts []T
 
-	err := r.db.Scopes(paginate(query.Page, query.Size)).Find(&ts).Error
+	err := r.scoped().Scopes(paginate(query.Page, query.Size)).Find(&ts).Error
 	return ts, err
 }
 
 func (r *baseRepository[T]) FindById(id string) (T, error) {
 	var t T
-	err := r.db.First(&t, "id = ?", id).Error
+	err := r.scoped().First(&t, "id = ?", id).Error
 	return t, err
 }
 
 func (r *baseRepository[T]) Create(t *T) error {
+	err := r.assign(t)
+	if err != nil {
+		return err
+	}
+
 	return r.db.Create(t).Error
 }
 
+// Update saves every column. Within a tenant it never inserts, so a row of
+// another organization with the same ID can not be overwritten.
 func (r *baseRepository[T]) Update(t *T) error {
-	return r.db.Save(t).Error
+	if r.tenantId == "" {
+		return r.db.Save(t).Error
+	}
+
+	err := r.assign(t)
+	if err != nil {
+		return err
+	}
+
+	return affected(r.scoped().Model(t).Select("*").Omit(clause.Associations).Updates(t))
 }
 
 func (r *baseRepository[T]) Delete(t *T) error {
-	return r.db.Delete(t).Error
+	if r.tenantId == "" {
+		return r.db.Delete(t).Error
+	}
+
+	return affected(r.scoped().Delete(t))
+}
+
+func (r *baseRepository[T]) scoped() *gorm.DB {
+	if r.tenantId == "" {
+		return r.db
+	}
+
+	return r.db.Scopes(inTenant(r.tenantId))
+}
+
+func (r *baseRepository[T]) assign(t *T) error {
+	if r.tenantId == "" {
+		return nil
+	}
+
+	m, ok := any(t).(models.TenantScoped)
+	if !ok {
+		return ErrNotTenantScoped
+	}
+
+	m.SetOrganizationID(r.tenantId)
+	return nil
+}
+
+// affected turns a write that matched no row into gorm.ErrRecordNotFound.
+func affected(tx *gorm.DB) error {
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// duplicate turns a unique constraint violation into ErrDuplicate.
+func duplicate(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// inTenant limits a query to the rows of an organization.
+func inTenant(organizationId string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("organization_id = ?", organizationId)
+	}
 }
 
 func paginate(page int, size int) func(db *gorm.DB) *gorm.DB {
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"encoding/json"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GroupRepository interface {
+	FindAll(query models.PaginationQuery) ([]models.Group, error)
+	FindById(id string) (models.Group, error)
+	Create(t *models.Group) error
+	Update(t *models.Group) error
+	Delete(t *models.Group) error
+
+	FindByName(name string) (models.Group, error)
+	FindAllByUserId(userId string) ([]models.Group, error)
+	CountByRole(role string) (int64, error)
+
+	FindMembers(groupId string) ([]models.GroupMember, error)
+	AddMember(groupId, userId string) error
+	RemoveMember(groupId, userId string) (bool, error)
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	log.Info().Msg("Creating new group repository")
+
+	return &groupRepository{
+		BaseRepository: NewBaseRepository[models.Group](db),
+		db:             db,
+	}
+}
+
+type groupRepository struct {
+	BaseRepository[models.Group]
+	db *gorm.DB
+}
+
+func (r *groupRepository) FindByName(name string) (models.Group, error) {
+	var group models.Group
+	err := r.db.First(&group, "name = ?", name).Error
+
+	return group, err
+}
+
+func (r *groupRepository) FindAllByUserId(userId string) ([]models.Group, error) {
+	var groups []models.Group
+	err := r.db.
+		Joins("JOIN group_members ON group_members.group_id = groups.id").
+		Where("group_members.user_id = ?", userId).
+		Order("groups.name").
+		Find(&groups).Error
+
+	return groups, err
+}
+
+func (r *groupRepository) CountByRole(role string) (int64, error) {
+	roles, err := json.Marshal([]string{role})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = r.db.Model(&models.Group{}).Where("roles @> ?::jsonb", string(roles)).Count(&count).Error
+
+	return count, err
+}
+
+func (r *groupRepository) FindMembers(groupId string) ([]models.GroupMember, error) {
+	var members []models.GroupMember
+	err := r.db.Preload("User").Where("group_id = ?", groupId).Order("created_at").Find(&members).Error
+
+	return members, err
+}
+
+func (r *groupRepository) AddMember(groupId, userId string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.GroupMember{GroupID: groupId, UserID: userId}).Error
+}
+
+func (r *groupRepository) RemoveMember(groupId, userId string) (bool, error) {
+	res := r.db.Where("group_id = ? AND user_id = ?", groupId, userId).Delete(&models.GroupMember{})
+
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// InvitationRepository manages the invitations of an organization, except
+// for the lookups by token made by the invited person.
+type InvitationRepository interface {
+	FindAll(organizationId string) ([]models.Invitation, error)
+	FindById(organizationId, id string) (models.Invitation, error)
+	Create(organizationId string, t *models.Invitation) error
+	Update(organizationId string, t *models.Invitation) error
+
+	FindPendingByEmail(organizationId, email string) (models.Invitation, error)
+	FindByHash(tokenHash string) (models.Invitation, error)
+	// Respond moves a pending invitation to the status, it reports false
+	// when the invitation was answered or revoked in the meantime.
+	Respond(id, status string) (bool, error)
+	// Accept claims the pending invitation and creates the user, when not
+	// nil, and the membership in one transaction, so either all of them are
+	// kept or none. Concurrent accepts wait for the claim and find the
+	// invitation answered. It reports false when the invitation was answered
+	// or revoked in the meantime and returns ErrDuplicate when the user
+	// already is a member.
+	Accept(id string, user *models.User, membership *models.Membership) (bool, error)
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	log.Info().Msg("Creating new invitation repository")
+
+	return &invitationRepository{
+		db: db,
+	}
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func (r *invitationRepository) FindAll(organizationId string) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.Scopes(inTenant(organizationId)).Order("created_at desc").Find(&invitations).Error
+
+	return invitations, err
+}
+
+func (r *invitationRepository) FindById(organizationId, id string) (models.Invitation, error) {
+	return r.tenant(organizationId).FindById(id)
+}
+
+func (r *invitationRepository) Create(organizationId string, t *models.Invitation) error {
+	return r.tenant(organizationId).Create(t)
+}
+
+func (r *invitationRepository) Update(organizationId string, t *models.Invitation) error {
+	return r.tenant(organizationId).Update(t)
+}
+
+func (r *invitationRepository) FindPendingByEmail(organizationId, email string) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Scopes(inTenant(organizationId)).
+		First(&invitation, "email = ? AND status = ?", email, models.InvitationPending).Error
+
+	return invitation, err
+}
+
+func (r *invitationRepository) FindByHash(tokenHash string) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Preload("Organization").First(&invitation, "token_hash = ?", tokenHash).Error
+
+	return invitation, err
+}
+
+func (r *invitationRepository) Respond(id, status string) (bool, error) {
+	res := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND status = ?", id, models.InvitationPending).
+		Updates(map[string]any{"status": status, "responded_at": time.Now()})
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *invitationRepository) Accept(id string, user *models.User, membership *models.Membership) (bool, error) {
+	claimed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Invitation{}).
+			Where("id = ? AND status = ?", id, models.InvitationPending).
+			Updates(map[string]any{"status": models.InvitationAccepted, "responded_at": time.Now()})
+		if res.Error != nil || res.RowsAffected != 1 {
+			return res.Error
+		}
+
+		if user != nil {
+			err := tx.Create(user).Error
+			if err != nil {
+				return err
+			}
+
+			membership.UserID = user.ID
+		}
+
+		err := tx.Create(membership).Error
+		if err != nil {
+			return duplicate(err)
+		}
+
+		claimed = true
+		return nil
+	})
+
+	return claimed, err
+}
+
+func (r *invitationRepository) tenant(organizationId string) BaseRepository[models.Invitation] {
+	return NewTenantRepository[models.Invitation](r.db, organizationId)
+}
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginAttemptRepository interface {
+	Find(keys ...string) ([]models.LoginAttempt, error)
+	FindLocked() ([]models.LoginAttempt, error)
+	Update(keys []string, fn func(attempts []*models.LoginAttempt) error) error
+	Reset(key string) error
+	Prune(window time.Duration) error
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	log.Info().Msg("Creating new login attempt repository")
+
+	return &loginAttemptRepository{
+		db: db,
+	}
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func (r *loginAttemptRepository) Find(keys ...string) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := r.db.Where("key IN ?", keys).Find(&attempts).Error
+
+	return attempts, err
+}
+
+func (r *loginAttemptRepository) FindLocked() ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := r.db.Where("locked_until > ?", time.Now()).Order("locked_until DESC").Find(&attempts).Error
+
+	return attempts, err
+}
+
+// Update passes the attempts of the keys to fn while holding their rows
+// locked and saves what fn changed, so concurrent requests for a key see
+// each other's attempts. Keys without attempts are passed as new ones and
+// attempts left without failures or lock are removed. Nothing is saved when
+// fn returns an error.
+func (r *loginAttemptRepository) Update(keys []string, fn func(attempts []*models.LoginAttempt) error) error {
+	// Rows are locked in key order so two requests can not deadlock.
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range sorted {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginAttempt{Key: key}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var found []models.LoginAttempt
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key IN ?", sorted).Order("key").Find(&found).Error
+		if err != nil {
+			return err
+		}
+
+		attempts := orderedAttempts(keys, found)
+		err = fn(attempts)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range attempts {
+			if a.Failures == 0 && a.LockedUntil == nil {
+				err = tx.Delete(a).Error
+			} else {
+				err = tx.Save(a).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *loginAttemptRepository) Reset(key string) error {
+	return r.db.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func (r *loginAttemptRepository) Prune(window time.Duration) error {
+	now := time.Now()
+	return r.db.Where("last_failure_at <= ? AND (locked_until IS NULL OR locked_until <= ?)", now.Add(-window), now).
+		Delete(&models.LoginAttempt{}).Error
+}
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	log.Info().Msg("Creating new in-memory login attempt repository")
+
+	return &memoryLoginAttemptRepository{
+		attempts: make(map[string]models.LoginAttempt),
+	}
+}
+
+type memoryLoginAttemptRepository struct {
+	mu       sync.RWMutex
+	attempts map[string]models.LoginAttempt
+}
+
+func (r *memoryLoginAttemptRepository) Find(keys ...string) ([]models.LoginAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var attempts []models.LoginAttempt
+	for _, key := range keys {
+		if a, ok := r.attempts[key]; ok {
+			attempts = append(attempts, a)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (r *memoryLoginAttemptRepository) FindLocked() ([]models.LoginAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	var attempts []models.LoginAttempt
+	for _, a := range r.attempts {
+		if a.LockedUntil != nil && a.LockedUntil.After(now) {
+			attempts = append(attempts, a)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (r *memoryLoginAttemptRepository) Update(keys []string, fn func(attempts []*models.LoginAttempt) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found []models.LoginAttempt
+	for _, key := range keys {
+		if a, ok := r.attempts[key]; ok {
+			found = append(found, a)
+		}
+	}
+
+	attempts := orderedAttempts(keys, found)
+	err := fn(attempts)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attempts {
+		if a.Failures == 0 && a.LockedUntil == nil {
+			delete(r.attempts, a.Key)
+		} else {
+			r.attempts[a.Key] = *a
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Reset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Prune(window time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for key, a := range r.attempts {
+		if !a.LastFailureAt.After(now.Add(-window)) && (a.LockedUntil == nil || !a.LockedUntil.After(now)) {
+			delete(r.attempts, key)
+		}
+	}
+
+	return nil
+}
+
+// orderedAttempts returns an attempt for every key in the order of the keys,
+// new ones for keys that were not found.
+func orderedAttempts(keys []string, found []models.LoginAttempt) []*models.LoginAttempt {
+	byKey := make(map[string]models.LoginAttempt, len(found))
+	for _, a := range found {
+		byKey[a.Key] = a
+	}
+
+	attempts := make([]*models.LoginAttempt, len(keys))
+	for i, key := range keys {
+		a, ok := byKey[key]
+		if !ok {
+			a = models.LoginAttempt{Key: key}
+		}
+
+		attempts[i] = &a
+	}
+
+	return attempts
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type MagicLinkRepository interface {
+	Create(t *models.MagicLink) error
+
+	FindByHash(hash string) (models.MagicLink, error)
+	MarkUsed(id string) (bool, error)
+	DeleteExpired() error
+}
+
+func NewMagicLinkRepository(db *gorm.DB) MagicLinkRepository {
+	log.Info().Msg("Creating new magic link repository")
+
+	return &magicLinkRepository{
+		BaseRepository: NewBaseRepository[models.MagicLink](db),
+		db:             db,
+	}
+}
+
+type magicLinkRepository struct {
+	BaseRepository[models.MagicLink]
+	db *gorm.DB
+}
+
+func (r *magicLinkRepository) FindByHash(hash string) (models.MagicLink, error) {
+	var link models.MagicLink
+	err := r.db.First(&link, "token_hash = ?", hash).Error
+
+	return link, err
+}
+
+func (r *magicLinkRepository) MarkUsed(id string) (bool, error) {
+	res := r.db.Model(&models.MagicLink{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *magicLinkRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&models.MagicLink{}).Error
+}
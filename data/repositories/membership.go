@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MembershipRepository takes the organization of every call and only ever
+// touches memberships of that organization.
+type MembershipRepository interface {
+	FindAll(organizationId string) ([]models.Membership, error)
+	FindByUserId(organizationId, userId string) (models.Membership, error)
+	// Create returns ErrDuplicate when the user already is a member.
+	Create(organizationId string, t *models.Membership) error
+	Update(organizationId string, t *models.Membership) error
+	Delete(organizationId string, t *models.Membership) error
+
+	// UpdateOwner and DeleteOwner demote or remove an owner, they report
+	// false and change nothing when no other owner would be left.
+	UpdateOwner(organizationId string, t *models.Membership) (bool, error)
+	DeleteOwner(organizationId string, t *models.Membership) (bool, error)
+}
+
+func NewMembershipRepository(db *gorm.DB) MembershipRepository {
+	log.Info().Msg("Creating new membership repository")
+
+	return &membershipRepository{
+		db: db,
+	}
+}
+
+type membershipRepository struct {
+	db *gorm.DB
+}
+
+func (r *membershipRepository) FindAll(organizationId string) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Scopes(inTenant(organizationId)).Preload("User").Order("created_at").Find(&memberships).Error
+
+	return memberships, err
+}
+
+func (r *membershipRepository) FindByUserId(organizationId, userId string) (models.Membership, error) {
+	var membership models.Membership
+	err := r.db.Scopes(inTenant(organizationId)).Preload("User").First(&membership, "user_id = ?", userId).Error
+
+	return membership, err
+}
+
+func (r *membershipRepository) Create(organizationId string, t *models.Membership) error {
+	return duplicate(r.tenant(organizationId).Create(t))
+}
+
+func (r *membershipRepository) Update(organizationId string, t *models.Membership) error {
+	return r.tenant(organizationId).Update(t)
+}
+
+func (r *membershipRepository) Delete(organizationId string, t *models.Membership) error {
+	return r.tenant(organizationId).Delete(t)
+}
+
+func (r *membershipRepository) UpdateOwner(organizationId string, t *models.Membership) (bool, error) {
+	return r.withOtherOwner(organizationId, t, func(tx *gorm.DB) error {
+		return NewTenantRepository[models.Membership](tx, organizationId).Update(t)
+	})
+}
+
+func (r *membershipRepository) DeleteOwner(organizationId string, t *models.Membership) (bool, error) {
+	return r.withOtherOwner(organizationId, t, func(tx *gorm.DB) error {
+		return NewTenantRepository[models.Membership](tx, organizationId).Delete(t)
+	})
+}
+
+// withOtherOwner runs fn while holding the owner rows of the organization
+// locked, so two owners demoting or removing each other at once can not
+// leave it without one.
+func (r *membershipRepository) withOtherOwner(organizationId string, t *models.Membership, fn func(tx *gorm.DB) error) (bool, error) {
+	ok := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var owners []models.Membership
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(inTenant(organizationId)).
+			Where("role = ? AND id <> ?", models.OrgRoleOwner, t.ID).Find(&owners).Error
+		if err != nil {
+			return err
+		}
+
+		if len(owners) == 0 {
+			return nil
+		}
+
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+
+		ok = true
+		return nil
+	})
+
+	return ok, err
+}
+
+func (r *membershipRepository) tenant(organizationId string) BaseRepository[models.Membership] {
+	return NewTenantRepository[models.Membership](r.db, organizationId)
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type OAuthClientRepository interface {
+	FindAll(query models.PaginationQuery) ([]models.OAuthClient, error)
+	FindById(id string) (models.OAuthClient, error)
+	Create(t *models.OAuthClient) error
+	Update(t *models.OAuthClient) error
+	Delete(t *models.OAuthClient) error
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	log.Info().Msg("Creating new oauth client repository")
+
+	return &oauthClientRepository{
+		BaseRepository: NewBaseRepository[models.OAuthClient](db),
+	}
+}
+
+type oauthClientRepository struct {
+	BaseRepository[models.OAuthClient]
+}
+
+type OAuthCodeRepository interface {
+	Create(t *models.OAuthAuthorizationCode) error
+
+	FindByCodeHash(hash string) (models.OAuthAuthorizationCode, error)
+	MarkUsed(id string) (bool, error)
+	DeleteExpired() error
+}
+
+func NewOAuthCodeRepository(db *gorm.DB) OAuthCodeRepository {
+	log.Info().Msg("Creating new oauth code repository")
+
+	return &oauthCodeRepository{
+		BaseRepository: NewBaseRepository[models.OAuthAuthorizationCode](db),
+		db:             db,
+	}
+}
+
+type oauthCodeRepository struct {
+	BaseRepository[models.OAuthAuthorizationCode]
+	db *gorm.DB
+}
+
+func (r *oauthCodeRepository) FindByCodeHash(hash string) (models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := r.db.First(&code, "code_hash = ?", hash).Error
+
+	return code, err
+}
+
+func (r *oauthCodeRepository) MarkUsed(id string) (bool, error) {
+	res := r.db.Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+// DeleteExpired keeps used codes until they expire so replays are still
+// detected.
+func (r *oauthCodeRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&models.OAuthAuthorizationCode{}).Error
+}
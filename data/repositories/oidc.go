@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type OidcStateRepository interface {
+	Create(t *models.OidcState) error
+	Delete(t *models.OidcState) error
+
+	FindByStateHash(hash string) (models.OidcState, error)
+	DeleteExpired() error
+}
+
+func NewOidcStateRepository(db *gorm.DB) OidcStateRepository {
+	log.Info().Msg("Creating new oidc state repository")
+
+	return &oidcStateRepository{
+		BaseRepository: NewBaseRepository[models.OidcState](db),
+		db:             db,
+	}
+}
+
+type oidcStateRepository struct {
+	BaseRepository[models.OidcState]
+	db *gorm.DB
+}
+
+func (r *oidcStateRepository) FindByStateHash(hash string) (models.OidcState, error) {
+	var state models.OidcState
+	err := r.db.First(&state, "state_hash = ?", hash).Error
+
+	return state, err
+}
+
+func (r *oidcStateRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&models.OidcState{}).Error
+}
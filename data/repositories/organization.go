@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository interface {
+	FindById(id string) (models.Organization, error)
+	Create(t *models.Organization) error
+	Update(t *models.Organization) error
+	Delete(t *models.Organization) error
+
+	// CreateWithOwner creates the organization along with the membership of
+	// its first owner, or neither. It returns ErrDuplicate when the slug is
+	// taken.
+	CreateWithOwner(t *models.Organization, owner *models.Membership) error
+	FindBySlug(slug string) (models.Organization, error)
+	FindAllByUserId(userId string) ([]models.Organization, error)
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	log.Info().Msg("Creating new organization repository")
+
+	return &organizationRepository{
+		BaseRepository: NewBaseRepository[models.Organization](db),
+		db:             db,
+	}
+}
+
+type organizationRepository struct {
+	BaseRepository[models.Organization]
+	db *gorm.DB
+}
+
+func (r *organizationRepository) CreateWithOwner(t *models.Organization, owner *models.Membership) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(t).Error
+		if err != nil {
+			return err
+		}
+
+		owner.OrganizationID = t.ID
+		return tx.Create(owner).Error
+	})
+
+	return duplicate(err)
+}
+
+func (r *organizationRepository) FindBySlug(slug string) (models.Organization, error) {
+	var organization models.Organization
+	err := r.db.First(&organization, "slug = ?", slug).Error
+
+	return organization, err
+}
+
+// FindAllByUserId returns the organizations the user is a member of along
+// with the role of the user in each.
+func (r *organizationRepository) FindAllByUserId(userId string) ([]models.Organization, error) {
+	var organizations []models.Organization
+	err := r.db.Model(&models.Organization{}).
+		Select("organizations.*, memberships.role").
+		Joins("JOIN memberships ON memberships.organization_id = organizations.id").
+		Where("memberships.user_id = ?", userId).
+		Order("organizations.name").
+		Find(&organizations).Error
+
+	return organizations, err
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type PasskeyRepository interface {
+	Create(t *models.Passkey) error
+	Delete(t *models.Passkey) error
+
+	FindByUserId(userId string) ([]models.Passkey, error)
+	FindByCredentialId(credentialId string) (models.Passkey, error)
+	UpdateSignCount(id string, from, to uint32) (bool, error)
+}
+
+func NewPasskeyRepository(db *gorm.DB) PasskeyRepository {
+	log.Info().Msg("Creating new passkey repository")
+
+	return &passkeyRepository{
+		BaseRepository: NewBaseRepository[models.Passkey](db),
+		db:             db,
+	}
+}
+
+type passkeyRepository struct {
+	BaseRepository[models.Passkey]
+	db *gorm.DB
+}
+
+func (r *passkeyRepository) FindByUserId(userId string) ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	err := r.db.Where("user_id = ?", userId).Order("created_at").Find(&passkeys).Error
+
+	return passkeys, err
+}
+
+func (r *passkeyRepository) FindByCredentialId(credentialId string) (models.Passkey, error) {
+	var passkey models.Passkey
+	err := r.db.First(&passkey, "credential_id = ?", credentialId).Error
+
+	return passkey, err
+}
+
+// UpdateSignCount only succeeds if the stored count is still the one the
+// assertion was checked against, so two concurrent logins with a cloned
+// authenticator can not both pass.
+func (r *passkeyRepository) UpdateSignCount(id string, from, to uint32) (bool, error) {
+	res := r.db.Model(&models.Passkey{}).
+		Where("id = ? AND sign_count = ?", id, from).
+		Updates(map[string]interface{}{"sign_count": to, "last_used_at": time.Now()})
+
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	Create(t *models.PasswordResetToken) error
+
+	FindByHash(hash string) (models.PasswordResetToken, error)
+	MarkUsed(id string) (bool, error)
+	DeleteByUserId(userId string) error
+	DeleteExpired() error
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	log.Info().Msg("Creating new password reset repository")
+
+	return &passwordResetRepository{
+		BaseRepository: NewBaseRepository[models.PasswordResetToken](db),
+		db:             db,
+	}
+}
+
+type passwordResetRepository struct {
+	BaseRepository[models.PasswordResetToken]
+	db *gorm.DB
+}
+
+func (r *passwordResetRepository) FindByHash(hash string) (models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.First(&token, "token_hash = ?", hash).Error
+
+	return token, err
+}
+
+func (r *passwordResetRepository) MarkUsed(id string) (bool, error) {
+	res := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *passwordResetRepository) DeleteByUserId(userId string) error {
+	return r.db.Where("user_id = ?", userId).Delete(&models.PasswordResetToken{}).Error
+}
+
+func (r *passwordResetRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&models.PasswordResetToken{}).Error
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	Create(t *models.RecoveryCode) error
+
+	FindUnused(userId, hash string) (models.RecoveryCode, error)
+	MarkUsed(id string) (bool, error)
+	DeleteByUserId(userId string) error
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	log.Info().Msg("Creating new recovery code repository")
+
+	return &recoveryCodeRepository{
+		BaseRepository: NewBaseRepository[models.RecoveryCode](db),
+		db:             db,
+	}
+}
+
+type recoveryCodeRepository struct {
+	BaseRepository[models.RecoveryCode]
+	db *gorm.DB
+}
+
+func (r *recoveryCodeRepository) FindUnused(userId, hash string) (models.RecoveryCode, error) {
+	var code models.RecoveryCode
+	err := r.db.First(&code, "user_id = ? AND code_hash = ? AND used_at IS NULL", userId, hash).Error
+
+	return code, err
+}
+
+func (r *recoveryCodeRepository) MarkUsed(id string) (bool, error) {
+	res := r.db.Model(&models.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *recoveryCodeRepository) DeleteByUserId(userId string) error {
+	return r.db.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(t *models.RefreshTokenRecord) error
+
+	FindByHash(hash string) (models.RefreshTokenRecord, error)
+	MarkUsed(id string) (bool, error)
+	RevokeFamily(familyId string) error
+	RevokeAllByUserId(userId string) error
+	RevokeAllByClientId(clientId string) error
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	log.Info().Msg("Creating new refresh token repository")
+
+	return &refreshTokenRepository{
+		BaseRepository: NewBaseRepository[models.RefreshTokenRecord](db),
+		db:             db,
+	}
+}
+
+type refreshTokenRepository struct {
+	BaseRepository[models.RefreshTokenRecord]
+	db *gorm.DB
+}
+
+func (r *refreshTokenRepository) FindByHash(hash string) (models.RefreshTokenRecord, error) {
+	var t models.RefreshTokenRecord
+	err := r.db.First(&t, "token_hash = ?", hash).Error
+
+	return t, err
+}
+
+// MarkUsed flags the token as used and reports whether this call was the one
+// that did it, so two concurrent refreshes cannot both succeed.
+func (r *refreshTokenRepository) MarkUsed(id string) (bool, error) {
+	res := r.db.Model(&models.RefreshTokenRecord{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyId string) error {
+	return r.db.Model(&models.RefreshTokenRecord{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeAllByUserId(userId string) error {
+	return r.db.Model(&models.RefreshTokenRecord{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeAllByClientId(clientId string) error {
+	return r.db.Model(&models.RefreshTokenRecord{}).
+		Where("client_id = ? AND revoked_at IS NULL", clientId).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevocationRepository interface {
+	RevokeToken(tokenId string, expiresAt time.Time) error
+	RevokeSession(sessionId string, expiresAt time.Time) error
+	RevokeUser(userId string, expiresAt time.Time) error
+	RevokeClient(clientId string, expiresAt time.Time) error
+	IsRevoked(tokenId, sessionId, userId, clientId string, issuedAt time.Time) (bool, error)
+	Prune() error
+}
+
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	log.Info().Msg("Creating new revocation repository")
+
+	return &revocationRepository{
+		db: db,
+	}
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+func (r *revocationRepository) RevokeToken(tokenId string, expiresAt time.Time) error {
+	return r.save(tokenKey(tokenId), expiresAt)
+}
+
+func (r *revocationRepository) RevokeSession(sessionId string, expiresAt time.Time) error {
+	return r.save(sessionKey(sessionId), expiresAt)
+}
+
+func (r *revocationRepository) RevokeUser(userId string, expiresAt time.Time) error {
+	return r.save(userKey(userId), expiresAt)
+}
+
+func (r *revocationRepository) RevokeClient(clientId string, expiresAt time.Time) error {
+	return r.save(clientKey(clientId), expiresAt)
+}
+
+func (r *revocationRepository) IsRevoked(tokenId, sessionId, userId, clientId string, issuedAt time.Time) (bool, error) {
+	var entries []models.RevokedToken
+	err := r.db.Where("key IN ? AND expires_at > ?", keys(tokenId, sessionId, userId, clientId), time.Now()).
+		Find(&entries).Error
+	if err != nil {
+		return false, err
+	}
+
+	for _, e := range entries {
+		if isRevoked(e, tokenId, sessionId, issuedAt) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *revocationRepository) Prune() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&models.RevokedToken{}).Error
+}
+
+func (r *revocationRepository) save(key string, expiresAt time.Time) error {
+	entry := models.RevokedToken{
+		Key:       key,
+		RevokedAt: time.Now().Truncate(time.Second),
+		ExpiresAt: expiresAt,
+	}
+
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error
+}
+
+func NewMemoryRevocationRepository() RevocationRepository {
+	log.Info().Msg("Creating new in-memory revocation repository")
+
+	return &memoryRevocationRepository{
+		entries: make(map[string]models.RevokedToken),
+	}
+}
+
+type memoryRevocationRepository struct {
+	mu      sync.RWMutex
+	entries map[string]models.RevokedToken
+}
+
+func (r *memoryRevocationRepository) RevokeToken(tokenId string, expiresAt time.Time) error {
+	r.save(tokenKey(tokenId), expiresAt)
+	return nil
+}
+
+func (r *memoryRevocationRepository) RevokeSession(sessionId string, expiresAt time.Time) error {
+	r.save(sessionKey(sessionId), expiresAt)
+	return nil
+}
+
+func (r *memoryRevocationRepository) RevokeUser(userId string, expiresAt time.Time) error {
+	r.save(userKey(userId), expiresAt)
+	return nil
+}
+
+func (r *memoryRevocationRepository) RevokeClient(clientId string, expiresAt time.Time) error {
+	r.save(clientKey(clientId), expiresAt)
+	return nil
+}
+
+func (r *memoryRevocationRepository) IsRevoked(tokenId, sessionId, userId, clientId string, issuedAt time.Time) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range keys(tokenId, sessionId, userId, clientId) {
+		e, ok := r.entries[key]
+		if ok && now.Before(e.ExpiresAt) && isRevoked(e, tokenId, sessionId, issuedAt) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryRevocationRepository) Prune() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for key, e := range r.entries {
+		if !now.Before(e.ExpiresAt) {
+			delete(r.entries, key)
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRevocationRepository) save(key string, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[key] = models.RevokedToken{
+		Key:       key,
+		RevokedAt: time.Now().Truncate(time.Second),
+		ExpiresAt: expiresAt,
+	}
+}
+
+func tokenKey(tokenId string) string {
+	return "jti:" + tokenId
+}
+
+func sessionKey(sessionId string) string {
+	return "sid:" + sessionId
+}
+
+func userKey(userId string) string {
+	return "user:" + userId
+}
+
+func clientKey(clientId string) string {
+	return "client:" + clientId
+}
+
+// keys are the entries that could revoke a token, tokens without a session
+// or client are only looked up by jti and user.
+func keys(tokenId, sessionId, userId, clientId string) []string {
+	keys := []string{tokenKey(tokenId), userKey(userId)}
+	if sessionId != "" {
+		keys = append(keys, sessionKey(sessionId))
+	}
+	if clientId != "" {
+		keys = append(keys, clientKey(clientId))
+	}
+
+	return keys
+}
+
+// isRevoked reports whether the entry matches the token. Token and session
+// entries revoke every token they match, user and client entries revoke
+// tokens issued before them. iat has second precision, so entries are saved
+// truncated to the second and the tokens issued right after a revocation,
+// e.g. on a password change, stay valid.
+func isRevoked(e models.RevokedToken, tokenId, sessionId string, issuedAt time.Time) bool {
+	if e.Key == tokenKey(tokenId) || (sessionId != "" && e.Key == sessionKey(sessionId)) {
+		return true
+	}
+
+	return issuedAt.Before(e.RevokedAt)
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationSameSecond(t *testing.T) {
+	r := NewMemoryRevocationRepository()
+
+	// iat is truncated to the second, like the claims of a signed token.
+	before := time.Now().Truncate(time.Second).Add(-time.Second)
+
+	err := r.RevokeUser("u1", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("RevokeUser() error = %v", err)
+	}
+
+	err = r.RevokeToken("t2", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+
+	sameSecond := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		tokenId  string
+		userId   string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued before", "t1", "u1", before, true},
+		{"issued in the same second", "t1", "u1", sameSecond, false},
+		{"issued after", "t1", "u1", sameSecond.Add(time.Second), false},
+		{"other user", "t1", "u2", before, false},
+		{"revoked token", "t2", "u2", sameSecond, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.IsRevoked(tt.tokenId, "", tt.userId, "", tt.issuedAt)
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository interface {
+	FindAll() ([]models.Role, error)
+	FindByName(name string) (models.Role, error)
+	Create(t *models.Role) error
+	Update(t *models.Role) error
+	Delete(t *models.Role) error
+
+	// Seed creates the roles that do not exist yet and leaves the others
+	// as they were changed by admins.
+	Seed(roles []models.Role) error
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	log.Info().Msg("Creating new role repository")
+
+	return &roleRepository{
+		db: db,
+	}
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func (r *roleRepository) FindAll() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Order("name").Find(&roles).Error
+
+	return roles, err
+}
+
+func (r *roleRepository) FindByName(name string) (models.Role, error) {
+	var role models.Role
+	err := r.db.First(&role, "name = ?", name).Error
+
+	return role, err
+}
+
+func (r *roleRepository) Create(t *models.Role) error {
+	return r.db.Create(t).Error
+}
+
+func (r *roleRepository) Update(t *models.Role) error {
+	return r.db.Save(t).Error
+}
+
+func (r *roleRepository) Delete(t *models.Role) error {
+	return r.db.Delete(t).Error
+}
+
+func (r *roleRepository) Seed(roles []models.Role) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&roles).Error
+}
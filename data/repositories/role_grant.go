@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleGrantRepository interface {
+	// Save creates the grant or moves the expiry of an existing one.
+	Save(t *models.RoleGrant) error
+	Delete(userId, role string) error
+	FindExpired() ([]models.RoleGrant, error)
+}
+
+func NewRoleGrantRepository(db *gorm.DB) RoleGrantRepository {
+	log.Info().Msg("Creating new role grant repository")
+
+	return &roleGrantRepository{
+		db: db,
+	}
+}
+
+type roleGrantRepository struct {
+	db *gorm.DB
+}
+
+func (r *roleGrantRepository) Save(t *models.RoleGrant) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(t).Error
+}
+
+func (r *roleGrantRepository) Delete(userId, role string) error {
+	return r.db.Where("user_id = ? AND role = ?", userId, role).Delete(&models.RoleGrant{}).Error
+}
+
+func (r *roleGrantRepository) FindExpired() ([]models.RoleGrant, error) {
+	var grants []models.RoleGrant
+	err := r.db.Where("expires_at < ?", time.Now()).Find(&grants).Error
+
+	return grants, err
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(t *models.Session) error
+
+	FindActiveByUserId(userId string) ([]models.Session, error)
+	Touch(id string, client models.ClientInfo, expiresAt time.Time) error
+	Revoke(userId, id string) (bool, error)
+	RevokeAllByUserId(userId string) error
+	DeleteExpired() error
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	log.Info().Msg("Creating new session repository")
+
+	return &sessionRepository{
+		BaseRepository: NewBaseRepository[models.Session](db),
+		db:             db,
+	}
+}
+
+type sessionRepository struct {
+	BaseRepository[models.Session]
+	db *gorm.DB
+}
+
+func (r *sessionRepository) FindActiveByUserId(userId string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+
+	return sessions, err
+}
+
+// Touch records that the session was used again from the client and
+// extends it by the lifespan of the rotated refresh token.
+func (r *sessionRepository) Touch(id string, client models.ClientInfo, expiresAt time.Time) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"ip":           client.IP,
+			"user_agent":   client.UserAgent,
+			"last_seen_at": time.Now(),
+			"expires_at":   expiresAt,
+		}).Error
+}
+
+func (r *sessionRepository) Revoke(userId, id string) (bool, error) {
+	res := r.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *sessionRepository) RevokeAllByUserId(userId string) error {
+	return r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// DeleteExpired removes sessions whose refresh tokens have expired and
+// sessions that were revoked before that.
+func (r *sessionRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ? OR revoked_at IS NOT NULL", time.Now()).Delete(&models.Session{}).Error
+}
@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/Marcel-MD/clean-api/models"
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
@@ -14,6 +17,17 @@ type UserRepository interface {
 	Delete(t *models.User) error
 
 	FindByEmail(email string) (models.User, error)
+	CountByRole(role string) (int64, error)
+	// CountByRoleSets returns every combination of roles users hold,
+	// directly or through their groups, with the number of users holding it.
+	CountByRoleSets() ([]RoleSet, error)
+}
+
+// RoleSet is a combination of roles and the number of users holding exactly
+// those roles before inheritance.
+type RoleSet struct {
+	Roles []string
+	Users int64
 }
 
 func NewUserRepository(db *gorm.DB) UserRepository {
@@ -36,3 +50,48 @@ func (r *userRepository) FindByEmail(email string) (models.User, error) {
 
 	return user, err
 }
+
+func (r *userRepository) CountByRole(role string) (int64, error) {
+	roles, err := json.Marshal([]string{role})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = r.db.Model(&models.User{}).Where("roles @> ?::jsonb", string(roles)).Count(&count).Error
+
+	return count, err
+}
+
+func (r *userRepository) CountByRoleSets() ([]RoleSet, error) {
+	var rows []struct {
+		Roles string
+		Users int64
+	}
+
+	// Users created without roles hold a JSON null instead of an array.
+	err := r.db.Raw(`
+		SELECT held.roles AS roles, count(*) AS users FROM (
+			SELECT granted.user_id, string_agg(DISTINCT granted.role, ',' ORDER BY granted.role) AS roles FROM (
+				SELECT users.id AS user_id, r.role
+				FROM users, jsonb_array_elements_text(CASE WHEN jsonb_typeof(users.roles) = 'array' THEN users.roles ELSE '[]' END) AS r(role)
+				UNION
+				SELECT group_members.user_id, r.role
+				FROM group_members JOIN groups ON groups.id = group_members.group_id,
+					jsonb_array_elements_text(CASE WHEN jsonb_typeof(groups.roles) = 'array' THEN groups.roles ELSE '[]' END) AS r(role)
+			) AS granted
+			GROUP BY granted.user_id
+		) AS held
+		GROUP BY held.roles`).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]RoleSet, len(rows))
+	for i, row := range rows {
+		sets[i] = RoleSet{Roles: strings.Split(row.Roles, ","), Users: row.Users}
+	}
+
+	return sets, nil
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type WebauthnSessionRepository interface {
+	Create(t *models.WebauthnSession) error
+	Delete(t *models.WebauthnSession) error
+
+	FindByChallengeHash(hash string) (models.WebauthnSession, error)
+	Consume(id string) (bool, error)
+	DeleteExpired() error
+}
+
+func NewWebauthnSessionRepository(db *gorm.DB) WebauthnSessionRepository {
+	log.Info().Msg("Creating new webauthn session repository")
+
+	return &webauthnSessionRepository{
+		BaseRepository: NewBaseRepository[models.WebauthnSession](db),
+		db:             db,
+	}
+}
+
+type webauthnSessionRepository struct {
+	BaseRepository[models.WebauthnSession]
+	db *gorm.DB
+}
+
+func (r *webauthnSessionRepository) FindByChallengeHash(hash string) (models.WebauthnSession, error) {
+	var session models.WebauthnSession
+	err := r.db.First(&session, "challenge_hash = ?", hash).Error
+
+	return session, err
+}
+
+// Consume deletes the session and reports whether it was still there, so
+// only one of two concurrent ceremonies can finish with it.
+func (r *webauthnSessionRepository) Consume(id string) (bool, error) {
+	res := r.db.Where("id = ?", id).Delete(&models.WebauthnSession{})
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *webauthnSessionRepository) DeleteExpired() error {
+	return r.db.Where("expires_at <= ?", time.Now()).Delete(&models.WebauthnSession{}).Error
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Every runs job once per interval until ctx is cancelled. Errors are logged
+// and do not stop the schedule.
+func Every(ctx context.Context, name string, interval time.Duration, job func() error) {
+	log.Info().Str("job", name).Dur("interval", interval).Msg("Starting background job")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("job", name).Msg("Stopping background job")
+			return
+		case <-ticker.C:
+			if err := job(); err != nil {
+				log.Error().Err(err).Str("job", name).Msg("Background job failed")
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import "github.com/rs/zerolog/log"
+
+// NewLogMailer returns a mailer that only logs messages, for development.
+// Bodies carry sign in and reset links, so it must not be used elsewhere.
+func NewLogMailer() Mailer {
+	log.Info().Msg("Creating new log mailer")
+
+	return &logMailer{}
+}
+
+type logMailer struct{}
+
+func (m *logMailer) Send(msg Message) error {
+	log.Info().Str("to", msg.To).Str("subject", msg.Subject).Str("body", msg.Body).Msg("Sending email")
+	return nil
+}
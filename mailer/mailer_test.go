@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	data, err := build("no-reply@example.com", Message{
+		To:      "user@example.com",
+		Subject: "Vérifiez",
+		Body:    "line 1\nline 2",
+	})
+	if err != nil {
+		t.Fatalf("build() error = %v", err)
+	}
+
+	msg := string(data)
+	for _, want := range []string{
+		"From: no-reply@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: =?utf-8?q?V=C3=A9rifiez?=\r\n",
+		"\r\n\r\nline 1\r\nline 2",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message is missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestBuildRejectsHeaderInjection(t *testing.T) {
+	_, err := build("no-reply@example.com", Message{To: "a@example.com\r\nBcc: b@example.com"})
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("build() error = %v, want %v", err, ErrInvalidHeader)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	_ = m.Send(Message{To: "a@example.com", Subject: "1"})
+	_ = m.Send(Message{To: "b@example.com", Subject: "2"})
+	_ = m.Send(Message{To: "a@example.com", Subject: "3"})
+
+	if len(m.Messages()) != 3 {
+		t.Fatalf("Messages() = %d, want 3", len(m.Messages()))
+	}
+
+	last, ok := m.Last("a@example.com")
+	if !ok || last.Subject != "3" {
+		t.Fatalf("Last() = %+v, %v", last, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+
+	"github.com/rs/zerolog/log"
+)
+
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	log.Info().Msg("Creating new smtp mailer")
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// Send delivers the message, STARTTLS is used when the server offers it.
+func (m *smtpMailer) Send(msg Message) error {
+	data, err := build(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, data)
+}
@@ -11,11 +11,15 @@ import (
 
 	"github.com/Marcel-MD/clean-api/api"
 	"github.com/Marcel-MD/clean-api/api/controllers"
+	"github.com/Marcel-MD/clean-api/auth/password"
 	"github.com/Marcel-MD/clean-api/config"
 	"github.com/Marcel-MD/clean-api/data"
 	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/jobs"
+	"github.com/Marcel-MD/clean-api/mailer"
 	"github.com/Marcel-MD/clean-api/services"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 // @title Clean API
@@ -25,7 +29,7 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer" followed by a space and JWT token
+// @description Type "Bearer" followed by a space and a JWT token or API key
 func main() {
 	cfg, err := config.NewConfig()
 	if err != nil {
@@ -37,12 +41,133 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
 	}
 
-	// User
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Key
+	keyService, err := services.NewKeyService(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load signing keys")
+	}
+	keyController := controllers.NewKeyController(keyService)
+
+	if cfg.JwtKeyRotationInterval > 0 {
+		go jobs.Every(jobsCtx, "rotate signing keys", cfg.JwtKeyRotationInterval, keyService.Rotate)
+	}
+
+	// Token
+	refreshTokenRepository := repositories.NewRefreshTokenRepository(db)
+	revocationRepository := newRevocationRepository(cfg, db)
+	sessionRepository := repositories.NewSessionRepository(db)
+	apiKeyRepository := repositories.NewApiKeyRepository(db)
 	userRepository := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepository, cfg)
+	go jobs.Every(jobsCtx, "prune revoked tokens", cfg.RevocationPruneInterval, revocationRepository.Prune)
+
+	// Role
+	groupRepository := repositories.NewGroupRepository(db)
+	roleService := services.NewRoleService(repositories.NewRoleRepository(db), userRepository, groupRepository, cfg)
+	if err := roleService.Seed(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to seed roles")
+	}
+	roleController := controllers.NewRoleController(roleService)
+	groupService := services.NewGroupService(groupRepository, userRepository, roleService)
+	groupController := controllers.NewGroupController(groupService)
+	authorizationService, err := services.NewAuthorizationService(roleService, cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load authorization policy")
+	}
+
+	// User
+	tokenService := services.NewTokenService(refreshTokenRepository, revocationRepository, sessionRepository, apiKeyRepository, userRepository, groupService, keyService, cfg)
+	recoveryCodeRepository := repositories.NewRecoveryCodeRepository(db)
+	lockoutService := services.NewLockoutService(newLoginAttemptRepository(cfg, db), cfg)
+	mfaService := services.NewMfaService(recoveryCodeRepository, userRepository, tokenService, lockoutService, cfg)
+	mfaController := controllers.NewMfaController(mfaService)
+	mailSender, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create mailer")
+	}
+	verificationService := services.NewVerificationService(userRepository, tokenService, mailSender, cfg)
+	passwordPolicy, err := newPasswordPolicy(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load password policy")
+	}
+	passwordHasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create password hasher")
+	}
+	lockoutController := controllers.NewLockoutController(lockoutService)
+	go jobs.Every(jobsCtx, "prune login attempts", cfg.LoginAttemptWindow, lockoutService.Prune)
+	userService, err := services.NewUserService(userRepository, repositories.NewRoleGrantRepository(db), roleService, tokenService, mfaService, verificationService, lockoutService, authorizationService, passwordPolicy, passwordHasher, cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create user service")
+	}
 	userController := controllers.NewUserController(userService)
+	go jobs.Every(jobsCtx, "remove expired role grants", cfg.RoleGrantSweepInterval, userService.RemoveExpiredRoles)
+
+	// Passkey
+	passkeyRepository := repositories.NewPasskeyRepository(db)
+	webauthnSessionRepository := repositories.NewWebauthnSessionRepository(db)
+	passkeyService := services.NewPasskeyService(passkeyRepository, webauthnSessionRepository, userRepository, tokenService, mfaService, cfg)
+	passkeyController := controllers.NewPasskeyController(passkeyService)
+	go jobs.Every(jobsCtx, "delete expired webauthn sessions", cfg.WebauthnTimeout, passkeyService.DeleteExpired)
+
+	// API key
+	apiKeyService := services.NewApiKeyService(apiKeyRepository, userRepository, groupService, roleService)
+	apiKeyController := controllers.NewApiKeyController(apiKeyService)
+
+	// Audit
+	auditService := services.NewAuditService(repositories.NewAuditRepository(db))
+	auditController := controllers.NewAuditController(auditService)
+
+	// Impersonation
+	impersonationService := services.NewImpersonationService(userRepository, groupService, roleService, tokenService, auditService)
+	impersonationController := controllers.NewImpersonationController(impersonationService)
+
+	// Session
+	sessionService := services.NewSessionService(sessionRepository, tokenService)
+	sessionController := controllers.NewSessionController(sessionService)
+	go jobs.Every(jobsCtx, "delete expired sessions", cfg.SessionPruneInterval, sessionService.DeleteExpired)
+
+	// Password
+	passwordResetRepository := repositories.NewPasswordResetRepository(db)
+	passwordService := services.NewPasswordService(passwordResetRepository, userRepository, tokenService, mailSender, passwordPolicy, passwordHasher, cfg)
+	passwordController := controllers.NewPasswordController(passwordService)
+	go jobs.Every(jobsCtx, "delete expired password reset tokens", cfg.PasswordResetTokenLifespan, passwordService.DeleteExpired)
+
+	// Magic link
+	magicLinkRepository := repositories.NewMagicLinkRepository(db)
+	magicLinkService := services.NewMagicLinkService(magicLinkRepository, userRepository, tokenService, mfaService, mailSender, cfg)
+	magicLinkController := controllers.NewMagicLinkController(magicLinkService, cfg)
+	go jobs.Every(jobsCtx, "delete expired magic links", cfg.MagicLinkLifespan, magicLinkService.DeleteExpired)
 
-	srv := api.NewServer(cfg, userController)
+	// OIDC
+	oidcStateRepository := repositories.NewOidcStateRepository(db)
+	oidcService := services.NewOidcService(oidcStateRepository, userRepository, tokenService, mfaService, cfg)
+	oidcController := controllers.NewOidcController(oidcService, cfg)
+	go jobs.Every(jobsCtx, "delete expired oidc states", cfg.OidcStateLifespan, oidcService.DeleteExpired)
+
+	// Organization
+	organizationRepository := repositories.NewOrganizationRepository(db)
+	membershipRepository := repositories.NewMembershipRepository(db)
+	organizationService := services.NewOrganizationService(organizationRepository, membershipRepository)
+	organizationController := controllers.NewOrganizationController(organizationService)
+	invitationService := services.NewInvitationService(repositories.NewInvitationRepository(db), organizationRepository, membershipRepository, userRepository, userService, tokenService, mailSender, cfg)
+	invitationController := controllers.NewInvitationController(invitationService)
+
+	// OAuth
+	oauthClientRepository := repositories.NewOAuthClientRepository(db)
+	oauthCodeRepository := repositories.NewOAuthCodeRepository(db)
+	var oauthController controllers.OAuthController
+	oauthService, err := services.NewOAuthService(oauthClientRepository, oauthCodeRepository, userRepository, tokenService, roleService, keyService, cfg)
+	if err != nil {
+		log.Warn().Err(err).Msg("OAuth provider is disabled, set JWT_ALGORITHM to RS256, ES256 or EdDSA to enable it")
+	} else {
+		oauthController = controllers.NewOAuthController(oauthService)
+		go jobs.Every(jobsCtx, "delete expired oauth codes", cfg.OAuthCodeLifespan, oauthService.DeleteExpiredCodes)
+	}
+
+	srv := api.NewServer(cfg, tokenService, roleService, organizationService, keyController, userController, mfaController, passkeyController, apiKeyController, sessionController, passwordController, magicLinkController, lockoutController, impersonationController, auditController, oidcController, oauthController, roleController, organizationController, invitationController, groupController)
 
 	go func() {
 		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
@@ -56,6 +181,7 @@ func main() {
 
 	<-quit
 	log.Warn().Msg("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -70,3 +196,70 @@ func main() {
 
 	log.Info().Msg("Server exited properly")
 }
+
+func newRevocationRepository(cfg config.Config, db *gorm.DB) repositories.RevocationRepository {
+	if cfg.RevocationStore == "memory" {
+		return repositories.NewMemoryRevocationRepository()
+	}
+
+	return repositories.NewRevocationRepository(db)
+}
+
+func newLoginAttemptRepository(cfg config.Config, db *gorm.DB) repositories.LoginAttemptRepository {
+	if cfg.LoginAttemptStore == "memory" {
+		return repositories.NewMemoryLoginAttemptRepository()
+	}
+
+	return repositories.NewLoginAttemptRepository(db)
+}
+
+func newPasswordHasher(cfg config.Config) (password.Hasher, error) {
+	return password.NewHasher(password.HasherConfig{
+		Algorithm: cfg.PasswordHashAlgorithm,
+		Argon2id: password.Argon2idParams{
+			Memory:      cfg.PasswordArgon2Memory,
+			Iterations:  cfg.PasswordArgon2Iterations,
+			Parallelism: cfg.PasswordArgon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		BcryptCost: cfg.PasswordBcryptCost,
+	})
+}
+
+func newPasswordPolicy(cfg config.Config) (*password.Policy, error) {
+	policy := &password.Policy{
+		MinLength:     cfg.PasswordMinLength,
+		MaxLength:     cfg.PasswordMaxLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		BannedWords:   cfg.PasswordBannedWords,
+	}
+
+	if cfg.PasswordBreachedListFile != "" {
+		err := policy.LoadBreached(cfg.PasswordBreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+func newMailer(cfg config.Config) (mailer.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SmtpHost, cfg.SmtpPort, cfg.SmtpUsername, cfg.SmtpPassword, cfg.MailFrom), nil
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	}
+
+	// The log mailer writes the links of every message to the logs.
+	if cfg.Env != "dev" {
+		return nil, errors.New("the log mailer is only allowed in development, set MAIL_DRIVER")
+	}
+
+	return mailer.NewLogMailer(), nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ApiKeyPrefix marks a bearer credential as an API key instead of a JWT.
+const ApiKeyPrefix = "cak_"
+
+const (
+	ApiScopeRead  = "read"
+	ApiScopeWrite = "write"
+	ApiScopeAdmin = "admin"
+)
+
+// ApiKey is a long lived credential of a user for scripts and integrations.
+// Only its hash is stored, Prefix is kept so the owner can recognize it.
+type ApiKey struct {
+	Base
+
+	UserID     string                      `json:"user_id" gorm:"index"`
+	Name       string                      `json:"name"`
+	Prefix     string                      `json:"prefix"`
+	KeyHash    string                      `json:"-" gorm:"uniqueIndex"`
+	Scopes     datatypes.JSONSlice[string] `json:"scopes"`
+	ExpiresAt  *time.Time                  `json:"expires_at"`
+	RevokedAt  *time.Time                  `json:"revoked_at"`
+	LastUsedAt *time.Time                  `json:"last_used_at"`
+	LastUsedIP string                      `json:"last_used_ip"`
+}
+
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type CreateApiKey struct {
+	Name      string     `json:"name" binding:"required,min=3,max=50"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read write admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ApiKeyCredentials is returned once when a key is created, the key can not
+// be retrieved afterwards.
+type ApiKeyCredentials struct {
+	ApiKey ApiKey `json:"api_key"`
+	Key    string `json:"key"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// Audit actions.
+const (
+	AuditImpersonationStart = "impersonation.start"
+	AuditImpersonationStop  = "impersonation.stop"
+)
+
+// AuditEvent records a security relevant action. The actor performed the
+// action on the subject, both are user IDs.
+type AuditEvent struct {
+	Base
+
+	Action    string `json:"action" gorm:"index"`
+	ActorID   string `json:"actor_id" gorm:"index"`
+	SubjectID string `json:"subject_id" gorm:"index"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Details   string `json:"details"`
+}
+
+type AuditQuery struct {
+	PaginationQuery
+
+	Action    string `form:"action"`
+	ActorID   string `form:"actor_id"`
+	SubjectID string `form:"subject_id"`
+}
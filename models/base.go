@@ -20,3 +20,19 @@ func (b *Base) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// Tenant is embedded by models owned by an organization. Repositories
+// created with repositories.NewTenantRepository only see the rows of one.
+type Tenant struct {
+	OrganizationID string `json:"organization_id" gorm:"index;not null"`
+}
+
+func (t *Tenant) SetOrganizationID(id string) {
+	t.OrganizationID = id
+}
+
+// TenantScoped is implemented by every model owned by an organization,
+// usually by embedding Tenant.
+type TenantScoped interface {
+	SetOrganizationID(id string)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Group holds roles that every member inherits on top of their own.
+type Group struct {
+	Base
+
+	Name        string                      `json:"name" gorm:"uniqueIndex"`
+	Description string                      `json:"description"`
+	Roles       datatypes.JSONSlice[string] `json:"roles"`
+}
+
+type GroupMember struct {
+	GroupID   string    `json:"group_id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Group *Group `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	User  *User  `json:"user,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type CreateGroup struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50"`
+	Description string   `json:"description" binding:"max=200"`
+	Roles       []string `json:"roles"`
+}
+
+type UpdateGroup struct {
+	Description string   `json:"description" binding:"max=200"`
+	Roles       []string `json:"roles" binding:"required"`
+}
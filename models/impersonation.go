@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type StartImpersonation struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// ImpersonationToken is an access token for acting as the user. It can not
+// be refreshed.
+type ImpersonationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation asks someone to join an organization by email. Only the hash
+// of the link token is stored. Expired invitations stay pending in the
+// database and are reported as expired.
+type Invitation struct {
+	Base
+	Tenant
+
+	Email       string     `json:"email" gorm:"index"`
+	Role        string     `json:"role"`
+	InviterID   string     `json:"inviter_id"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex"`
+	Status      string     `json:"status"`
+	SentAt      time.Time  `json:"sent_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+
+	Organization *Organization `json:"organization,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type CreateInvitation struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// AcceptInvitation accepts an invitation. Name and optionally a password
+// are needed when no account exists for the invited email yet.
+type AcceptInvitation struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"omitempty,min=3,max=50"`
+	Password string `json:"password"`
+}
+
+type DeclineInvitation struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type InvitationQuery struct {
+	Token string `form:"token" binding:"required"`
+}
+
+// InvitationPreview is shown on the invitation page before it is accepted.
+// Registered tells whether accepting needs a name to create the account.
+type InvitationPreview struct {
+	Organization string    `json:"organization"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Registered   bool      `json:"registered"`
+}
+
+// AcceptedInvitation holds the new membership, and tokens when accepting
+// created the account.
+type AcceptedInvitation struct {
+	Membership Membership `json:"membership"`
+	Token      *Token     `json:"token,omitempty"`
+}
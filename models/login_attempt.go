@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for a key, which is an account
+// ("account:<email>"), a client address ("ip:<address>") or the second
+// factor of a user ("mfa:<user id>").
+type LoginAttempt struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"index"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// ClientInfo describes the client a request came from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
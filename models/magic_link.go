@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// MagicLink is a pending passwordless login. The link token and the nonce
+// kept in the requesting browser are only stored hashed.
+type MagicLink struct {
+	Base
+
+	UserID    string     `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	NonceHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+type RequestMagicLink struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkCallback struct {
+	Token string `form:"token" binding:"required"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single use code that replaces a TOTP code when the user
+// lost access to their authenticator. Only its hash is stored.
+type RecoveryCode struct {
+	Base
+
+	UserID   string     `json:"user_id" gorm:"index"`
+	CodeHash string     `json:"-" gorm:"index"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+type TotpEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MfaCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
+
+// MfaChallenge is returned by login instead of a Token when the user has to
+// complete a second factor.
+type MfaChallenge struct {
+	MfaRequired bool   `json:"mfa_required"`
+	MfaToken    string `json:"mfa_token"`
+}
+
+type LoginMfa struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// OAuthClient is an application allowed to request tokens on behalf of our
+// users. Public clients have no secret and must always use PKCE. Machine
+// clients act on their own behalf through the client credentials grant,
+// their scopes are API key scopes.
+type OAuthClient struct {
+	Base
+
+	Name         string                      `json:"name"`
+	SecretHash   string                      `json:"-"`
+	Public       bool                        `json:"public"`
+	Machine      bool                        `json:"machine"`
+	RedirectURIs datatypes.JSONSlice[string] `json:"redirect_uris"`
+	Scopes       datatypes.JSONSlice[string] `json:"scopes"`
+	OwnerID      string                      `json:"owner_id" gorm:"index"`
+}
+
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *OAuthClient) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OAuthAuthorizationCode is a pending authorization code. Only its hash is
+// stored. FamilyID is the refresh token family issued for the code so the
+// tokens can be revoked if the code is replayed.
+type OAuthAuthorizationCode struct {
+	Base
+
+	CodeHash      string     `json:"-" gorm:"uniqueIndex"`
+	ClientID      string     `json:"client_id" gorm:"index"`
+	UserID        string     `json:"user_id" gorm:"index"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scope         string     `json:"scope"`
+	Nonce         string     `json:"-"`
+	CodeChallenge string     `json:"-"`
+	FamilyID      string     `json:"-"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt        *time.Time `json:"used_at"`
+}
+
+type CreateOAuthClient struct {
+	Name         string   `json:"name" binding:"required,min=3,max=50"`
+	Public       bool     `json:"public"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+}
+
+type CreateMachineClient struct {
+	Name   string   `json:"name" binding:"required,min=3,max=50"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=read write admin"`
+}
+
+// OAuthClientCredentials is returned once when a client is created, the
+// secret can not be retrieved afterwards.
+type OAuthClientCredentials struct {
+	Client       OAuthClient `json:"client"`
+	ClientSecret string      `json:"client_secret,omitempty"`
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge" binding:"required"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"required"`
+}
+
+type AuthorizeDecision struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+type ScopeDescription struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ConsentScreen is what the frontend shows the user before they approve an
+// authorization request.
+type ConsentScreen struct {
+	ClientID    string             `json:"client_id"`
+	ClientName  string             `json:"client_name"`
+	RedirectURI string             `json:"redirect_uri"`
+	Scopes      []ScopeDescription `json:"scopes"`
+	State       string             `json:"state"`
+}
+
+type AuthorizeResponse struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// IntrospectionRequest is a token introspection request as defined by RFC
+// 7662, the caller authenticates as a confidential client.
+type IntrospectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectionResponse describes an active token. Inactive tokens only
+// report active false. TokenType is the type of token as issued by the API,
+// access or refresh.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	JwtID     string   `json:"jti,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	ActorID   string   `json:"act_sub,omitempty"`
+}
+
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// OidcState is a pending external login. It holds the secrets needed to
+// finish the authorization code flow and is deleted once used.
+type OidcState struct {
+	Base
+
+	Provider     string    `json:"provider"`
+	StateHash    string    `json:"-" gorm:"uniqueIndex"`
+	Nonce        string    `json:"-"`
+	CodeVerifier string    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+}
+
+type OidcCallback struct {
+	State string `form:"state" binding:"required"`
+	Code  string `form:"code" binding:"required"`
+}
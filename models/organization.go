@@ -0,0 +1,48 @@
+package models
+
+// Roles of a member within an organization, unrelated to the global roles
+// of the user.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// OrganizationHeader selects the active organization of a request.
+const OrganizationHeader = "X-Organization-ID"
+
+type Organization struct {
+	Base
+
+	Name string `json:"name"`
+	Slug string `json:"slug" gorm:"uniqueIndex"`
+
+	// Role of the current user, only set when listing their organizations.
+	Role string `json:"role,omitempty" gorm:"->;-:migration"`
+}
+
+// Membership makes a user part of an organization with an organization
+// scoped role. It declares the tenant column itself, so a user can only be
+// a member of an organization once.
+type Membership struct {
+	Base
+
+	OrganizationID string `json:"organization_id" gorm:"not null;uniqueIndex:idx_membership_org_user"`
+	UserID         string `json:"user_id" gorm:"index;uniqueIndex:idx_membership_org_user"`
+	Role           string `json:"role"`
+
+	User *User `json:"user,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (m *Membership) SetOrganizationID(id string) {
+	m.OrganizationID = id
+}
+
+type CreateOrganization struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+	Slug string `json:"slug" binding:"required,min=2,max=50"`
+}
+
+type UpdateMembership struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
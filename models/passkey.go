@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth/webauthn"
+)
+
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+// Passkey is a WebAuthn credential registered by a user.
+type Passkey struct {
+	Base
+
+	UserID       string     `json:"user_id" gorm:"index"`
+	Name         string     `json:"name"`
+	CredentialID string     `json:"credential_id" gorm:"uniqueIndex"`
+	PublicKey    []byte     `json:"-"`
+	Algorithm    int64      `json:"algorithm"`
+	SignCount    uint32     `json:"-"`
+	AAGUID       string     `json:"aaguid"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// WebauthnSession is a pending registration or login ceremony. It is found
+// by the hash of the challenge echoed in the client data and deleted once
+// used. UserID is empty for passwordless logins.
+type WebauthnSession struct {
+	Base
+
+	ChallengeHash string    `json:"-" gorm:"uniqueIndex"`
+	Ceremony      string    `json:"ceremony"`
+	UserID        string    `json:"user_id"`
+	ExpiresAt     time.Time `json:"expires_at" gorm:"index"`
+}
+
+type RegisterPasskey struct {
+	Name       string                        `json:"name" binding:"max=50"`
+	Credential webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
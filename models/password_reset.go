@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single use token mailed to a user who forgot
+// their password. Only its hash is stored.
+type PasswordResetToken struct {
+	Base
+
+	UserID    string     `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Actions decided by the authorization policy, see services.AuthorizationService.
+const (
+	ActionUsersList   = "users:list"
+	ActionUsersRead   = "users:read"
+	ActionUsersDelete = "users:delete"
+)
+
+// Resource types passed to the authorization policy.
+const (
+	ResourceUser = "user"
+)
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RefreshTokenRecord is the persisted form of an issued refresh token.
+// Only the hash of the token is stored. Tokens rotated from the same
+// login share a FamilyID so the whole chain can be revoked at once.
+type RefreshTokenRecord struct {
+	Base
+
+	UserID      string     `json:"user_id" gorm:"index"`
+	FamilyID    string     `json:"family_id" gorm:"index"`
+	ClientID    string     `json:"client_id"`
+	Scope       string     `json:"scope"`
+	AuthMethods string     `json:"auth_methods"`
+	AuthTime    time.Time  `json:"auth_time"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RevokedToken is a denylist entry. Key is either the jti of a single access
+// token, a session key revoking every token of a device session, or a user
+// key revoking every token issued to that user before RevokedAt. Entries
+// are only kept until ExpiresAt, once every token they could match has
+// expired on its own.
+type RevokedToken struct {
+	Key       string    `json:"key" gorm:"primaryKey"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
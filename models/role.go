@@ -0,0 +1,142 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Permissions checked by the API. Roles can also hold "*" for every
+// permission or "<resource>:*" for every permission on a resource.
+const (
+	PermissionUsersRead        = "users:read"
+	PermissionUsersDelete      = "users:delete"
+	PermissionUsersRoles       = "users:roles"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionLockoutsManage   = "lockouts:manage"
+	PermissionAuditRead        = "audit:read"
+	PermissionOAuthClients     = "oauth:clients"
+	PermissionRolesManage      = "roles:manage"
+	PermissionGroupsManage     = "groups:manage"
+)
+
+var Permissions = []string{
+	PermissionUsersRead,
+	PermissionUsersDelete,
+	PermissionUsersRoles,
+	PermissionUsersImpersonate,
+	PermissionLockoutsManage,
+	PermissionAuditRead,
+	PermissionOAuthClients,
+	PermissionRolesManage,
+	PermissionGroupsManage,
+}
+
+// ScopePermissions are the permissions granted by the scopes of machine
+// clients. Clients hold no roles, so this is all they can do.
+var ScopePermissions = map[string][]string{
+	ApiScopeRead: {PermissionUsersRead},
+	ApiScopeAdmin: {
+		PermissionUsersRead,
+		PermissionUsersDelete,
+		PermissionUsersRoles,
+		PermissionLockoutsManage,
+		PermissionAuditRead,
+		PermissionGroupsManage,
+	},
+}
+
+// HasScope reports whether the space separated scopes contain the scope.
+func HasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScopesGrant reports whether any of the space separated scopes grants the
+// permission.
+func ScopesGrant(scope, permission string) bool {
+	for _, s := range strings.Fields(scope) {
+		for _, p := range ScopePermissions[s] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsPermission reports whether p is a known permission or a wildcard that
+// matches at least one.
+func IsPermission(p string) bool {
+	if p == "*" {
+		return true
+	}
+
+	for _, known := range Permissions {
+		if known == p || (strings.HasSuffix(p, ":*") && strings.HasPrefix(known, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Role is a named set of permissions, users hold roles by name. Holding a
+// role also grants the roles it inherits, e.g. admin implies user.
+type Role struct {
+	Name        string                      `json:"name" gorm:"primaryKey"`
+	Description string                      `json:"description"`
+	Permissions datatypes.JSONSlice[string] `json:"permissions"`
+	Inherits    datatypes.JSONSlice[string] `json:"inherits"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+
+	// Members counts the users holding the role directly, through a group
+	// or by inheritance, only set when listing roles.
+	Members int64 `json:"members" gorm:"-"`
+}
+
+func (r *Role) Grants(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == "*" || p == permission || (strings.HasSuffix(p, ":*") && strings.HasPrefix(permission, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type CreateRole struct {
+	Name        string   `json:"name" binding:"required,min=2,max=32"`
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions"`
+	Inherits    []string `json:"inherits"`
+}
+
+type UpdateRole struct {
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions" binding:"required"`
+	Inherits    []string `json:"inherits"`
+}
+
+// RoleGrant records when a role assigned to a user directly expires. Roles
+// without a grant are held until removed.
+type RoleGrant struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	Role      string    `json:"role" gorm:"primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+
+	User *User `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// AssignRole optionally limits how long a role is held.
+type AssignRole struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
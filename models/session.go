@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session is a device a user signed in from. Its ID is the family ID of the
+// refresh tokens issued to the device, every token of the session carries it
+// as the sid claim.
+type Session struct {
+	Base
+
+	UserID     string     `json:"-" gorm:"index"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt  *time.Time `json:"-"`
+
+	Current bool `json:"current" gorm:"-"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/datatypes"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 const (
 	UserRole  = "user"
@@ -15,6 +19,13 @@ type User struct {
 	Password string `json:"-"`
 
 	Roles datatypes.JSONSlice[string] `json:"roles"`
+
+	VerifiedAt         *time.Time `json:"verified_at"`
+	VerificationSentAt *time.Time `json:"-"`
+
+	MfaEnabled  bool   `json:"mfa_enabled"`
+	MfaSecret   string `json:"-"`
+	MfaLastStep int64  `json:"-"`
 }
 
 func (u *User) HasRole(role string) bool {
@@ -35,7 +46,7 @@ type RegisterUser struct {
 
 type LoginUser struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8,max=50"`
+	Password string `json:"password" binding:"required,max=1024"`
 }
 
 type Token struct {
@@ -47,3 +58,11 @@ type Token struct {
 type RefreshToken struct {
 	Token string `json:"token"`
 }
+
+type VerifyEmail struct {
+	Token string `form:"token" binding:"required"`
+}
+
+type ResendVerification struct {
+	Email string `json:"email" binding:"required,email"`
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrUnknownApiKey    = errors.New("unknown api key")
+	ErrApiKeyExpiry     = errors.New("api key expiry must be in the future")
+	ErrApiKeyAdminScope = errors.New("only users holding a permission can create keys with the admin scope")
+)
+
+type ApiKeyService interface {
+	FindAll(userId string) ([]models.ApiKey, error)
+	Create(userId string, apiKey models.CreateApiKey) (models.ApiKeyCredentials, error)
+	Revoke(userId, id string) error
+}
+
+func NewApiKeyService(repository repositories.ApiKeyRepository, userRepository repositories.UserRepository, groupService GroupService, roleService RoleService) ApiKeyService {
+	log.Info().Msg("Creating new api key service")
+
+	return &apiKeyService{
+		repository:     repository,
+		userRepository: userRepository,
+		groupService:   groupService,
+		roleService:    roleService,
+	}
+}
+
+type apiKeyService struct {
+	repository     repositories.ApiKeyRepository
+	userRepository repositories.UserRepository
+	groupService   GroupService
+	roleService    RoleService
+}
+
+func (s *apiKeyService) FindAll(userId string) ([]models.ApiKey, error) {
+	return s.repository.FindByUserId(userId)
+}
+
+func (s *apiKeyService) Create(userId string, apiKey models.CreateApiKey) (models.ApiKeyCredentials, error) {
+	var credentials models.ApiKeyCredentials
+
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return credentials, err
+	}
+
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now()) {
+		return credentials, ErrApiKeyExpiry
+	}
+
+	for _, scope := range apiKey.Scopes {
+		if scope != models.ApiScopeAdmin {
+			continue
+		}
+
+		ok, err := s.holdsPermission(user)
+		if err != nil {
+			return credentials, err
+		}
+
+		if !ok {
+			return credentials, ErrApiKeyAdminScope
+		}
+	}
+
+	secret, err := auth.RandomToken()
+	if err != nil {
+		return credentials, err
+	}
+
+	key := models.ApiKeyPrefix + secret
+
+	newApiKey := models.ApiKey{
+		UserID:    user.ID,
+		Name:      apiKey.Name,
+		Prefix:    key[:len(models.ApiKeyPrefix)+8],
+		KeyHash:   auth.HashToken(key),
+		Scopes:    apiKey.Scopes,
+		ExpiresAt: apiKey.ExpiresAt,
+	}
+
+	err = s.repository.Create(&newApiKey)
+	if err != nil {
+		return credentials, err
+	}
+
+	credentials.ApiKey = newApiKey
+	credentials.Key = key
+
+	return credentials, nil
+}
+
+func (s *apiKeyService) Revoke(userId, id string) error {
+	ok, err := s.repository.Revoke(userId, id)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrUnknownApiKey
+	}
+
+	return nil
+}
+
+// holdsPermission reports whether the effective roles of the user grant any
+// permission, the admin scope gives keys nothing more otherwise.
+func (s *apiKeyService) holdsPermission(user models.User) (bool, error) {
+	roles, err := s.groupService.EffectiveRoles(user)
+	if err != nil {
+		return false, err
+	}
+
+	for _, permission := range models.Permissions {
+		ok, err := s.roleService.HasPermission(roles, permission)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memoryApiKeyRepository keeps the created keys.
+type memoryApiKeyRepository struct {
+	repositories.ApiKeyRepository
+	keys []models.ApiKey
+}
+
+func (r *memoryApiKeyRepository) Create(t *models.ApiKey) error {
+	r.keys = append(r.keys, *t)
+	return nil
+}
+
+func TestApiKeyCreate(t *testing.T) {
+	users := &fixedUserRepository{users: []models.User{
+		{Base: models.Base{ID: "u1"}, Roles: []string{models.UserRole}},
+		{Base: models.Base{ID: "a1"}, Roles: []string{models.AdminRole}},
+	}}
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		userId    string
+		scopes    []string
+		expiresAt *time.Time
+		want      error
+	}{
+		{"read", "u1", []string{models.ApiScopeRead}, nil, nil},
+		{"expires later", "u1", []string{models.ApiScopeRead}, &future, nil},
+		{"expired", "u1", []string{models.ApiScopeRead}, &past, ErrApiKeyExpiry},
+		{"admin scope without permissions", "u1", []string{models.ApiScopeRead, models.ApiScopeAdmin}, nil, ErrApiKeyAdminScope},
+		{"admin scope", "a1", []string{models.ApiScopeAdmin}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &memoryApiKeyRepository{}
+			s := &apiKeyService{
+				repository:     keys,
+				userRepository: users,
+				groupService:   &directRolesGroupService{},
+				roleService:    newFixedRoleService(),
+			}
+
+			credentials, err := s.Create(tt.userId, models.CreateApiKey{Name: "key", Scopes: tt.scopes, ExpiresAt: tt.expiresAt})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.want)
+			}
+
+			if tt.want != nil {
+				if len(keys.keys) != 0 {
+					t.Errorf("Create() stored %d keys, want none", len(keys.keys))
+				}
+				return
+			}
+
+			if !strings.HasPrefix(credentials.Key, credentials.ApiKey.Prefix) || !strings.HasPrefix(credentials.Key, models.ApiKeyPrefix) {
+				t.Errorf("Create() key %q does not start with prefix %q", credentials.Key, credentials.ApiKey.Prefix)
+			}
+
+			if len(keys.keys) != 1 || keys.keys[0].KeyHash != auth.HashToken(credentials.Key) {
+				t.Errorf("Create() stored %+v, want the hash of the key", keys.keys)
+			}
+		})
+	}
+}
+
+func TestApiKeyScopesLimitPermissions(t *testing.T) {
+	s := newFixedRoleService()
+
+	tests := []struct {
+		name       string
+		scope      string
+		permission string
+		want       bool
+	}{
+		{"read scope reads", models.ApiScopeRead, models.PermissionUsersRead, true},
+		{"read scope can not delete", models.ApiScopeRead, models.PermissionUsersDelete, false},
+		{"write scope can not manage roles", models.ApiScopeWrite, models.PermissionRolesManage, false},
+		{"admin scope uses the roles", models.ApiScopeAdmin, models.PermissionRolesManage, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole}, Type: auth.TokenTypeApiKey, Scope: tt.scope}
+
+			got, err := s.Can(claims, tt.permission)
+			if err != nil {
+				t.Fatalf("Can() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Can(%s) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
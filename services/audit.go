@@ -0,0 +1,41 @@
+package services
+
+import (
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+type AuditService interface {
+	Record(event models.AuditEvent) error
+	FindAll(query models.AuditQuery) ([]models.AuditEvent, error)
+}
+
+func NewAuditService(repository repositories.AuditRepository) AuditService {
+	log.Info().Msg("Creating new audit service")
+
+	return &auditService{
+		repository: repository,
+	}
+}
+
+type auditService struct {
+	repository repositories.AuditRepository
+}
+
+// Record stores the event and writes it to the log.
+func (s *auditService) Record(event models.AuditEvent) error {
+	log.Info().
+		Str("action", event.Action).
+		Str("actor_id", event.ActorID).
+		Str("subject_id", event.SubjectID).
+		Str("ip", event.IP).
+		Str("details", event.Details).
+		Msg("Audit event")
+
+	return s.repository.Create(&event)
+}
+
+func (s *auditService) FindAll(query models.AuditQuery) ([]models.AuditEvent, error) {
+	return s.repository.Find(query)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/policy"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultPolicy lets users read their own account and delete it when signed
+// in themselves, and holders of the matching permission, machine clients
+// through their scopes, do so for every account. A policy file can replace
+// the rule of any action.
+var defaultPolicy = policy.Rules{
+	models.ActionUsersList:   {"permission:" + models.PermissionUsersRead},
+	models.ActionUsersRead:   {policy.ConditionOwner, "permission:" + models.PermissionUsersRead},
+	models.ActionUsersDelete: {policy.ConditionSelf, "permission:" + models.PermissionUsersDelete},
+}
+
+type AuthorizationService interface {
+	Authorize(claims *auth.Claims, action string, resource policy.Resource) error
+}
+
+func NewAuthorizationService(roleService RoleService, cfg config.Config) (AuthorizationService, error) {
+	log.Info().Msg("Creating new authorization service")
+
+	var p *policy.Policy
+	var err error
+	if cfg.PolicyFile != "" {
+		p, err = policy.Load(cfg.PolicyFile, defaultPolicy)
+	} else {
+		p, err = policy.New(defaultPolicy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &authorizationService{
+		roleService: roleService,
+		policy:      p,
+	}, nil
+}
+
+type authorizationService struct {
+	roleService RoleService
+	policy      *policy.Policy
+}
+
+// Authorize returns policy.ErrDenied unless the policy allows the principal
+// of the claims the action on the resource.
+func (s *authorizationService) Authorize(claims *auth.Claims, action string, resource policy.Resource) error {
+	principal := policy.Principal{
+		ID:     claims.UserID,
+		Roles:  claims.Roles,
+		Client: claims.IsClient(),
+		ApiKey: claims.Type == auth.TokenTypeApiKey,
+		Can: func(permission string) bool {
+			allowed, err := s.roleService.Can(claims, permission)
+			if err != nil {
+				log.Error().Err(err).Str("permission", permission).Msg("Failed to check permission")
+			}
+			return allowed
+		},
+	}
+
+	if principal.Client {
+		principal.ID = claims.ClientID
+	}
+
+	// OAuth clients act for the user like an impersonating admin does.
+	if claims.Actor != nil {
+		principal.Actor = claims.Actor.Subject
+	} else if claims.Delegated() {
+		principal.Actor = claims.ClientID
+	}
+
+	return s.policy.Authorize(principal, action, resource)
+}
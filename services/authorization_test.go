@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/policy"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// defaultRoleRepository serves the default roles to the role service.
+type defaultRoleRepository struct {
+	repositories.RoleRepository
+}
+
+func (r *defaultRoleRepository) FindAll() ([]models.Role, error) {
+	return defaultRoles, nil
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	p, err := policy.New(defaultPolicy)
+	if err != nil {
+		t.Fatalf("policy.New() error = %v", err)
+	}
+
+	roleService := &roleService{repository: &defaultRoleRepository{}, cfg: config.Config{RoleCacheTTL: time.Minute}}
+	s := &authorizationService{roleService: roleService, policy: p}
+
+	owner := &auth.Claims{UserID: "u1", Roles: []string{models.UserRole}, Type: auth.TokenTypeAccess}
+	other := &auth.Claims{UserID: "u2", Roles: []string{models.UserRole}, Type: auth.TokenTypeAccess}
+	admin := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole, models.UserRole}, Type: auth.TokenTypeAccess}
+	impersonated := &auth.Claims{UserID: "u1", Roles: []string{models.UserRole}, Type: auth.TokenTypeAccess, Actor: &auth.Actor{Subject: "a1"}}
+	ownerApiKey := &auth.Claims{UserID: "u1", Roles: []string{models.UserRole}, Type: auth.TokenTypeApiKey, Scope: "read write admin"}
+	adminReadKey := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole, models.UserRole}, Type: auth.TokenTypeApiKey, Scope: models.ApiScopeRead}
+	delegated := &auth.Claims{UserID: "u1", ClientID: "app", Type: auth.TokenTypeAccess, Scope: models.ScopeOpenID}
+	client := &auth.Claims{ClientID: "svc", Type: auth.TokenTypeAccess, Scope: models.ApiScopeRead}
+	adminClient := &auth.Claims{ClientID: "svc", Type: auth.TokenTypeAccess, Scope: models.ApiScopeAdmin}
+
+	own := policy.Resource{Type: models.ResourceUser, ID: "u1", OwnerID: "u1"}
+	list := policy.Resource{Type: models.ResourceUser}
+
+	tests := []struct {
+		name     string
+		claims   *auth.Claims
+		action   string
+		resource policy.Resource
+		want     bool
+	}{
+		{"owner reads", owner, models.ActionUsersRead, own, true},
+		{"owner deletes", owner, models.ActionUsersDelete, own, true},
+		{"owner lists", owner, models.ActionUsersList, list, false},
+		{"other reads", other, models.ActionUsersRead, own, false},
+		{"other deletes", other, models.ActionUsersDelete, own, false},
+		{"admin reads", admin, models.ActionUsersRead, own, true},
+		{"admin lists", admin, models.ActionUsersList, list, true},
+		{"admin deletes", admin, models.ActionUsersDelete, own, true},
+		{"impersonator reads as owner", impersonated, models.ActionUsersRead, own, true},
+		{"impersonator deletes as owner", impersonated, models.ActionUsersDelete, own, false},
+		{"owner api key deletes", ownerApiKey, models.ActionUsersDelete, own, false},
+		{"admin read api key lists", adminReadKey, models.ActionUsersList, list, true},
+		{"admin read api key deletes", adminReadKey, models.ActionUsersDelete, own, false},
+		{"oauth client reads as owner", delegated, models.ActionUsersRead, own, true},
+		{"oauth client deletes as owner", delegated, models.ActionUsersDelete, own, false},
+		{"machine client lists", client, models.ActionUsersList, list, true},
+		{"machine client reads", client, models.ActionUsersRead, own, true},
+		{"machine client deletes", client, models.ActionUsersDelete, own, false},
+		{"admin machine client deletes", adminClient, models.ActionUsersDelete, own, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Authorize(tt.claims, tt.action, tt.resource)
+
+			if tt.want && err != nil {
+				t.Errorf("Authorize() error = %v, want allowed", err)
+			}
+
+			if !tt.want && !errors.Is(err, policy.ErrDenied) {
+				t.Errorf("Authorize() error = %v, want ErrDenied", err)
+			}
+		})
+	}
+}
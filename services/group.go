@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrUnknownGroup       = errors.New("unknown group")
+	ErrGroupExists        = errors.New("group already exists")
+	ErrUnknownGroupMember = errors.New("user is not a member of the group")
+)
+
+type GroupService interface {
+	FindAll(query models.PaginationQuery) ([]models.Group, error)
+	FindById(id string) (models.Group, error)
+	Create(claims *auth.Claims, group models.CreateGroup) (models.Group, error)
+	Update(claims *auth.Claims, id string, group models.UpdateGroup) (models.Group, error)
+	Delete(id string) error
+	FindMembers(id string) ([]models.GroupMember, error)
+	AddMember(claims *auth.Claims, id, userId string) error
+	RemoveMember(id, userId string) error
+	EffectiveRoles(user models.User) ([]string, error)
+}
+
+func NewGroupService(repository repositories.GroupRepository, userRepository repositories.UserRepository, roleService RoleService) GroupService {
+	log.Info().Msg("Creating new group service")
+
+	return &groupService{
+		repository:     repository,
+		userRepository: userRepository,
+		roleService:    roleService,
+	}
+}
+
+type groupService struct {
+	repository     repositories.GroupRepository
+	userRepository repositories.UserRepository
+	roleService    RoleService
+}
+
+func (s *groupService) FindAll(query models.PaginationQuery) ([]models.Group, error) {
+	return s.repository.FindAll(query)
+}
+
+func (s *groupService) FindById(id string) (models.Group, error) {
+	group, err := s.repository.FindById(id)
+	if err != nil {
+		return group, ErrUnknownGroup
+	}
+
+	return group, nil
+}
+
+// Create adds a group with roles whose permissions the caller holds.
+func (s *groupService) Create(claims *auth.Claims, group models.CreateGroup) (models.Group, error) {
+	_, err := s.repository.FindByName(group.Name)
+	if err == nil {
+		return models.Group{}, ErrGroupExists
+	}
+
+	roles, err := s.validRoles(claims, group.Roles)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	newGroup := models.Group{
+		Name:        group.Name,
+		Description: group.Description,
+		Roles:       roles,
+	}
+
+	err = s.repository.Create(&newGroup)
+	return newGroup, err
+}
+
+// Update replaces the roles of the group. Members get them with their next
+// token, like roles assigned to them directly.
+func (s *groupService) Update(claims *auth.Claims, id string, group models.UpdateGroup) (models.Group, error) {
+	existing, err := s.FindById(id)
+	if err != nil {
+		return existing, err
+	}
+
+	roles, err := s.validRoles(claims, group.Roles)
+	if err != nil {
+		return existing, err
+	}
+
+	existing.Description = group.Description
+	existing.Roles = roles
+
+	err = s.repository.Update(&existing)
+	return existing, err
+}
+
+func (s *groupService) Delete(id string) error {
+	group, err := s.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.Delete(&group)
+}
+
+func (s *groupService) FindMembers(id string) ([]models.GroupMember, error) {
+	_, err := s.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.FindMembers(id)
+}
+
+// AddMember gives the user the roles of the group, so the caller must hold
+// their permissions.
+func (s *groupService) AddMember(claims *auth.Claims, id, userId string) error {
+	group, err := s.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.roleService.CanGrant(claims, group.Roles)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.userRepository.FindById(userId)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.AddMember(id, userId)
+}
+
+func (s *groupService) RemoveMember(id, userId string) error {
+	ok, err := s.repository.RemoveMember(id, userId)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrUnknownGroupMember
+	}
+
+	return nil
+}
+
+// EffectiveRoles returns the roles of the user joined with the roles of
+// every group the user is a member of and the roles those inherit.
+func (s *groupService) EffectiveRoles(user models.User) ([]string, error) {
+	groups, err := s.repository.FindAllByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := append([]string(nil), user.Roles...)
+	for _, group := range groups {
+		roles = append(roles, group.Roles...)
+	}
+
+	// Expand also drops the duplicates.
+	return s.roleService.Expand(roles)
+}
+
+func (s *groupService) validRoles(claims *auth.Claims, roles []string) ([]string, error) {
+	valid := make([]string, 0, len(roles))
+	for _, role := range roles {
+		_, err := s.roleService.FindByName(role)
+		if err != nil {
+			return nil, err
+		}
+
+		valid = append(valid, role)
+	}
+
+	err := s.roleService.CanGrant(claims, valid)
+	if err != nil {
+		return nil, err
+	}
+
+	return valid, nil
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memoryGroupRepository keeps groups and their members in memory.
+type memoryGroupRepository struct {
+	repositories.GroupRepository
+	groups  []*models.Group
+	members map[string][]string
+}
+
+func (r *memoryGroupRepository) FindById(id string) (models.Group, error) {
+	for _, g := range r.groups {
+		if g.ID == id {
+			return *g, nil
+		}
+	}
+
+	return models.Group{}, errors.New("record not found")
+}
+
+func (r *memoryGroupRepository) FindByName(name string) (models.Group, error) {
+	for _, g := range r.groups {
+		if g.Name == name {
+			return *g, nil
+		}
+	}
+
+	return models.Group{}, errors.New("record not found")
+}
+
+func (r *memoryGroupRepository) FindAllByUserId(userId string) ([]models.Group, error) {
+	var groups []models.Group
+	for _, id := range r.members[userId] {
+		group, err := r.FindById(id)
+		if err == nil {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups, nil
+}
+
+func (r *memoryGroupRepository) Create(t *models.Group) error {
+	r.groups = append(r.groups, t)
+	return nil
+}
+
+func (r *memoryGroupRepository) Update(t *models.Group) error {
+	for i, g := range r.groups {
+		if g.ID == t.ID {
+			r.groups[i] = t
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryGroupRepository) AddMember(groupId, userId string) error {
+	r.members[userId] = append(r.members[userId], groupId)
+	return nil
+}
+
+func newTestGroupService() *groupService {
+	return &groupService{
+		repository: &memoryGroupRepository{
+			groups: []*models.Group{
+				{Base: models.Base{ID: "g1"}, Name: "readers", Roles: []string{"reader"}},
+				{Base: models.Base{ID: "g2"}, Name: "auditors", Roles: []string{"auditor"}},
+				{Base: models.Base{ID: "g3"}, Name: "support", Roles: []string{"support"}},
+			},
+			members: map[string][]string{"u1": {"g2"}},
+		},
+		userRepository: &fixedUserRepository{users: []models.User{{Base: models.Base{ID: "u1"}}, {Base: models.Base{ID: "u2"}}}},
+		roleService: newFixedRoleService(
+			models.Role{Name: "manager", Permissions: []string{models.PermissionUsersRoles, models.PermissionUsersRead, models.PermissionAuditRead}},
+			models.Role{Name: "reader", Permissions: []string{models.PermissionUsersRead}},
+			models.Role{Name: "auditor", Permissions: []string{models.PermissionAuditRead}, Inherits: []string{"reader"}},
+			models.Role{Name: "support", Permissions: []string{models.PermissionUsersImpersonate}, Inherits: []string{"reader"}},
+		),
+	}
+}
+
+func TestGroupEffectiveRoles(t *testing.T) {
+	s := newTestGroupService()
+
+	tests := []struct {
+		name string
+		user models.User
+		want []string
+	}{
+		{"direct roles only", models.User{Base: models.Base{ID: "u2"}, Roles: []string{"reader"}}, []string{"reader"}},
+		{"group roles and their inherited roles", models.User{Base: models.Base{ID: "u1"}}, []string{"auditor", "reader"}},
+		{"direct and group roles without duplicates", models.User{Base: models.Base{ID: "u1"}, Roles: []string{"reader", "support"}}, []string{"auditor", "reader", "support"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, err := s.EffectiveRoles(tt.user)
+			if err != nil {
+				t.Fatalf("EffectiveRoles() error = %v", err)
+			}
+
+			sort.Strings(roles)
+			if !reflect.DeepEqual(roles, tt.want) {
+				t.Errorf("EffectiveRoles() = %v, want %v", roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupAddMember(t *testing.T) {
+	manager := &auth.Claims{UserID: "m1", Roles: []string{"manager"}, Type: auth.TokenTypeAccess}
+	admin := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		id     string
+		userId string
+		want   error
+	}{
+		{"group with held roles", manager, "g1", "u2", nil},
+		{"group with held inherited roles", manager, "g2", "u2", nil},
+		{"group with a role not held", manager, "g3", "u2", ErrRoleNotHeld},
+		{"admin adds to any group", admin, "g3", "u2", nil},
+		{"unknown group", admin, "missing", "u2", ErrUnknownGroup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestGroupService()
+
+			err := s.AddMember(tt.claims, tt.id, tt.userId)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AddMember() error = %v, want %v", err, tt.want)
+			}
+
+			groups, _ := s.repository.FindAllByUserId(tt.userId)
+			if added := len(groups) == 1; added != (tt.want == nil) {
+				t.Errorf("AddMember() added = %v, want %v", added, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestGroupCanOnlyGrantHeldRoles(t *testing.T) {
+	manager := &auth.Claims{UserID: "m1", Roles: []string{"manager"}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  error
+	}{
+		{"held roles", []string{"reader", "auditor"}, nil},
+		{"role not held", []string{"reader", "support"}, ErrRoleNotHeld},
+		{"admin", []string{models.AdminRole}, ErrRoleNotHeld},
+		{"unknown role", []string{"ghost"}, ErrUnknownRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestGroupService()
+
+			if _, err := s.Create(manager, models.CreateGroup{Name: "new", Roles: tt.roles}); !errors.Is(err, tt.want) {
+				t.Errorf("Create() error = %v, want %v", err, tt.want)
+			}
+
+			if _, err := s.Update(manager, "g1", models.UpdateGroup{Roles: tt.roles}); !errors.Is(err, tt.want) {
+				t.Errorf("Update() error = %v, want %v", err, tt.want)
+			}
+
+			if group, _ := s.FindById("g1"); tt.want != nil && !reflect.DeepEqual([]string(group.Roles), []string{"reader"}) {
+				t.Errorf("Update() stored roles = %v after a rejected change", group.Roles)
+			}
+		})
+	}
+}
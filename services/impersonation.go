@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrImpersonateSelf       = errors.New("you can not impersonate yourself")
+	ErrImpersonatePrivileged = errors.New("users holding permissions you lack can not be impersonated")
+	ErrNotImpersonating      = errors.New("token is not an impersonation token")
+)
+
+type ImpersonationService interface {
+	Start(actor *auth.Claims, userId string, start models.StartImpersonation, client models.ClientInfo) (models.ImpersonationToken, error)
+	Stop(claims *auth.Claims, client models.ClientInfo) error
+}
+
+func NewImpersonationService(userRepository repositories.UserRepository, groupService GroupService, roleService RoleService, tokenService TokenService, auditService AuditService) ImpersonationService {
+	log.Info().Msg("Creating new impersonation service")
+
+	return &impersonationService{
+		userRepository: userRepository,
+		groupService:   groupService,
+		roleService:    roleService,
+		tokenService:   tokenService,
+		auditService:   auditService,
+	}
+}
+
+type impersonationService struct {
+	userRepository repositories.UserRepository
+	groupService   GroupService
+	roleService    RoleService
+	tokenService   TokenService
+	auditService   AuditService
+}
+
+// Start issues a token for acting as the user. Users holding permissions
+// the actor lacks can not be impersonated so support staff never gain more
+// rights than they have. The token is only handed out once the start was
+// audited.
+func (s *impersonationService) Start(actor *auth.Claims, userId string, start models.StartImpersonation, client models.ClientInfo) (models.ImpersonationToken, error) {
+	var token models.ImpersonationToken
+
+	actorId := actor.UserID
+	if actorId == userId {
+		return token, ErrImpersonateSelf
+	}
+
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return token, err
+	}
+
+	roles, err := s.groupService.EffectiveRoles(user)
+	if err != nil {
+		return token, err
+	}
+
+	err = s.roleService.CanGrant(actor, roles)
+	if errors.Is(err, ErrRoleNotHeld) {
+		return token, ErrImpersonatePrivileged
+	}
+	if err != nil {
+		return token, err
+	}
+
+	token, err = s.tokenService.IssueImpersonation(actorId, user)
+	if err != nil {
+		return token, err
+	}
+
+	err = s.auditService.Record(models.AuditEvent{
+		Action:    models.AuditImpersonationStart,
+		ActorID:   actorId,
+		SubjectID: user.ID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Details:   start.Reason,
+	})
+	if err != nil {
+		return models.ImpersonationToken{}, err
+	}
+
+	return token, nil
+}
+
+// Stop revokes the impersonation token before it expires.
+func (s *impersonationService) Stop(claims *auth.Claims, client models.ClientInfo) error {
+	if !claims.Impersonated() {
+		return ErrNotImpersonating
+	}
+
+	err := s.tokenService.Logout(claims, "")
+	if err != nil {
+		return err
+	}
+
+	return s.auditService.Record(models.AuditEvent{
+		Action:    models.AuditImpersonationStop,
+		ActorID:   claims.Actor.Subject,
+		SubjectID: claims.UserID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Details:   "stopped after " + time.Since(claims.IssuedAt.Time).Round(time.Second).String(),
+	})
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fixedUserRepository serves a fixed set of users.
+type fixedUserRepository struct {
+	repositories.UserRepository
+	users []models.User
+}
+
+func (r *fixedUserRepository) FindById(id string) (models.User, error) {
+	for _, u := range r.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+
+	return models.User{}, errors.New("record not found")
+}
+
+func (r *fixedUserRepository) FindByEmail(email string) (models.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return models.User{}, errors.New("record not found")
+}
+
+// directRolesGroupService gives users their own roles only.
+type directRolesGroupService struct {
+	GroupService
+}
+
+func (s *directRolesGroupService) EffectiveRoles(user models.User) ([]string, error) {
+	return user.Roles, nil
+}
+
+// impersonationTokenService issues impersonation tokens without signing and
+// records the tokens logged out.
+type impersonationTokenService struct {
+	TokenService
+	loggedOut []string
+}
+
+func (s *impersonationTokenService) IssueImpersonation(actorId string, user models.User) (models.ImpersonationToken, error) {
+	return models.ImpersonationToken{Token: "impersonation"}, nil
+}
+
+func (s *impersonationTokenService) Logout(claims *auth.Claims, refreshToken string) error {
+	s.loggedOut = append(s.loggedOut, claims.ID)
+	return nil
+}
+
+// recordingAuditService keeps the recorded events, or fails with err.
+type recordingAuditService struct {
+	AuditService
+	events []models.AuditEvent
+	err    error
+}
+
+func (s *recordingAuditService) Record(event models.AuditEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestImpersonationStart(t *testing.T) {
+	roleService := newFixedRoleService(
+		models.Role{Name: "support", Permissions: []string{models.PermissionUsersImpersonate, models.PermissionUsersRead}},
+		models.Role{Name: "manager", Permissions: []string{models.PermissionRolesManage}},
+		models.Role{Name: "superuser", Permissions: []string{"*"}},
+	)
+
+	users := &fixedUserRepository{users: []models.User{
+		{Base: models.Base{ID: "u1"}, Roles: []string{models.UserRole}},
+		{Base: models.Base{ID: "s2"}, Roles: []string{"support", models.UserRole}},
+		{Base: models.Base{ID: "m1"}, Roles: []string{"manager", models.UserRole}},
+		{Base: models.Base{ID: "x1"}, Roles: []string{"superuser"}},
+		{Base: models.Base{ID: "a1"}, Roles: []string{models.AdminRole}},
+	}}
+
+	support := &auth.Claims{UserID: "s1", Roles: []string{"support", models.UserRole}, Type: auth.TokenTypeAccess}
+	admin := &auth.Claims{UserID: "a2", Roles: []string{models.AdminRole}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name   string
+		actor  *auth.Claims
+		userId string
+		want   error
+	}{
+		{"user", support, "u1", nil},
+		{"other support", support, "s2", nil},
+		{"self", support, "s1", ErrImpersonateSelf},
+		{"role manager", support, "m1", ErrImpersonatePrivileged},
+		{"custom wildcard role", support, "x1", ErrImpersonatePrivileged},
+		{"admin", support, "a1", ErrImpersonatePrivileged},
+		{"admin impersonates manager", admin, "m1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &recordingAuditService{}
+			s := &impersonationService{
+				userRepository: users,
+				groupService:   &directRolesGroupService{},
+				roleService:    roleService,
+				tokenService:   &impersonationTokenService{},
+				auditService:   audit,
+			}
+
+			_, err := s.Start(tt.actor, tt.userId, models.StartImpersonation{Reason: "ticket"}, models.ClientInfo{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Start() error = %v, want %v", err, tt.want)
+			}
+
+			if audited := len(audit.events) == 1; audited != (tt.want == nil) {
+				t.Errorf("Start() audited = %v, want %v", audited, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestImpersonationStartNeedsAudit(t *testing.T) {
+	s := &impersonationService{
+		userRepository: &fixedUserRepository{users: []models.User{{Base: models.Base{ID: "u1"}, Roles: []string{models.UserRole}}}},
+		groupService:   &directRolesGroupService{},
+		roleService:    newFixedRoleService(),
+		tokenService:   &impersonationTokenService{},
+		auditService:   &recordingAuditService{err: errors.New("audit log unavailable")},
+	}
+
+	admin := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole}, Type: auth.TokenTypeAccess}
+
+	token, err := s.Start(admin, "u1", models.StartImpersonation{Reason: "ticket"}, models.ClientInfo{})
+	if err == nil || token.Token != "" {
+		t.Errorf("Start() = %q, %v, want no token when the audit fails", token.Token, err)
+	}
+}
+
+func TestImpersonationStop(t *testing.T) {
+	impersonated := &auth.Claims{UserID: "u1", Type: auth.TokenTypeAccess, Actor: &auth.Actor{Subject: "a1"}}
+	impersonated.ID = "t1"
+	impersonated.IssuedAt = jwt.NewNumericDate(time.Now())
+
+	own := &auth.Claims{UserID: "u1", Type: auth.TokenTypeAccess}
+	own.ID = "t2"
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		want   error
+	}{
+		{"impersonation token", impersonated, nil},
+		{"own token", own, ErrNotImpersonating},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := &impersonationTokenService{}
+			audit := &recordingAuditService{}
+			s := &impersonationService{tokenService: tokens, auditService: audit}
+
+			err := s.Stop(tt.claims, models.ClientInfo{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Stop() error = %v, want %v", err, tt.want)
+			}
+
+			if tt.want != nil {
+				if len(tokens.loggedOut) != 0 || len(audit.events) != 0 {
+					t.Errorf("Stop() logged out %v and audited %v, want nothing", tokens.loggedOut, audit.events)
+				}
+				return
+			}
+
+			if len(tokens.loggedOut) != 1 || tokens.loggedOut[0] != "t1" {
+				t.Errorf("Stop() logged out %v, want [t1]", tokens.loggedOut)
+			}
+
+			if len(audit.events) != 1 || audit.events[0].ActorID != "a1" || audit.events[0].SubjectID != "u1" {
+				t.Errorf("Stop() audited %+v, want a1 acting as u1", audit.events)
+			}
+		})
+	}
+}
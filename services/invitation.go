@@ -0,0 +1,318 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/mailer"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidInvitation    = errors.New("invalid or expired invitation")
+	ErrUnknownInvitation    = errors.New("unknown invitation")
+	ErrInvitationExists     = errors.New("the email already has a pending invitation")
+	ErrInvitationNotPending = errors.New("invitation was already answered or revoked")
+	ErrInvitationThrottled  = errors.New("invitation was sent recently, try again later")
+	ErrAlreadyMember        = errors.New("user is already a member of the organization")
+	ErrNameRequired         = errors.New("name is required to create the account")
+)
+
+type InvitationService interface {
+	FindAll(organizationId string) ([]models.Invitation, error)
+	Create(organizationId string, inviter models.Membership, invitation models.CreateInvitation) (models.Invitation, error)
+	Resend(organizationId, id string) (models.Invitation, error)
+	Revoke(organizationId, id string) error
+	Preview(token string) (models.InvitationPreview, error)
+	Accept(accept models.AcceptInvitation, client models.ClientInfo) (models.AcceptedInvitation, error)
+	Decline(token string) error
+}
+
+func NewInvitationService(repository repositories.InvitationRepository, organizationRepository repositories.OrganizationRepository, membershipRepository repositories.MembershipRepository, userRepository repositories.UserRepository, userService UserService, tokenService TokenService, mailer mailer.Mailer, cfg config.Config) InvitationService {
+	log.Info().Msg("Creating new invitation service")
+
+	return &invitationService{
+		repository:             repository,
+		organizationRepository: organizationRepository,
+		membershipRepository:   membershipRepository,
+		userRepository:         userRepository,
+		userService:            userService,
+		tokenService:           tokenService,
+		mailer:                 mailer,
+		cfg:                    cfg,
+	}
+}
+
+type invitationService struct {
+	repository             repositories.InvitationRepository
+	organizationRepository repositories.OrganizationRepository
+	membershipRepository   repositories.MembershipRepository
+	userRepository         repositories.UserRepository
+	userService            UserService
+	tokenService           TokenService
+	mailer                 mailer.Mailer
+	cfg                    config.Config
+}
+
+func (s *invitationService) FindAll(organizationId string) ([]models.Invitation, error) {
+	invitations, err := s.repository.FindAll(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range invitations {
+		invitations[i].Status = invitationStatus(invitations[i])
+	}
+
+	return invitations, nil
+}
+
+// Create invites the email to the organization and mails the link. Only
+// owners can invite new owners.
+func (s *invitationService) Create(organizationId string, inviter models.Membership, invitation models.CreateInvitation) (models.Invitation, error) {
+	if invitation.Role == models.OrgRoleOwner && inviter.Role != models.OrgRoleOwner {
+		return models.Invitation{}, ErrOrgRoleForbidden
+	}
+
+	user, err := s.userRepository.FindByEmail(invitation.Email)
+	if err == nil {
+		_, err = s.membershipRepository.FindByUserId(organizationId, user.ID)
+		if err == nil {
+			return models.Invitation{}, ErrAlreadyMember
+		}
+	}
+
+	_, err = s.repository.FindPendingByEmail(organizationId, invitation.Email)
+	if err == nil {
+		return models.Invitation{}, ErrInvitationExists
+	}
+
+	newInvitation := models.Invitation{
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		InviterID: inviter.UserID,
+		Status:    models.InvitationPending,
+	}
+
+	token, err := s.renew(&newInvitation)
+	if err != nil {
+		return newInvitation, err
+	}
+
+	err = s.repository.Create(organizationId, &newInvitation)
+	if err != nil {
+		return newInvitation, err
+	}
+
+	return newInvitation, s.send(newInvitation, token)
+}
+
+// Resend mails a new link and extends the expiry, the old link stops
+// working.
+func (s *invitationService) Resend(organizationId, id string) (models.Invitation, error) {
+	invitation, err := s.repository.FindById(organizationId, id)
+	if err != nil {
+		return invitation, ErrUnknownInvitation
+	}
+
+	if invitation.Status != models.InvitationPending {
+		return invitation, ErrInvitationNotPending
+	}
+
+	if time.Since(invitation.SentAt) < s.cfg.InvitationResendInterval {
+		return invitation, ErrInvitationThrottled
+	}
+
+	token, err := s.renew(&invitation)
+	if err != nil {
+		return invitation, err
+	}
+
+	err = s.repository.Update(organizationId, &invitation)
+	if err != nil {
+		return invitation, err
+	}
+
+	return invitation, s.send(invitation, token)
+}
+
+func (s *invitationService) Revoke(organizationId, id string) error {
+	invitation, err := s.repository.FindById(organizationId, id)
+	if err != nil {
+		return ErrUnknownInvitation
+	}
+
+	ok, err := s.repository.Respond(invitation.ID, models.InvitationRevoked)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvitationNotPending
+	}
+
+	return nil
+}
+
+func (s *invitationService) Preview(token string) (models.InvitationPreview, error) {
+	invitation, err := s.findPending(token)
+	if err != nil {
+		return models.InvitationPreview{}, err
+	}
+
+	_, err = s.userRepository.FindByEmail(invitation.Email)
+
+	return models.InvitationPreview{
+		Organization: invitation.Organization.Name,
+		Email:        invitation.Email,
+		Role:         invitation.Role,
+		ExpiresAt:    invitation.ExpiresAt,
+		Registered:   err == nil,
+	}, nil
+}
+
+// Accept adds the invited user to the organization. Without an account for
+// the email one is registered along with the membership, following the
+// emailed link proves ownership of the address so it is created verified.
+// The invitation is claimed in the same transaction, so it can only ever be
+// accepted once, and tokens are only issued once it committed.
+func (s *invitationService) Accept(accept models.AcceptInvitation, client models.ClientInfo) (models.AcceptedInvitation, error) {
+	var accepted models.AcceptedInvitation
+
+	invitation, err := s.findPending(accept.Token)
+	if err != nil {
+		return accepted, err
+	}
+
+	membership := models.Membership{
+		OrganizationID: invitation.OrganizationID,
+		Role:           invitation.Role,
+	}
+
+	var newUser *models.User
+	var methods []string
+
+	user, err := s.userRepository.FindByEmail(invitation.Email)
+	if err == nil {
+		membership.UserID = user.ID
+	} else {
+		if accept.Name == "" {
+			return accepted, ErrNameRequired
+		}
+
+		user, methods, err = s.userService.NewUser(models.RegisterUser{
+			Email:    invitation.Email,
+			Name:     accept.Name,
+			Password: accept.Password,
+		})
+		if err != nil {
+			return accepted, err
+		}
+
+		now := time.Now()
+		user.VerifiedAt = &now
+		newUser = &user
+	}
+
+	ok, err := s.repository.Accept(invitation.ID, newUser, &membership)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return accepted, ErrAlreadyMember
+	}
+	if err != nil {
+		return accepted, err
+	}
+
+	if !ok {
+		return accepted, ErrInvalidInvitation
+	}
+
+	accepted.Membership = membership
+
+	if newUser != nil {
+		token, err := s.tokenService.Issue(*newUser, client, methods...)
+		if err != nil {
+			return accepted, err
+		}
+		accepted.Token = &token
+	}
+
+	return accepted, nil
+}
+
+func (s *invitationService) Decline(token string) error {
+	invitation, err := s.findPending(token)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.repository.Respond(invitation.ID, models.InvitationDeclined)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidInvitation
+	}
+
+	return nil
+}
+
+func (s *invitationService) findPending(token string) (models.Invitation, error) {
+	invitation, err := s.repository.FindByHash(auth.HashToken(token))
+	if err != nil || invitationStatus(invitation) != models.InvitationPending || invitation.Organization == nil {
+		return invitation, ErrInvalidInvitation
+	}
+
+	return invitation, nil
+}
+
+// renew gives the invitation a new token and expiry and returns the token.
+func (s *invitationService) renew(invitation *models.Invitation) (string, error) {
+	token, err := auth.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	invitation.TokenHash = auth.HashToken(token)
+	invitation.SentAt = now
+	invitation.ExpiresAt = now.Add(s.cfg.InvitationLifespan)
+
+	return token, nil
+}
+
+func (s *invitationService) send(invitation models.Invitation, token string) error {
+	organization, err := s.organizationRepository.FindById(invitation.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	inviter, err := s.userRepository.FindById(invitation.InviterID)
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.InvitationUrl + "?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(mailer.Message{
+		To:      invitation.Email,
+		Subject: "Join " + organization.Name,
+		Body: fmt.Sprintf("Hi,\n\n%s invited you to join %s as %s. Open the link below to accept or decline the invitation:\n\n%s\n\nThe link expires in %s. If you were not expecting this you can ignore this email.\n",
+			inviter.Name, organization.Name, invitation.Role, link, s.cfg.InvitationLifespan),
+	})
+}
+
+// invitationStatus reports pending invitations past their expiry as expired.
+func invitationStatus(invitation models.Invitation) string {
+	if invitation.Status == models.InvitationPending && time.Now().After(invitation.ExpiresAt) {
+		return models.InvitationExpired
+	}
+
+	return invitation.Status
+}
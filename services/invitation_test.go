@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memoryInvitationRepository keeps invitations in memory and accepts them
+// into the membership repository.
+type memoryInvitationRepository struct {
+	repositories.InvitationRepository
+	invitations []*models.Invitation
+	memberships *memoryMembershipRepository
+}
+
+func (r *memoryInvitationRepository) FindById(organizationId, id string) (models.Invitation, error) {
+	for _, i := range r.invitations {
+		if i.OrganizationID == organizationId && i.ID == id {
+			return *i, nil
+		}
+	}
+
+	return models.Invitation{}, errors.New("record not found")
+}
+
+func (r *memoryInvitationRepository) FindPendingByEmail(organizationId, email string) (models.Invitation, error) {
+	for _, i := range r.invitations {
+		if i.OrganizationID == organizationId && i.Email == email && i.Status == models.InvitationPending {
+			return *i, nil
+		}
+	}
+
+	return models.Invitation{}, errors.New("record not found")
+}
+
+func (r *memoryInvitationRepository) FindByHash(tokenHash string) (models.Invitation, error) {
+	for _, i := range r.invitations {
+		if i.TokenHash == tokenHash {
+			return *i, nil
+		}
+	}
+
+	return models.Invitation{}, errors.New("record not found")
+}
+
+func (r *memoryInvitationRepository) Respond(id, status string) (bool, error) {
+	for _, i := range r.invitations {
+		if i.ID == id && i.Status == models.InvitationPending {
+			i.Status = status
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryInvitationRepository) Accept(id string, user *models.User, membership *models.Membership) (bool, error) {
+	if user != nil {
+		user.ID = "new"
+		membership.UserID = user.ID
+	}
+
+	if _, err := r.memberships.FindByUserId(membership.OrganizationID, membership.UserID); err == nil {
+		return false, repositories.ErrDuplicate
+	}
+
+	ok, err := r.Respond(id, models.InvitationAccepted)
+	if ok {
+		r.memberships.memberships = append(r.memberships.memberships, *membership)
+	}
+
+	return ok, err
+}
+
+// registeringUserService builds new users without storing them.
+type registeringUserService struct {
+	UserService
+}
+
+func (s *registeringUserService) NewUser(user models.RegisterUser) (models.User, []string, error) {
+	return models.User{Email: user.Email, Name: user.Name}, []string{auth.AuthMethodPassword}, nil
+}
+
+func newTestInvitationService(invitations ...*models.Invitation) (*invitationService, *loginTokenService) {
+	organization := &models.Organization{Base: models.Base{ID: "o1"}, Name: "Acme"}
+	for _, i := range invitations {
+		i.OrganizationID = organization.ID
+		i.Organization = organization
+	}
+
+	memberships := &memoryMembershipRepository{memberships: []models.Membership{
+		membership("m1", "o1", "owner", models.OrgRoleOwner),
+		membership("m2", "o1", "member", models.OrgRoleMember),
+	}}
+	tokens := &loginTokenService{}
+
+	return &invitationService{
+		repository:           &memoryInvitationRepository{invitations: invitations, memberships: memberships},
+		membershipRepository: memberships,
+		userRepository: &fixedUserRepository{users: []models.User{
+			{Base: models.Base{ID: "member"}, Email: "member@example.com"},
+			{Base: models.Base{ID: "u1"}, Email: "user@example.com"},
+		}},
+		userService:  &registeringUserService{},
+		tokenService: tokens,
+	}, tokens
+}
+
+func invitation(id, email, token, status string, expiresAt time.Time) *models.Invitation {
+	return &models.Invitation{
+		Base:      models.Base{ID: id},
+		Email:     email,
+		Role:      models.OrgRoleMember,
+		TokenHash: auth.HashToken(token),
+		Status:    status,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func TestInvitationAccept(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		invitation *models.Invitation
+		token      string
+		accept     models.AcceptInvitation
+		want       error
+		issued     bool
+	}{
+		{"registered user", invitation("i1", "user@example.com", "valid", models.InvitationPending, future), "valid", models.AcceptInvitation{}, nil, false},
+		{"new user", invitation("i1", "new@example.com", "valid", models.InvitationPending, future), "valid", models.AcceptInvitation{Name: "New"}, nil, true},
+		{"new user without name", invitation("i1", "new@example.com", "valid", models.InvitationPending, future), "valid", models.AcceptInvitation{}, ErrNameRequired, false},
+		{"unknown token", invitation("i1", "user@example.com", "valid", models.InvitationPending, future), "forged", models.AcceptInvitation{}, ErrInvalidInvitation, false},
+		{"expired", invitation("i1", "user@example.com", "valid", models.InvitationPending, time.Now().Add(-time.Second)), "valid", models.AcceptInvitation{}, ErrInvalidInvitation, false},
+		{"declined", invitation("i1", "user@example.com", "valid", models.InvitationDeclined, future), "valid", models.AcceptInvitation{}, ErrInvalidInvitation, false},
+		{"revoked", invitation("i1", "user@example.com", "valid", models.InvitationRevoked, future), "valid", models.AcceptInvitation{}, ErrInvalidInvitation, false},
+		{"already a member", invitation("i1", "member@example.com", "valid", models.InvitationPending, future), "valid", models.AcceptInvitation{}, ErrAlreadyMember, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tokens := newTestInvitationService(tt.invitation)
+
+			tt.accept.Token = tt.token
+			accepted, err := s.Accept(tt.accept, models.ClientInfo{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Accept() error = %v, want %v", err, tt.want)
+			}
+
+			if issued := tokens.methods != nil; issued != tt.issued || (accepted.Token != nil) != tt.issued {
+				t.Errorf("Accept() issued tokens = %v, want %v", issued, tt.issued)
+			}
+
+			if tt.want == nil && (accepted.Membership.OrganizationID != "o1" || accepted.Membership.Role != models.OrgRoleMember) {
+				t.Errorf("Accept() membership = %+v, want member of o1", accepted.Membership)
+			}
+		})
+	}
+}
+
+func TestInvitationIsSingleUse(t *testing.T) {
+	s, _ := newTestInvitationService(invitation("i1", "user@example.com", "valid", models.InvitationPending, time.Now().Add(time.Hour)))
+
+	if _, err := s.Accept(models.AcceptInvitation{Token: "valid"}, models.ClientInfo{}); err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+
+	if _, err := s.Accept(models.AcceptInvitation{Token: "valid"}, models.ClientInfo{}); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("Accept() replay error = %v, want ErrInvalidInvitation", err)
+	}
+
+	if err := s.Decline("valid"); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("Decline() after accept error = %v, want ErrInvalidInvitation", err)
+	}
+}
+
+func TestInvitationCreateRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		inviter    string
+		invitation models.CreateInvitation
+		want       error
+	}{
+		{"admin invites owner", models.OrgRoleAdmin, models.CreateInvitation{Email: "new@example.com", Role: models.OrgRoleOwner}, ErrOrgRoleForbidden},
+		{"member", models.OrgRoleOwner, models.CreateInvitation{Email: "member@example.com", Role: models.OrgRoleAdmin}, ErrAlreadyMember},
+		{"pending invitation", models.OrgRoleOwner, models.CreateInvitation{Email: "user@example.com", Role: models.OrgRoleMember}, ErrInvitationExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestInvitationService(invitation("i1", "user@example.com", "valid", models.InvitationPending, time.Now().Add(time.Hour)))
+
+			inviter := membership("m9", "o1", "inviter", tt.inviter)
+			if _, err := s.Create("o1", inviter, tt.invitation); !errors.Is(err, tt.want) {
+				t.Errorf("Create() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvitationRevokeIsScoped(t *testing.T) {
+	s, _ := newTestInvitationService(invitation("i1", "user@example.com", "valid", models.InvitationPending, time.Now().Add(time.Hour)))
+
+	if err := s.Revoke("o2", "i1"); !errors.Is(err, ErrUnknownInvitation) {
+		t.Fatalf("Revoke() from another organization error = %v, want ErrUnknownInvitation", err)
+	}
+
+	if err := s.Revoke("o1", "i1"); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	if err := s.Revoke("o1", "i1"); !errors.Is(err, ErrInvitationNotPending) {
+		t.Errorf("Revoke() twice error = %v, want ErrInvitationNotPending", err)
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/rs/zerolog/log"
+)
+
+type KeyService interface {
+	KeySet() *auth.KeySet
+	JWKS() auth.JWKS
+	Rotate() error
+}
+
+// NewKeyService loads the access token signing keys. Without any configured
+// key files the access token secret is used with HS256.
+func NewKeyService(cfg config.Config) (KeyService, error) {
+	log.Info().Msg("Creating new key service")
+
+	s := &keyService{
+		cfg: cfg,
+	}
+
+	if cfg.JwtKeyRotationInterval > 0 && cfg.JwtKeyDir == "" {
+		return nil, errors.New("key rotation requires a key directory")
+	}
+
+	if cfg.JwtAlgorithm == auth.AlgHS256 && len(cfg.JwtKeyFiles) == 0 && cfg.JwtKeyDir == "" {
+		s.keySet = auth.NewSecretKeySet(cfg.AccessTokenSecret)
+		return s, nil
+	}
+
+	s.keySet = &auth.KeySet{}
+
+	if cfg.JwtKeyDir != "" {
+		err := os.MkdirAll(cfg.JwtKeyDir, 0700)
+		if err != nil {
+			return nil, err
+		}
+
+		files, err := s.keyDirFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(files) == 0 {
+			err = s.generate()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+type keyService struct {
+	keySet *auth.KeySet
+	cfg    config.Config
+}
+
+func (s *keyService) KeySet() *auth.KeySet {
+	return s.keySet
+}
+
+func (s *keyService) JWKS() auth.JWKS {
+	return s.keySet.JWKS()
+}
+
+// Rotate generates a new signing key in the key directory and removes keys
+// that were retired long enough ago for every token they signed to expire.
+// Other instances sharing the directory pick the new key up on their next
+// rotation, so a key younger than half the interval is not rotated again.
+func (s *keyService) Rotate() error {
+	if s.cfg.JwtKeyDir == "" {
+		return errors.New("key rotation requires a key directory")
+	}
+
+	files, err := s.keyDirFiles()
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 || time.Since(files[len(files)-1].ModTime()) >= s.cfg.JwtKeyRotationInterval/2 {
+		err = s.generate()
+		if err != nil {
+			return err
+		}
+
+		files, err = s.keyDirFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(files)-1; i++ {
+		retiredAt := files[i+1].ModTime()
+		if time.Since(retiredAt) > s.cfg.AccessTokenLifespan {
+			log.Info().Str("file", files[i].Name()).Msg("Removing retired signing key")
+
+			err = os.Remove(filepath.Join(s.cfg.JwtKeyDir, files[i].Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.load()
+}
+
+// load reads the configured key files followed by the key directory. The
+// first private key file signs, unless the key directory holds a newer one.
+func (s *keyService) load() error {
+	var keys []*auth.Key
+	var signing *auth.Key
+
+	for _, path := range s.cfg.JwtKeyFiles {
+		key, err := auth.LoadKeyFile(path)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+		if signing == nil && key.CanSign() {
+			signing = key
+		}
+	}
+
+	if s.cfg.JwtKeyDir != "" {
+		files, err := s.keyDirFiles()
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			key, err := auth.LoadKeyFile(filepath.Join(s.cfg.JwtKeyDir, f.Name()))
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, key)
+			if key.CanSign() {
+				signing = key
+			}
+		}
+	}
+
+	if signing == nil {
+		return errors.New("no private key found to sign access tokens")
+	}
+
+	log.Info().Str("kid", signing.ID).Str("alg", signing.Method.Alg()).Int("keys", len(keys)).Msg("Loaded signing keys")
+
+	return s.keySet.Replace(signing, keys)
+}
+
+func (s *keyService) generate() error {
+	key, err := auth.GenerateKey(s.cfg.JwtAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d.pem", time.Now().UnixNano())
+	log.Info().Str("kid", key.ID).Str("file", name).Msg("Generated new signing key")
+
+	return auth.WriteKeyFile(filepath.Join(s.cfg.JwtKeyDir, name), key)
+}
+
+// keyDirFiles lists the PEM files of the key directory, oldest first.
+func (s *keyService) keyDirFiles() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(s.cfg.JwtKeyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pem" {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, info)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	return files, nil
+}
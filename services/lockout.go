@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrUnknownLockout = errors.New("unknown lockout")
+
+// LockedError is returned while an account or address has to wait before
+// trying to log in again.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+type LockoutService interface {
+	Attempt(email, ip string) error
+	Succeed(email, ip string) error
+	AttemptMfa(userId string) error
+	SucceedMfa(userId string) error
+	FindLocked() ([]models.LoginAttempt, error)
+	Unlock(key string) error
+	Prune() error
+}
+
+func NewLockoutService(repository repositories.LoginAttemptRepository, cfg config.Config) LockoutService {
+	log.Info().Msg("Creating new lockout service")
+
+	return &lockoutService{
+		repository: repository,
+		cfg:        cfg,
+	}
+}
+
+type lockoutService struct {
+	repository repositories.LoginAttemptRepository
+	cfg        config.Config
+}
+
+// limit is the number of failures a key may collect within the attempt
+// window. Delayed keys also wait progressively longer after every failure.
+type limit struct {
+	key     string
+	max     int
+	delayed bool
+}
+
+// Attempt reserves a login for the account and the address before the
+// password is checked. The attempt counts as failed until Succeed is called,
+// so concurrent guesses can not get past the limits. It is rejected while
+// either is locked or the delay after the last failure of the account runs.
+func (s *lockoutService) Attempt(email, ip string) error {
+	return s.attempt(
+		limit{key: accountKey(email), max: s.cfg.LoginMaxAccountFailures, delayed: true},
+		limit{key: ipKey(ip), max: s.cfg.LoginMaxIpFailures},
+	)
+}
+
+// Succeed clears the failures of the account and takes the reserved attempt
+// back from the address.
+func (s *lockoutService) Succeed(email, ip string) error {
+	return s.repository.Update([]string{accountKey(email), ipKey(ip)}, func(attempts []*models.LoginAttempt) error {
+		account, address := attempts[0], attempts[1]
+
+		account.Failures = 0
+		account.LockedUntil = nil
+
+		if address.Failures > 0 {
+			address.Failures--
+		}
+
+		return nil
+	})
+}
+
+// AttemptMfa reserves a second factor attempt for the user the same way, so
+// codes can not be guessed with one challenge or many. It is only rejected
+// once the user is locked.
+func (s *lockoutService) AttemptMfa(userId string) error {
+	return s.attempt(limit{key: mfaKey(userId), max: s.cfg.LoginMaxAccountFailures})
+}
+
+func (s *lockoutService) SucceedMfa(userId string) error {
+	return s.repository.Reset(mfaKey(userId))
+}
+
+func (s *lockoutService) attempt(limits ...limit) error {
+	keys := make([]string, len(limits))
+	for i, l := range limits {
+		keys[i] = l.key
+	}
+
+	var locked error
+	err := s.repository.Update(keys, func(attempts []*models.LoginAttempt) error {
+		now := time.Now()
+
+		var wait time.Duration
+		for i, a := range attempts {
+			wait = maxDuration(wait, s.wait(a, limits[i], now))
+		}
+
+		// A rejected attempt is not counted, but a lock it caused is kept.
+		if wait > 0 {
+			locked = &LockedError{RetryAfter: time.Duration(math.Ceil(wait.Seconds())) * time.Second}
+			return nil
+		}
+
+		for _, a := range attempts {
+			s.record(a, now)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return locked
+}
+
+// wait returns how long the key has to wait before its next attempt and
+// locks it once it collected its limit of failures within the window.
+func (s *lockoutService) wait(a *models.LoginAttempt, l limit, now time.Time) time.Duration {
+	if a.LockedUntil != nil {
+		if a.LockedUntil.After(now) {
+			return a.LockedUntil.Sub(now)
+		}
+
+		// A served lock starts the count over, the failures that caused it
+		// could still be within the window and would lock again at once.
+		a.Failures = 0
+		a.LockedUntil = nil
+		return 0
+	}
+
+	if now.Sub(a.LastFailureAt) >= s.cfg.LoginAttemptWindow {
+		return 0
+	}
+
+	if l.max > 0 && a.Failures >= l.max {
+		log.Warn().Str("key", a.Key).Int("failures", a.Failures).Msg("Locking out after too many failed logins")
+
+		lockedUntil := now.Add(s.cfg.LoginLockoutDuration)
+		a.LockedUntil = &lockedUntil
+		return s.cfg.LoginLockoutDuration
+	}
+
+	if l.delayed {
+		return maxDuration(0, a.LastFailureAt.Add(s.delay(a.Failures)).Sub(now))
+	}
+
+	return 0
+}
+
+// record counts an attempt, starting over once the window has passed.
+func (s *lockoutService) record(a *models.LoginAttempt, now time.Time) {
+	if now.Sub(a.LastFailureAt) >= s.cfg.LoginAttemptWindow {
+		a.Failures = 0
+	}
+
+	if a.LockedUntil != nil && !a.LockedUntil.After(now) {
+		a.LockedUntil = nil
+	}
+
+	a.Failures++
+	a.LastFailureAt = now
+}
+
+func (s *lockoutService) FindLocked() ([]models.LoginAttempt, error) {
+	return s.repository.FindLocked()
+}
+
+func (s *lockoutService) Unlock(key string) error {
+	attempts, err := s.repository.Find(key)
+	if err != nil {
+		return err
+	}
+
+	if len(attempts) == 0 {
+		return ErrUnknownLockout
+	}
+
+	return s.repository.Reset(key)
+}
+
+func (s *lockoutService) Prune() error {
+	return s.repository.Prune(s.cfg.LoginAttemptWindow)
+}
+
+// delay doubles with every failure, starting at the base delay.
+func (s *lockoutService) delay(failures int) time.Duration {
+	if failures <= 0 || s.cfg.LoginBaseDelay <= 0 {
+		return 0
+	}
+
+	d := s.cfg.LoginBaseDelay
+	for i := 1; i < failures && d < s.cfg.LoginMaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > s.cfg.LoginMaxDelay {
+		d = s.cfg.LoginMaxDelay
+	}
+
+	return d
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func mfaKey(userId string) string {
+	return "mfa:" + userId
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+func newTestLockoutService(cfg config.Config) *lockoutService {
+	return &lockoutService{repository: repositories.NewMemoryLoginAttemptRepository(), cfg: cfg}
+}
+
+func TestLockoutDelay(t *testing.T) {
+	s := &lockoutService{cfg: config.Config{LoginBaseDelay: time.Second, LoginMaxDelay: 5 * time.Second}}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{50, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := s.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutWait(t *testing.T) {
+	s := &lockoutService{cfg: config.Config{
+		LoginAttemptWindow:   15 * time.Minute,
+		LoginLockoutDuration: 10 * time.Minute,
+		LoginBaseDelay:       time.Second,
+		LoginMaxDelay:        time.Minute,
+	}}
+
+	now := time.Now()
+	lockedUntil := now.Add(3 * time.Minute)
+	lockEnded := now.Add(-time.Second)
+	account := limit{max: 5, delayed: true}
+	address := limit{max: 5}
+
+	tests := []struct {
+		name       string
+		attempt    models.LoginAttempt
+		limit      limit
+		want       time.Duration
+		wantLocked bool
+	}{
+		{"new", models.LoginAttempt{}, account, 0, false},
+		{"locked", models.LoginAttempt{Failures: 1, LastFailureAt: now, LockedUntil: &lockedUntil}, account, 3 * time.Minute, true},
+		{"delayed", models.LoginAttempt{Failures: 2, LastFailureAt: now.Add(-time.Second)}, account, time.Second, false},
+		{"delay passed", models.LoginAttempt{Failures: 2, LastFailureAt: now.Add(-3 * time.Second)}, account, 0, false},
+		{"address not delayed", models.LoginAttempt{Failures: 2, LastFailureAt: now}, address, 0, false},
+		{"limit reached", models.LoginAttempt{Failures: 5, LastFailureAt: now.Add(-10 * time.Minute)}, address, 10 * time.Minute, true},
+		{"window passed", models.LoginAttempt{Failures: 5, LastFailureAt: now.Add(-time.Hour)}, account, 0, false},
+		{"lock ended within window", models.LoginAttempt{Failures: 5, LastFailureAt: now.Add(-10 * time.Minute), LockedUntil: &lockEnded}, address, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.attempt
+			got := s.wait(&a, tt.limit, now)
+
+			if got > tt.want || got < tt.want-time.Millisecond {
+				t.Errorf("wait() = %v, want %v", got, tt.want)
+			}
+
+			if locked := a.LockedUntil != nil; locked != tt.wantLocked {
+				t.Errorf("wait() locked = %v, want %v", locked, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestLockoutRecord(t *testing.T) {
+	s := &lockoutService{cfg: config.Config{LoginAttemptWindow: 15 * time.Minute}}
+
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+
+	a := models.LoginAttempt{Failures: 3, LastFailureAt: now.Add(-time.Minute), LockedUntil: &expired}
+	s.record(&a, now)
+	if a.Failures != 4 || !a.LastFailureAt.Equal(now) || a.LockedUntil != nil {
+		t.Errorf("record() within window = %+v, want 4 failures and no lock", a)
+	}
+
+	a = models.LoginAttempt{Failures: 3, LastFailureAt: now.Add(-time.Hour)}
+	s.record(&a, now)
+	if a.Failures != 1 {
+		t.Errorf("record() after window failures = %d, want 1", a.Failures)
+	}
+}
+
+func TestLockoutAttemptLocksAccount(t *testing.T) {
+	s := newTestLockoutService(config.Config{
+		LoginAttemptWindow:      15 * time.Minute,
+		LoginMaxAccountFailures: 3,
+		LoginMaxIpFailures:      10,
+		LoginLockoutDuration:    15 * time.Minute,
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := s.Attempt("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Attempt() %d error = %v", i, err)
+		}
+	}
+
+	var locked *LockedError
+	if err := s.Attempt("User@example.com", "10.0.0.2"); !errors.As(err, &locked) {
+		t.Fatalf("Attempt() error = %v, want LockedError", err)
+	}
+
+	if err := s.Attempt("other@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("Attempt() other account error = %v", err)
+	}
+
+	lockouts, err := s.FindLocked()
+	if err != nil {
+		t.Fatalf("FindLocked() error = %v", err)
+	}
+
+	if len(lockouts) != 1 || lockouts[0].Key != accountKey("user@example.com") {
+		t.Errorf("FindLocked() = %+v, want the account", lockouts)
+	}
+}
+
+func TestLockoutSucceedReleasesAttempt(t *testing.T) {
+	s := newTestLockoutService(config.Config{
+		LoginAttemptWindow:      15 * time.Minute,
+		LoginMaxAccountFailures: 2,
+		LoginMaxIpFailures:      2,
+		LoginLockoutDuration:    15 * time.Minute,
+	})
+
+	for i := 0; i < 5; i++ {
+		if err := s.Attempt("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Attempt() %d error = %v", i, err)
+		}
+
+		if err := s.Succeed("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("Succeed() %d error = %v", i, err)
+		}
+	}
+
+	attempts, err := s.repository.Find(accountKey("user@example.com"), ipKey("10.0.0.1"))
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	if len(attempts) != 0 {
+		t.Errorf("Find() = %+v, want no attempts left", attempts)
+	}
+}
+
+func TestLockoutAttemptMfaLocksUser(t *testing.T) {
+	s := newTestLockoutService(config.Config{
+		LoginAttemptWindow:      15 * time.Minute,
+		LoginMaxAccountFailures: 3,
+		LoginLockoutDuration:    15 * time.Minute,
+		LoginBaseDelay:          time.Minute,
+		LoginMaxDelay:           time.Minute,
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := s.AttemptMfa("u1"); err != nil {
+			t.Fatalf("AttemptMfa() %d error = %v", i, err)
+		}
+	}
+
+	var locked *LockedError
+	if err := s.AttemptMfa("u1"); !errors.As(err, &locked) {
+		t.Fatalf("AttemptMfa() error = %v, want LockedError", err)
+	}
+
+	if err := s.AttemptMfa("u2"); err != nil {
+		t.Errorf("AttemptMfa() other user error = %v", err)
+	}
+}
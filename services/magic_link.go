@@ -0,0 +1,148 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/mailer"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired login link")
+
+type MagicLinkService interface {
+	Request(email string) (string, error)
+	Login(token, nonce string, client models.ClientInfo) (models.Token, error)
+	DeleteExpired() error
+}
+
+func NewMagicLinkService(repository repositories.MagicLinkRepository, userRepository repositories.UserRepository, tokenService TokenService, mfaService MfaService, mailer mailer.Mailer, cfg config.Config) MagicLinkService {
+	log.Info().Msg("Creating new magic link service")
+
+	return &magicLinkService{
+		repository:     repository,
+		userRepository: userRepository,
+		tokenService:   tokenService,
+		mfaService:     mfaService,
+		mailer:         mailer,
+		cfg:            cfg,
+	}
+}
+
+type magicLinkService struct {
+	repository     repositories.MagicLinkRepository
+	userRepository repositories.UserRepository
+	tokenService   TokenService
+	mfaService     MfaService
+	mailer         mailer.Mailer
+	cfg            config.Config
+}
+
+// Request mails a login link and returns the nonce the requesting browser
+// must present when the link is opened. A nonce is returned for unknown
+// emails too so the response does not reveal whether the account exists.
+func (s *magicLinkService) Request(email string) (string, error) {
+	nonce, err := auth.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.userRepository.FindByEmail(email)
+	if err != nil {
+		return nonce, nil
+	}
+
+	// Sent in the background so the response time does not tell whether
+	// the account exists.
+	go func() {
+		err := s.send(user, nonce)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to send login link")
+		}
+	}()
+
+	return nonce, nil
+}
+
+// Login consumes the link. Opening it proves ownership of the address so
+// the email is marked as verified.
+func (s *magicLinkService) Login(token, nonce string, client models.ClientInfo) (models.Token, error) {
+	var res models.Token
+
+	link, err := s.repository.FindByHash(auth.HashToken(token))
+	if err != nil || time.Now().After(link.ExpiresAt) {
+		return res, ErrInvalidMagicLink
+	}
+
+	if subtle.ConstantTimeCompare([]byte(link.NonceHash), []byte(auth.HashToken(nonce))) != 1 {
+		return res, ErrInvalidMagicLink
+	}
+
+	ok, err := s.repository.MarkUsed(link.ID)
+	if err != nil {
+		return res, err
+	}
+
+	if !ok {
+		return res, ErrInvalidMagicLink
+	}
+
+	user, err := s.userRepository.FindById(link.UserID)
+	if err != nil {
+		return res, ErrInvalidMagicLink
+	}
+
+	if user.VerifiedAt == nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+
+		err = s.userRepository.Update(&user)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	err = s.mfaService.Challenge(user, auth.AuthMethodOTP)
+	if err != nil {
+		return res, err
+	}
+
+	return s.tokenService.Issue(user, client, auth.AuthMethodOTP)
+}
+
+func (s *magicLinkService) DeleteExpired() error {
+	return s.repository.DeleteExpired()
+}
+
+func (s *magicLinkService) send(user models.User, nonce string) error {
+	token, err := auth.RandomToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.Create(&models.MagicLink{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		NonceHash: auth.HashToken(nonce),
+		ExpiresAt: time.Now().Add(s.cfg.MagicLinkLifespan),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.PublicUrl + "/users/login/magic/callback?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below in the same browser you requested it from to sign in:\n\n%s\n\nThe link can be used once and expires in %s.\n",
+			user.Name, link, s.cfg.MagicLinkLifespan),
+	})
+}
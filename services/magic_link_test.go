@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memoryMagicLinkRepository keeps login links in memory.
+type memoryMagicLinkRepository struct {
+	repositories.MagicLinkRepository
+	links []*models.MagicLink
+}
+
+func (r *memoryMagicLinkRepository) FindByHash(hash string) (models.MagicLink, error) {
+	for _, l := range r.links {
+		if l.TokenHash == hash {
+			return *l, nil
+		}
+	}
+
+	return models.MagicLink{}, errors.New("record not found")
+}
+
+func (r *memoryMagicLinkRepository) MarkUsed(id string) (bool, error) {
+	for _, l := range r.links {
+		if l.ID == id && l.UsedAt == nil {
+			now := time.Now()
+			l.UsedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// loginTokenService records the methods of issued tokens and challenges.
+type loginTokenService struct {
+	TokenService
+	methods          []string
+	challengeMethods []string
+}
+
+func (s *loginTokenService) Issue(user models.User, client models.ClientInfo, methods ...string) (models.Token, error) {
+	s.methods = methods
+	return models.Token{Token: "access"}, nil
+}
+
+func (s *loginTokenService) IssueChallenge(userId, tokenType string, lifespan time.Duration, methods ...string) (string, error) {
+	s.challengeMethods = methods
+	return "challenge", nil
+}
+
+func newTestMagicLinkService(users ...models.User) (*magicLinkService, *loginTokenService) {
+	tokens := &loginTokenService{}
+
+	return &magicLinkService{
+		repository: &memoryMagicLinkRepository{links: []*models.MagicLink{
+			{Base: models.Base{ID: "l1"}, UserID: "u1", TokenHash: auth.HashToken("valid"), NonceHash: auth.HashToken("nonce"), ExpiresAt: time.Now().Add(time.Minute)},
+			{Base: models.Base{ID: "l2"}, UserID: "u1", TokenHash: auth.HashToken("expired"), NonceHash: auth.HashToken("nonce"), ExpiresAt: time.Now().Add(-time.Second)},
+			{Base: models.Base{ID: "l3"}, UserID: "m1", TokenHash: auth.HashToken("mfa"), NonceHash: auth.HashToken("nonce"), ExpiresAt: time.Now().Add(time.Minute)},
+		}},
+		userRepository: &updatingUserRepository{fixedUserRepository{users: users}},
+		tokenService:   tokens,
+		mfaService:     &mfaService{tokenService: tokens},
+	}, tokens
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		want  error
+	}{
+		{"valid", "valid", "nonce", nil},
+		{"other browser", "valid", "other", ErrInvalidMagicLink},
+		{"no nonce", "valid", "", ErrInvalidMagicLink},
+		{"unknown", "forged", "nonce", ErrInvalidMagicLink},
+		{"expired", "expired", "nonce", ErrInvalidMagicLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tokens := newTestMagicLinkService(models.User{Base: models.Base{ID: "u1"}})
+
+			_, err := s.Login(tt.token, tt.nonce, models.ClientInfo{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.want)
+			}
+
+			if tt.want != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(tokens.methods, []string{auth.AuthMethodOTP}) {
+				t.Errorf("Login() amr = %v, want [otp]", tokens.methods)
+			}
+
+			user, _ := s.userRepository.FindById("u1")
+			if user.VerifiedAt == nil {
+				t.Errorf("Login() did not verify the email")
+			}
+		})
+	}
+}
+
+func TestMagicLinkIsSingleUse(t *testing.T) {
+	s, _ := newTestMagicLinkService(models.User{Base: models.Base{ID: "u1"}})
+
+	if _, err := s.Login("valid", "nonce", models.ClientInfo{}); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if _, err := s.Login("valid", "nonce", models.ClientInfo{}); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("Login() replay error = %v, want ErrInvalidMagicLink", err)
+	}
+}
+
+func TestMagicLinkRequiresSecondFactor(t *testing.T) {
+	s, tokens := newTestMagicLinkService(models.User{Base: models.Base{ID: "m1"}, MfaEnabled: true})
+
+	var mfaErr *MfaRequiredError
+	if _, err := s.Login("mfa", "nonce", models.ClientInfo{}); !errors.As(err, &mfaErr) {
+		t.Fatalf("Login() error = %v, want MfaRequiredError", err)
+	}
+
+	if tokens.methods != nil {
+		t.Errorf("Login() issued tokens before the second factor")
+	}
+
+	if !reflect.DeepEqual(tokens.challengeMethods, []string{auth.AuthMethodOTP}) {
+		t.Errorf("Login() challenge amr = %v, want [otp]", tokens.challengeMethods)
+	}
+}
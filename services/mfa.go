@@ -0,0 +1,330 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMfaAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	ErrMfaNotEnabled     = errors.New("multi-factor authentication is not enabled")
+	ErrMfaNotEnrolled    = errors.New("multi-factor authentication enrollment was not started")
+	ErrInvalidMfaCode    = errors.New("invalid multi-factor authentication code")
+)
+
+// MfaRequiredError is returned by login when the password was correct but a
+// second factor still has to be presented with the challenge token.
+type MfaRequiredError struct {
+	Challenge models.MfaChallenge
+}
+
+func (e *MfaRequiredError) Error() string {
+	return "multi-factor authentication required"
+}
+
+type MfaService interface {
+	EnrollTotp(userId string) (models.TotpEnrollment, error)
+	ConfirmTotp(userId, code string) (models.RecoveryCodes, error)
+	DisableTotp(userId, code string) error
+	RegenerateRecoveryCodes(userId, code string) (models.RecoveryCodes, error)
+	Challenge(user models.User, methods ...string) error
+	Verify(login models.LoginMfa, client models.ClientInfo) (models.Token, error)
+}
+
+func NewMfaService(recoveryCodeRepository repositories.RecoveryCodeRepository, userRepository repositories.UserRepository, tokenService TokenService, lockoutService LockoutService, cfg config.Config) MfaService {
+	log.Info().Msg("Creating new mfa service")
+
+	return &mfaService{
+		recoveryCodeRepository: recoveryCodeRepository,
+		userRepository:         userRepository,
+		tokenService:           tokenService,
+		lockoutService:         lockoutService,
+		cfg:                    cfg,
+	}
+}
+
+type mfaService struct {
+	recoveryCodeRepository repositories.RecoveryCodeRepository
+	userRepository         repositories.UserRepository
+	tokenService           TokenService
+	lockoutService         LockoutService
+	cfg                    config.Config
+}
+
+// EnrollTotp creates a new secret for the user. It is only enforced once
+// confirmed with a valid code.
+func (s *mfaService) EnrollTotp(userId string) (models.TotpEnrollment, error) {
+	var enrollment models.TotpEnrollment
+
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return enrollment, err
+	}
+
+	if user.MfaEnabled {
+		return enrollment, ErrMfaAlreadyEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return enrollment, err
+	}
+
+	user.MfaSecret = secret
+	err = s.userRepository.Update(&user)
+	if err != nil {
+		return enrollment, err
+	}
+
+	enrollment.Secret = secret
+	enrollment.ProvisioningURI = auth.TOTPProvisioningURI(secret, s.cfg.MfaIssuer, user.Email)
+
+	return enrollment, nil
+}
+
+func (s *mfaService) ConfirmTotp(userId, code string) (models.RecoveryCodes, error) {
+	var codes models.RecoveryCodes
+
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return codes, err
+	}
+
+	if user.MfaEnabled {
+		return codes, ErrMfaAlreadyEnabled
+	}
+
+	if user.MfaSecret == "" {
+		return codes, ErrMfaNotEnrolled
+	}
+
+	if !s.verifyTotp(&user, code) {
+		return codes, ErrInvalidMfaCode
+	}
+
+	user.MfaEnabled = true
+	err = s.userRepository.Update(&user)
+	if err != nil {
+		return codes, err
+	}
+
+	return s.generateRecoveryCodes(user.ID)
+}
+
+func (s *mfaService) DisableTotp(userId, code string) error {
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return err
+	}
+
+	if !user.MfaEnabled {
+		return ErrMfaNotEnabled
+	}
+
+	ok, err := s.verify(&user, code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidMfaCode
+	}
+
+	user.MfaEnabled = false
+	user.MfaSecret = ""
+	user.MfaLastStep = 0
+	err = s.userRepository.Update(&user)
+	if err != nil {
+		return err
+	}
+
+	return s.recoveryCodeRepository.DeleteByUserId(user.ID)
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(userId, code string) (models.RecoveryCodes, error) {
+	var codes models.RecoveryCodes
+
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return codes, err
+	}
+
+	if !user.MfaEnabled {
+		return codes, ErrMfaNotEnabled
+	}
+
+	if !s.verifyTotp(&user, code) {
+		return codes, ErrInvalidMfaCode
+	}
+
+	return s.generateRecoveryCodes(user.ID)
+}
+
+// Challenge returns a MfaRequiredError if the user has a second factor. The
+// methods of the first factor are kept in the challenge for Verify.
+func (s *mfaService) Challenge(user models.User, methods ...string) error {
+	if !user.MfaEnabled {
+		return nil
+	}
+
+	token, err := s.tokenService.IssueChallenge(user.ID, auth.TokenTypeMfa, s.cfg.MfaTokenLifespan, methods...)
+	if err != nil {
+		return err
+	}
+
+	return &MfaRequiredError{
+		Challenge: models.MfaChallenge{
+			MfaRequired: true,
+			MfaToken:    token,
+		},
+	}
+}
+
+// Verify completes a login with a TOTP or recovery code. Attempts count
+// towards the lockout of the user and a locked out user's challenge is
+// revoked, so the login has to start over once the lock ends. A challenge
+// can only complete one login.
+func (s *mfaService) Verify(login models.LoginMfa, client models.ClientInfo) (models.Token, error) {
+	var token models.Token
+
+	claims, err := s.tokenService.ValidateChallenge(login.MfaToken, auth.TokenTypeMfa)
+	if err != nil {
+		return token, err
+	}
+
+	err = s.lockoutService.AttemptMfa(claims.UserID)
+	var lockedErr *LockedError
+	if errors.As(err, &lockedErr) {
+		revokeErr := s.tokenService.RevokeChallenge(claims)
+		if revokeErr != nil {
+			log.Error().Err(revokeErr).Str("user_id", claims.UserID).Msg("Failed to revoke mfa challenge")
+		}
+	}
+	if err != nil {
+		return token, err
+	}
+
+	user, err := s.userRepository.FindById(claims.UserID)
+	if err != nil {
+		return token, err
+	}
+
+	if !user.MfaEnabled {
+		return token, ErrMfaNotEnabled
+	}
+
+	ok, err := s.verify(&user, login.Code)
+	if err != nil {
+		return token, err
+	}
+
+	if !ok {
+		return token, ErrInvalidMfaCode
+	}
+
+	err = s.lockoutService.SucceedMfa(user.ID)
+	if err != nil {
+		return token, err
+	}
+
+	err = s.tokenService.RevokeChallenge(claims)
+	if err != nil {
+		return token, err
+	}
+
+	methods := append([]string(nil), claims.AuthMethods...)
+	for _, method := range []string{auth.AuthMethodOTP, auth.AuthMethodMFA} {
+		if !contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+
+	return s.tokenService.Issue(user, client, methods...)
+}
+
+// verify accepts either a TOTP code or an unused recovery code.
+func (s *mfaService) verify(user *models.User, code string) (bool, error) {
+	if s.verifyTotp(user, code) {
+		return true, nil
+	}
+
+	recoveryCode, err := s.recoveryCodeRepository.FindUnused(user.ID, auth.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, nil
+	}
+
+	return s.recoveryCodeRepository.MarkUsed(recoveryCode.ID)
+}
+
+// verifyTotp checks the code and remembers its time step so the same code
+// can not be used twice.
+func (s *mfaService) verifyTotp(user *models.User, code string) bool {
+	step, ok := auth.ValidateTOTP(user.MfaSecret, code, time.Now(), 1)
+	if !ok || step <= user.MfaLastStep {
+		return false
+	}
+
+	user.MfaLastStep = step
+	err := s.userRepository.Update(user)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to store last used TOTP step")
+		return false
+	}
+
+	return true
+}
+
+func (s *mfaService) generateRecoveryCodes(userId string) (models.RecoveryCodes, error) {
+	var codes models.RecoveryCodes
+
+	err := s.recoveryCodeRepository.DeleteByUserId(userId)
+	if err != nil {
+		return codes, err
+	}
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return codes, err
+		}
+
+		err = s.recoveryCodeRepository.Create(&models.RecoveryCode{
+			UserID:   userId,
+			CodeHash: auth.HashToken(normalizeRecoveryCode(code)),
+		})
+		if err != nil {
+			return codes, err
+		}
+
+		codes.Codes = append(codes.Codes, code)
+	}
+
+	return codes, nil
+}
+
+// newRecoveryCode returns a code like "k3j9d-q8x2m".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// updatingUserRepository serves a fixed set of users and keeps updates.
+type updatingUserRepository struct {
+	fixedUserRepository
+}
+
+func (r *updatingUserRepository) Update(user *models.User) error {
+	for i, u := range r.users {
+		if u.ID == user.ID {
+			r.users[i] = *user
+		}
+	}
+
+	return nil
+}
+
+// challengeTokenService accepts any challenge and records what it is asked
+// to issue and revoke.
+type challengeTokenService struct {
+	TokenService
+	challenge *auth.Claims
+	revoked   []string
+	methods   []string
+}
+
+func (s *challengeTokenService) ValidateChallenge(challenge, tokenType string) (*auth.Claims, error) {
+	return s.challenge, nil
+}
+
+func (s *challengeTokenService) RevokeChallenge(claims *auth.Claims) error {
+	s.revoked = append(s.revoked, claims.ID)
+	return nil
+}
+
+func (s *challengeTokenService) Issue(user models.User, client models.ClientInfo, methods ...string) (models.Token, error) {
+	s.methods = methods
+	return models.Token{Token: "access"}, nil
+}
+
+// allowingLockoutService never locks anyone out.
+type allowingLockoutService struct {
+	LockoutService
+}
+
+func (s *allowingLockoutService) AttemptMfa(userId string) error {
+	return nil
+}
+
+func (s *allowingLockoutService) SucceedMfa(userId string) error {
+	return nil
+}
+
+func TestMfaVerify(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		methods []string
+		want    []string
+	}{
+		{"password", []string{auth.AuthMethodPassword}, []string{auth.AuthMethodPassword, auth.AuthMethodOTP, auth.AuthMethodMFA}},
+		{"magic link", []string{auth.AuthMethodOTP}, []string{auth.AuthMethodOTP, auth.AuthMethodMFA}},
+		{"federated", []string{auth.AuthMethodFederated}, []string{auth.AuthMethodFederated, auth.AuthMethodOTP, auth.AuthMethodMFA}},
+		{"passkey", []string{auth.AuthMethodHardware}, []string{auth.AuthMethodHardware, auth.AuthMethodOTP, auth.AuthMethodMFA}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &updatingUserRepository{fixedUserRepository{users: []models.User{
+				{Base: models.Base{ID: "u1"}, MfaEnabled: true, MfaSecret: secret},
+			}}}
+			tokens := &challengeTokenService{challenge: &auth.Claims{UserID: "u1", AuthMethods: tt.methods}}
+			tokens.challenge.ID = "c1"
+
+			s := &mfaService{userRepository: users, tokenService: tokens, lockoutService: &allowingLockoutService{}}
+
+			code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now()))
+			if err != nil {
+				t.Fatalf("TOTPCode() error = %v", err)
+			}
+
+			if _, err := s.Verify(models.LoginMfa{MfaToken: "challenge", Code: code}, models.ClientInfo{}); err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(tokens.methods, tt.want) {
+				t.Errorf("Verify() amr = %v, want %v", tokens.methods, tt.want)
+			}
+
+			if len(tokens.revoked) != 1 || tokens.revoked[0] != "c1" {
+				t.Errorf("Verify() revoked challenges = %v, want [c1]", tokens.revoked)
+			}
+		})
+	}
+}
@@ -0,0 +1,600 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/oidc"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// OAuthError is an error response as defined by RFC 6749 section 5.2.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+var (
+	ErrScopeNotHeld        = errors.New("machine clients can only be granted scopes whose permissions you hold")
+	ErrSymmetricSigningKey = errors.New("the oauth provider requires an asymmetric access token signing key")
+)
+
+var scopeDescriptions = map[string]string{
+	models.ScopeOpenID:        "Sign you in with your account",
+	models.ScopeProfile:       "Read your name",
+	models.ScopeEmail:         "Read your email address",
+	models.ScopeOfflineAccess: "Stay signed in when you are not using the app",
+}
+
+type OAuthService interface {
+	CreateClient(ownerId string, client models.CreateOAuthClient) (models.OAuthClientCredentials, error)
+	CreateMachineClient(claims *auth.Claims, client models.CreateMachineClient) (models.OAuthClientCredentials, error)
+	FindAllClients(query models.PaginationQuery) ([]models.OAuthClient, error)
+	DeleteClient(id string) error
+
+	Authorize(req models.AuthorizeRequest) (models.ConsentScreen, error)
+	Decide(userId string, decision models.AuthorizeDecision) (models.AuthorizeResponse, error)
+	Token(req models.OAuthTokenRequest) (models.OAuthTokenResponse, error)
+	UserInfo(claims *auth.Claims) (models.UserInfo, error)
+	Introspect(req models.IntrospectionRequest) (models.IntrospectionResponse, error)
+	Configuration() models.OpenIDConfiguration
+	DeleteExpiredCodes() error
+}
+
+// NewOAuthService fails with an HMAC signing key, since clients could not
+// verify the ID tokens signed with it without holding the secret.
+func NewOAuthService(clientRepository repositories.OAuthClientRepository, codeRepository repositories.OAuthCodeRepository, userRepository repositories.UserRepository, tokenService TokenService, roleService RoleService, keyService KeyService, cfg config.Config) (OAuthService, error) {
+	log.Info().Msg("Creating new oauth service")
+
+	if keyService.KeySet().SigningKey().Symmetric() {
+		return nil, ErrSymmetricSigningKey
+	}
+
+	return &oauthService{
+		clientRepository: clientRepository,
+		codeRepository:   codeRepository,
+		userRepository:   userRepository,
+		tokenService:     tokenService,
+		roleService:      roleService,
+		keyService:       keyService,
+		cfg:              cfg,
+	}, nil
+}
+
+type oauthService struct {
+	clientRepository repositories.OAuthClientRepository
+	codeRepository   repositories.OAuthCodeRepository
+	userRepository   repositories.UserRepository
+	tokenService     TokenService
+	roleService      RoleService
+	keyService       KeyService
+	cfg              config.Config
+}
+
+func (s *oauthService) CreateClient(ownerId string, client models.CreateOAuthClient) (models.OAuthClientCredentials, error) {
+	var credentials models.OAuthClientCredentials
+
+	for _, scope := range client.Scopes {
+		if _, ok := scopeDescriptions[scope]; !ok {
+			return credentials, oauthError("invalid_scope", "unsupported scope "+scope)
+		}
+	}
+
+	newClient := models.OAuthClient{
+		Name:         client.Name,
+		Public:       client.Public,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		OwnerID:      ownerId,
+	}
+
+	if !client.Public {
+		secret, err := auth.RandomToken()
+		if err != nil {
+			return credentials, err
+		}
+
+		newClient.SecretHash = auth.HashToken(secret)
+		credentials.ClientSecret = secret
+	}
+
+	err := s.clientRepository.Create(&newClient)
+	if err != nil {
+		return credentials, err
+	}
+
+	credentials.Client = newClient
+	return credentials, nil
+}
+
+// CreateMachineClient registers a confidential client for service to
+// service calls, its secret is only returned here. The creator must hold
+// every permission the scopes of the client grant.
+func (s *oauthService) CreateMachineClient(claims *auth.Claims, client models.CreateMachineClient) (models.OAuthClientCredentials, error) {
+	var credentials models.OAuthClientCredentials
+
+	for _, scope := range client.Scopes {
+		for _, permission := range models.ScopePermissions[scope] {
+			allowed, err := s.roleService.Can(claims, permission)
+			if err != nil {
+				return credentials, err
+			}
+
+			if !allowed {
+				return credentials, ErrScopeNotHeld
+			}
+		}
+	}
+
+	secret, err := auth.RandomToken()
+	if err != nil {
+		return credentials, err
+	}
+
+	newClient := models.OAuthClient{
+		Name:       client.Name,
+		SecretHash: auth.HashToken(secret),
+		Machine:    true,
+		Scopes:     client.Scopes,
+		OwnerID:    claims.UserID,
+	}
+
+	err = s.clientRepository.Create(&newClient)
+	if err != nil {
+		return credentials, err
+	}
+
+	credentials.Client = newClient
+	credentials.ClientSecret = secret
+	return credentials, nil
+}
+
+func (s *oauthService) FindAllClients(query models.PaginationQuery) ([]models.OAuthClient, error) {
+	return s.clientRepository.FindAll(query)
+}
+
+// DeleteClient removes the client and revokes the tokens issued to it, which
+// would otherwise stay valid until they expire.
+func (s *oauthService) DeleteClient(id string) error {
+	client, err := s.clientRepository.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.clientRepository.Delete(&client)
+	if err != nil {
+		return err
+	}
+
+	return s.tokenService.RevokeClient(client.ID)
+}
+
+// Authorize validates an authorization request and returns what the user
+// has to consent to.
+func (s *oauthService) Authorize(req models.AuthorizeRequest) (models.ConsentScreen, error) {
+	var screen models.ConsentScreen
+
+	client, scopes, err := s.validateAuthorizeRequest(req)
+	if err != nil {
+		return screen, err
+	}
+
+	screen.ClientID = client.ID
+	screen.ClientName = client.Name
+	screen.RedirectURI = req.RedirectURI
+	screen.State = req.State
+	for _, scope := range scopes {
+		screen.Scopes = append(screen.Scopes, models.ScopeDescription{Name: scope, Description: scopeDescriptions[scope]})
+	}
+
+	return screen, nil
+}
+
+// Decide records the decision of the user and returns where to send the
+// browser, with an authorization code if the request was approved.
+func (s *oauthService) Decide(userId string, decision models.AuthorizeDecision) (models.AuthorizeResponse, error) {
+	var res models.AuthorizeResponse
+
+	_, scopes, err := s.validateAuthorizeRequest(decision.AuthorizeRequest)
+	if err != nil {
+		return res, err
+	}
+
+	params := url.Values{}
+	if decision.State != "" {
+		params.Set("state", decision.State)
+	}
+	params.Set("iss", s.cfg.PublicUrl)
+
+	if !decision.Approve {
+		params.Set("error", "access_denied")
+		res.RedirectURI = withQuery(decision.RedirectURI, params)
+		return res, nil
+	}
+
+	code, err := auth.RandomToken()
+	if err != nil {
+		return res, err
+	}
+
+	err = s.codeRepository.Create(&models.OAuthAuthorizationCode{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      decision.ClientID,
+		UserID:        userId,
+		RedirectURI:   decision.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         decision.Nonce,
+		CodeChallenge: decision.CodeChallenge,
+		FamilyID:      uuid.New().String(),
+		ExpiresAt:     time.Now().Add(s.cfg.OAuthCodeLifespan),
+	})
+	if err != nil {
+		return res, err
+	}
+
+	params.Set("code", code)
+	res.RedirectURI = withQuery(decision.RedirectURI, params)
+
+	return res, nil
+}
+
+func (s *oauthService) Token(req models.OAuthTokenRequest) (models.OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case "authorization_code":
+		return s.authorizationCodeGrant(req)
+	case "refresh_token":
+		return s.refreshTokenGrant(req)
+	case "client_credentials":
+		return s.clientCredentialsGrant(req)
+	}
+
+	return models.OAuthTokenResponse{}, oauthError("unsupported_grant_type", "")
+}
+
+func (s *oauthService) UserInfo(claims *auth.Claims) (models.UserInfo, error) {
+	var info models.UserInfo
+
+	if claims.ClientID != "" && !hasScope(claims.Scope, models.ScopeOpenID) {
+		return info, oauthError("insufficient_scope", "openid scope is required")
+	}
+
+	user, err := s.userRepository.FindById(claims.UserID)
+	if err != nil {
+		return info, oauthError("invalid_token", "")
+	}
+
+	info.Subject = user.ID
+	if claims.ClientID == "" || hasScope(claims.Scope, models.ScopeProfile) {
+		info.Name = user.Name
+	}
+	if claims.ClientID == "" || hasScope(claims.Scope, models.ScopeEmail) {
+		info.Email = user.Email
+		info.EmailVerified = user.VerifiedAt != nil
+	}
+
+	return info, nil
+}
+
+// Introspect reports whether a token is active and what it grants. Only
+// confidential clients, usually resource servers, may ask.
+func (s *oauthService) Introspect(req models.IntrospectionRequest) (models.IntrospectionResponse, error) {
+	var res models.IntrospectionResponse
+
+	client, err := s.authenticateClient(models.OAuthTokenRequest{ClientID: req.ClientID, ClientSecret: req.ClientSecret})
+	if err != nil {
+		return res, err
+	}
+
+	if client.Public {
+		return res, oauthError("invalid_client", "public clients can not introspect tokens")
+	}
+
+	claims, err := s.tokenService.Introspect(req.Token, req.TokenTypeHint)
+	if err != nil {
+		return res, nil
+	}
+
+	res = models.IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: claims.Type,
+		Exp:       claims.ExpiresAt.Unix(),
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		JwtID:     claims.ID,
+		Roles:     claims.Roles,
+		SessionID: claims.SessionID,
+	}
+
+	if claims.IssuedAt != nil {
+		res.Iat = claims.IssuedAt.Unix()
+	}
+
+	if claims.NotBefore != nil {
+		res.Nbf = claims.NotBefore.Unix()
+	}
+
+	if claims.Actor != nil {
+		res.ActorID = claims.Actor.Subject
+	}
+
+	return res, nil
+}
+
+func (s *oauthService) Configuration() models.OpenIDConfiguration {
+	scopes := make([]string, 0, len(scopeDescriptions))
+	for scope := range scopeDescriptions {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	return models.OpenIDConfiguration{
+		Issuer:                            s.cfg.PublicUrl,
+		AuthorizationEndpoint:             s.cfg.PublicUrl + "/oauth/authorize",
+		TokenEndpoint:                     s.cfg.PublicUrl + "/oauth/token",
+		UserinfoEndpoint:                  s.cfg.PublicUrl + "/oauth/userinfo",
+		IntrospectionEndpoint:             s.cfg.PublicUrl + "/oauth/introspect",
+		JwksURI:                           s.cfg.PublicUrl + "/.well-known/jwks.json",
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.keyService.KeySet().SigningKey().Method.Alg()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	}
+}
+
+func (s *oauthService) DeleteExpiredCodes() error {
+	return s.codeRepository.DeleteExpired()
+}
+
+func (s *oauthService) authorizationCodeGrant(req models.OAuthTokenRequest) (models.OAuthTokenResponse, error) {
+	var res models.OAuthTokenResponse
+
+	client, err := s.authenticateClient(req)
+	if err != nil {
+		return res, err
+	}
+
+	code, err := s.codeRepository.FindByCodeHash(auth.HashToken(req.Code))
+	if err != nil || code.ClientID != client.ID || time.Now().After(code.ExpiresAt) {
+		return res, oauthError("invalid_grant", "invalid authorization code")
+	}
+
+	ok, err := s.codeRepository.MarkUsed(code.ID)
+	if err != nil {
+		return res, err
+	}
+
+	if !ok {
+		log.Warn().Str("client_id", client.ID).Str("user_id", code.UserID).Msg("Authorization code replayed, revoking issued tokens")
+
+		err = s.tokenService.RevokeFamily(code.FamilyID)
+		if err != nil {
+			return res, err
+		}
+
+		return res, oauthError("invalid_grant", "authorization code already used")
+	}
+
+	if req.RedirectURI != code.RedirectURI {
+		return res, oauthError("invalid_grant", "redirect_uri mismatch")
+	}
+
+	if req.CodeVerifier == "" || subtle.ConstantTimeCompare([]byte(auth.CodeChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+		return res, oauthError("invalid_grant", "invalid code_verifier")
+	}
+
+	user, err := s.userRepository.FindById(code.UserID)
+	if err != nil {
+		return res, oauthError("invalid_grant", "user not found")
+	}
+
+	token, err := s.tokenService.IssueForClient(user, client.ID, code.Scope, code.FamilyID)
+	if err != nil {
+		return res, err
+	}
+
+	res = s.tokenResponse(token, code.Scope)
+
+	if hasScope(code.Scope, models.ScopeOpenID) {
+		res.IDToken, err = s.idToken(user, client.ID, code.Scope, code.Nonce)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+func (s *oauthService) refreshTokenGrant(req models.OAuthTokenRequest) (models.OAuthTokenResponse, error) {
+	var res models.OAuthTokenResponse
+
+	client, err := s.authenticateClient(req)
+	if err != nil {
+		return res, err
+	}
+
+	token, scope, err := s.tokenService.RefreshForClient(req.RefreshToken, client.ID)
+	if err != nil {
+		return res, oauthError("invalid_grant", err.Error())
+	}
+
+	return s.tokenResponse(token, scope), nil
+}
+
+// clientCredentialsGrant issues a token to a machine client for the
+// requested scopes, or all of its scopes when none are requested.
+func (s *oauthService) clientCredentialsGrant(req models.OAuthTokenRequest) (models.OAuthTokenResponse, error) {
+	var res models.OAuthTokenResponse
+
+	client, err := s.authenticateClient(req)
+	if err != nil {
+		return res, err
+	}
+
+	if !client.Machine {
+		return res, oauthError("unauthorized_client", "client is not allowed to use the client_credentials grant")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !client.HasScope(scope) {
+			return res, oauthError("invalid_scope", "scope "+scope+" is not allowed")
+		}
+	}
+
+	scope := strings.Join(scopes, " ")
+	accessToken, err := s.tokenService.IssueForMachine(client.ID, scope)
+	if err != nil {
+		return res, err
+	}
+
+	return models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.cfg.AccessTokenLifespan.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// authenticateClient accepts client_secret_basic and client_secret_post for
+// confidential clients. Public clients only identify themselves.
+func (s *oauthService) authenticateClient(req models.OAuthTokenRequest) (models.OAuthClient, error) {
+	client, err := s.clientRepository.FindById(req.ClientID)
+	if err != nil {
+		return client, oauthError("invalid_client", "")
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(auth.HashToken(req.ClientSecret)), []byte(client.SecretHash)) != 1 {
+		return client, oauthError("invalid_client", "")
+	}
+
+	return client, nil
+}
+
+func (s *oauthService) validateAuthorizeRequest(req models.AuthorizeRequest) (models.OAuthClient, []string, error) {
+	client, err := s.clientRepository.FindById(req.ClientID)
+	if err != nil {
+		return client, nil, oauthError("invalid_client", "")
+	}
+
+	if client.Machine {
+		return client, nil, oauthError("unauthorized_client", "machine clients can not act for users")
+	}
+
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return client, nil, oauthError("invalid_request", "redirect_uri is not registered")
+	}
+
+	if req.ResponseType != "code" {
+		return client, nil, oauthError("unsupported_response_type", "")
+	}
+
+	if req.CodeChallengeMethod != "S256" {
+		return client, nil, oauthError("invalid_request", "code_challenge_method must be S256")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	for _, scope := range scopes {
+		if !client.HasScope(scope) {
+			return client, nil, oauthError("invalid_scope", "scope "+scope+" is not allowed")
+		}
+	}
+
+	return client, scopes, nil
+}
+
+func (s *oauthService) tokenResponse(token models.Token, scope string) models.OAuthTokenResponse {
+	res := models.OAuthTokenResponse{
+		AccessToken: token.Token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.cfg.AccessTokenLifespan.Seconds()),
+		Scope:       scope,
+	}
+
+	if hasScope(scope, models.ScopeOfflineAccess) {
+		res.RefreshToken = token.RefreshToken
+	}
+
+	return res
+}
+
+func (s *oauthService) idToken(user models.User, clientId, scope, nonce string) (string, error) {
+	now := time.Now()
+
+	claims := &oidc.IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.PublicUrl,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{clientId},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenLifespan)),
+		},
+		Nonce:           nonce,
+		AuthorizedParty: clientId,
+	}
+
+	if hasScope(scope, models.ScopeEmail) {
+		claims.Email = user.Email
+		claims.EmailVerified = user.VerifiedAt != nil
+	}
+
+	if hasScope(scope, models.ScopeProfile) {
+		claims.Name = user.Name
+	}
+
+	return s.keyService.KeySet().Sign(claims)
+}
+
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withQuery(uri string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+
+	return uri + sep + params.Encode()
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fixedOAuthClientRepository serves a fixed set of clients.
+type fixedOAuthClientRepository struct {
+	repositories.OAuthClientRepository
+	clients []models.OAuthClient
+}
+
+func (r *fixedOAuthClientRepository) FindById(id string) (models.OAuthClient, error) {
+	for _, c := range r.clients {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+
+	return models.OAuthClient{}, errors.New("record not found")
+}
+
+// memoryOAuthCodeRepository keeps authorization codes in memory.
+type memoryOAuthCodeRepository struct {
+	repositories.OAuthCodeRepository
+	codes []*models.OAuthAuthorizationCode
+}
+
+func (r *memoryOAuthCodeRepository) FindByCodeHash(hash string) (models.OAuthAuthorizationCode, error) {
+	for _, c := range r.codes {
+		if c.CodeHash == hash {
+			return *c, nil
+		}
+	}
+
+	return models.OAuthAuthorizationCode{}, errors.New("record not found")
+}
+
+func (r *memoryOAuthCodeRepository) MarkUsed(id string) (bool, error) {
+	for _, c := range r.codes {
+		if c.ID == id && c.UsedAt == nil {
+			now := time.Now()
+			c.UsedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// rotatingTokenService hands out numbered refresh tokens that can each be
+// used once by the client they were issued to.
+type rotatingTokenService struct {
+	TokenService
+	issued  int
+	active  map[string]string
+	scopes  map[string]string
+	revoked []string
+}
+
+func (s *rotatingTokenService) next(clientId, scope string) models.Token {
+	s.issued++
+	token := models.Token{Token: "access", RefreshToken: "refresh-" + strconv.Itoa(s.issued)}
+	s.active[token.RefreshToken] = clientId
+	s.scopes[token.RefreshToken] = scope
+	return token
+}
+
+func (s *rotatingTokenService) IssueForClient(user models.User, clientId, scope, familyId string) (models.Token, error) {
+	return s.next(clientId, scope), nil
+}
+
+func (s *rotatingTokenService) RefreshForClient(refreshToken, clientId string) (models.Token, string, error) {
+	if s.active[refreshToken] != clientId {
+		return models.Token{}, "", ErrInvalidRefreshToken
+	}
+
+	scope := s.scopes[refreshToken]
+	delete(s.active, refreshToken)
+	return s.next(clientId, scope), scope, nil
+}
+
+func (s *rotatingTokenService) RevokeFamily(familyId string) error {
+	s.revoked = append(s.revoked, familyId)
+	return nil
+}
+
+const (
+	testRedirectURI  = "https://app.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mJ0kq1v2w3x4y5z6A7B8C9D0E1F2G3"
+)
+
+func newTestOAuthService() (*oauthService, *rotatingTokenService) {
+	tokens := &rotatingTokenService{active: map[string]string{}, scopes: map[string]string{}}
+
+	return &oauthService{
+		clientRepository: &fixedOAuthClientRepository{clients: []models.OAuthClient{
+			{Base: models.Base{ID: "public"}, Public: true, RedirectURIs: []string{testRedirectURI}},
+			{Base: models.Base{ID: "confidential"}, SecretHash: auth.HashToken("secret"), RedirectURIs: []string{testRedirectURI}},
+		}},
+		codeRepository: &memoryOAuthCodeRepository{codes: []*models.OAuthAuthorizationCode{
+			{
+				Base:          models.Base{ID: "c1"},
+				CodeHash:      auth.HashToken("code"),
+				ClientID:      "public",
+				UserID:        "u1",
+				RedirectURI:   testRedirectURI,
+				Scope:         models.ScopeProfile,
+				CodeChallenge: auth.CodeChallenge(testCodeVerifier),
+				FamilyID:      "f1",
+				ExpiresAt:     time.Now().Add(time.Minute),
+			},
+			{
+				Base:          models.Base{ID: "c2"},
+				CodeHash:      auth.HashToken("confidential-code"),
+				ClientID:      "confidential",
+				UserID:        "u1",
+				RedirectURI:   testRedirectURI,
+				Scope:         models.ScopeProfile + " " + models.ScopeOfflineAccess,
+				CodeChallenge: auth.CodeChallenge(testCodeVerifier),
+				FamilyID:      "f2",
+				ExpiresAt:     time.Now().Add(time.Minute),
+			},
+		}},
+		userRepository: &fixedUserRepository{users: []models.User{{Base: models.Base{ID: "u1"}}}},
+		tokenService:   tokens,
+		cfg:            config.Config{AccessTokenLifespan: time.Minute},
+	}, tokens
+}
+
+func oauthErrorCode(err error) string {
+	var oerr *OAuthError
+	if errors.As(err, &oerr) {
+		return oerr.Code
+	}
+
+	return ""
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	valid := models.OAuthTokenRequest{
+		GrantType:    "authorization_code",
+		Code:         "code",
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientID:     "public",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *models.OAuthTokenRequest)
+		want   string
+	}{
+		{"valid", func(r *models.OAuthTokenRequest) {}, ""},
+		{"pkce mismatch", func(r *models.OAuthTokenRequest) { r.CodeVerifier = "wrong" }, "invalid_grant"},
+		{"pkce missing", func(r *models.OAuthTokenRequest) { r.CodeVerifier = "" }, "invalid_grant"},
+		{"redirect_uri mismatch", func(r *models.OAuthTokenRequest) { r.RedirectURI = "https://evil.example.com/callback" }, "invalid_grant"},
+		{"unknown code", func(r *models.OAuthTokenRequest) { r.Code = "other" }, "invalid_grant"},
+		{"other client", func(r *models.OAuthTokenRequest) { r.ClientID = "confidential"; r.ClientSecret = "secret" }, "invalid_grant"},
+		{"unknown client", func(r *models.OAuthTokenRequest) { r.ClientID = "unknown" }, "invalid_client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestOAuthService()
+
+			req := valid
+			tt.modify(&req)
+
+			res, err := s.Token(req)
+			if got := oauthErrorCode(err); got != tt.want {
+				t.Fatalf("Token() error = %v, want %q", err, tt.want)
+			}
+
+			if tt.want == "" && (res.AccessToken == "" || res.Scope != models.ScopeProfile) {
+				t.Errorf("Token() = %+v, want an access token for %q", res, models.ScopeProfile)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCodeReplayRevokesFamily(t *testing.T) {
+	s, tokens := newTestOAuthService()
+
+	req := models.OAuthTokenRequest{
+		GrantType:    "authorization_code",
+		Code:         "code",
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientID:     "public",
+	}
+
+	if _, err := s.Token(req); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	if _, err := s.Token(req); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("Token() replay error = %v, want invalid_grant", err)
+	}
+
+	if len(tokens.revoked) != 1 || tokens.revoked[0] != "f1" {
+		t.Errorf("RevokeFamily() calls = %v, want [f1]", tokens.revoked)
+	}
+}
+
+func TestConfidentialClientAuthentication(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"valid secret", "secret", ""},
+		{"wrong secret", "wrong", "invalid_client"},
+		{"missing secret", "", "invalid_client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestOAuthService()
+
+			_, err := s.Token(models.OAuthTokenRequest{
+				GrantType:    "authorization_code",
+				Code:         "confidential-code",
+				RedirectURI:  testRedirectURI,
+				CodeVerifier: testCodeVerifier,
+				ClientID:     "confidential",
+				ClientSecret: tt.secret,
+			})
+			if got := oauthErrorCode(err); got != tt.want {
+				t.Errorf("Token() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenGrantRotates(t *testing.T) {
+	s, _ := newTestOAuthService()
+
+	res, err := s.Token(models.OAuthTokenRequest{
+		GrantType:    "authorization_code",
+		Code:         "confidential-code",
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientID:     "confidential",
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	refresh := models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: res.RefreshToken, ClientID: "confidential", ClientSecret: "secret"}
+
+	rotated, err := s.Token(refresh)
+	if err != nil {
+		t.Fatalf("Token() refresh error = %v", err)
+	}
+
+	if rotated.RefreshToken == "" || rotated.RefreshToken == res.RefreshToken {
+		t.Errorf("Token() refresh token = %q, want a new one", rotated.RefreshToken)
+	}
+
+	if rotated.Scope != res.Scope {
+		t.Errorf("Token() refresh scope = %q, want %q", rotated.Scope, res.Scope)
+	}
+
+	if _, err := s.Token(refresh); oauthErrorCode(err) != "invalid_grant" {
+		t.Errorf("Token() reused refresh token error = %v, want invalid_grant", err)
+	}
+
+	other := models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: rotated.RefreshToken, ClientID: "public"}
+	if _, err := s.Token(other); oauthErrorCode(err) != "invalid_grant" {
+		t.Errorf("Token() refresh by other client error = %v, want invalid_grant", err)
+	}
+}
+
+// introspectionTokenService knows a single active token.
+type introspectionTokenService struct {
+	TokenService
+}
+
+func (s *introspectionTokenService) Introspect(token, hint string) (*auth.Claims, error) {
+	if token != "active" {
+		return nil, ErrTokenRevoked
+	}
+
+	claims := &auth.Claims{UserID: "u1", ClientID: "public", Scope: models.ScopeProfile, Type: auth.TokenTypeAccess}
+	claims.Subject = "u1"
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	return claims, nil
+}
+
+func TestIntrospect(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        models.IntrospectionRequest
+		want       string
+		wantActive bool
+	}{
+		{"active token", models.IntrospectionRequest{Token: "active", ClientID: "confidential", ClientSecret: "secret"}, "", true},
+		{"inactive token", models.IntrospectionRequest{Token: "revoked", ClientID: "confidential", ClientSecret: "secret"}, "", false},
+		{"wrong secret", models.IntrospectionRequest{Token: "active", ClientID: "confidential", ClientSecret: "wrong"}, "invalid_client", false},
+		{"public client", models.IntrospectionRequest{Token: "active", ClientID: "public"}, "invalid_client", false},
+		{"unknown client", models.IntrospectionRequest{Token: "active", ClientID: "unknown"}, "invalid_client", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestOAuthService()
+			s.tokenService = &introspectionTokenService{}
+
+			res, err := s.Introspect(tt.req)
+			if got := oauthErrorCode(err); got != tt.want {
+				t.Fatalf("Introspect() error = %v, want %q", err, tt.want)
+			}
+
+			if res.Active != tt.wantActive {
+				t.Errorf("Introspect() active = %v, want %v", res.Active, tt.wantActive)
+			}
+
+			if !res.Active && (res.Subject != "" || res.Scope != "") {
+				t.Errorf("Introspect() inactive response = %+v, want nothing but active", res)
+			}
+		})
+	}
+}
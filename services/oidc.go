@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/oidc"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown provider")
+	ErrInvalidState     = errors.New("invalid or expired state")
+	ErrEmailNotVerified = errors.New("email is not verified by the provider")
+)
+
+type OidcService interface {
+	Login(provider string) (string, string, error)
+	Callback(provider, state, code string, client models.ClientInfo) (models.Token, error)
+	DeleteExpired() error
+}
+
+func NewOidcService(repository repositories.OidcStateRepository, userRepository repositories.UserRepository, tokenService TokenService, mfaService MfaService, cfg config.Config) OidcService {
+	log.Info().Msg("Creating new oidc service")
+
+	providers := make(map[string]*oidc.Provider)
+	for _, p := range cfg.OidcProviders {
+		providers[p.Name] = oidc.NewProvider(p, nil)
+	}
+
+	return &oidcService{
+		repository:     repository,
+		userRepository: userRepository,
+		tokenService:   tokenService,
+		mfaService:     mfaService,
+		providers:      providers,
+		cfg:            cfg,
+	}
+}
+
+type oidcService struct {
+	repository     repositories.OidcStateRepository
+	userRepository repositories.UserRepository
+	tokenService   TokenService
+	mfaService     MfaService
+	providers      map[string]*oidc.Provider
+	cfg            config.Config
+}
+
+// Login starts the authorization code flow and returns the provider URL to
+// redirect to together with the state the callback must present.
+func (s *oidcService) Login(provider string) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := auth.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := auth.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	codeVerifier, err := auth.RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := p.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.repository.Create(&models.OidcState{
+		Provider:     provider,
+		StateHash:    auth.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(s.cfg.OidcStateLifespan),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// Callback finishes the flow, the user is found or created by the verified
+// email of the ID token. Users with a second factor still have to present it.
+func (s *oidcService) Callback(provider, state, code string, client models.ClientInfo) (models.Token, error) {
+	var token models.Token
+
+	p, ok := s.providers[provider]
+	if !ok {
+		return token, ErrUnknownProvider
+	}
+
+	pending, err := s.repository.FindByStateHash(auth.HashToken(state))
+	if err != nil {
+		return token, ErrInvalidState
+	}
+
+	err = s.repository.Delete(&pending)
+	if err != nil {
+		return token, err
+	}
+
+	if pending.Provider != provider || time.Now().After(pending.ExpiresAt) {
+		return token, ErrInvalidState
+	}
+
+	res, err := p.Exchange(code, pending.CodeVerifier)
+	if err != nil {
+		return token, err
+	}
+
+	claims, err := p.VerifyIDToken(res.IDToken, pending.Nonce)
+	if err != nil {
+		return token, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return token, ErrEmailNotVerified
+	}
+
+	user, err := s.findOrCreate(claims)
+	if err != nil {
+		return token, err
+	}
+
+	err = s.mfaService.Challenge(user, auth.AuthMethodFederated)
+	if err != nil {
+		return token, err
+	}
+
+	return s.tokenService.Issue(user, client, auth.AuthMethodFederated)
+}
+
+func (s *oidcService) DeleteExpired() error {
+	return s.repository.DeleteExpired()
+}
+
+func (s *oidcService) findOrCreate(claims *oidc.IDTokenClaims) (models.User, error) {
+	now := time.Now()
+
+	user, err := s.userRepository.FindByEmail(claims.Email)
+	if err == nil {
+		if user.VerifiedAt == nil {
+			user.VerifiedAt = &now
+			err = s.userRepository.Update(&user)
+		}
+
+		return user, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = strings.Split(claims.Email, "@")[0]
+	}
+
+	user = models.User{
+		Email: claims.Email,
+		Name:  name,
+		Roles: []string{models.UserRole},
+
+		VerifiedAt: &now,
+	}
+
+	err = s.userRepository.Create(&user)
+	return user, err
+}
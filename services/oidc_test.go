@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/oidc"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memoryOidcStateRepository keeps pending logins in memory.
+type memoryOidcStateRepository struct {
+	repositories.OidcStateRepository
+	states []models.OidcState
+}
+
+func (r *memoryOidcStateRepository) Create(t *models.OidcState) error {
+	r.states = append(r.states, *t)
+	return nil
+}
+
+func (r *memoryOidcStateRepository) FindByStateHash(hash string) (models.OidcState, error) {
+	for _, s := range r.states {
+		if s.StateHash == hash {
+			return s, nil
+		}
+	}
+
+	return models.OidcState{}, errors.New("record not found")
+}
+
+func (r *memoryOidcStateRepository) Delete(t *models.OidcState) error {
+	for i, s := range r.states {
+		if s.StateHash == t.StateHash {
+			r.states = append(r.states[:i], r.states[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// newDiscoveryServer serves provider metadata only, the token endpoint
+// rejects every code.
+func newDiscoveryServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(oidc.Discovery{
+			Issuer:                server.URL,
+			AuthorizationEndpoint: server.URL + "/authorize",
+			TokenEndpoint:         server.URL + "/token",
+			JwksURI:               server.URL + "/jwks",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestOidcService(t *testing.T) (*oidcService, *memoryOidcStateRepository) {
+	server := newDiscoveryServer(t)
+	states := &memoryOidcStateRepository{}
+
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range []string{"google", "github"} {
+		providers[name] = oidc.NewProvider(oidc.Config{Name: name, Issuer: server.URL, ClientID: name}, server.Client())
+	}
+
+	return &oidcService{
+		repository: states,
+		providers:  providers,
+		cfg:        config.Config{OidcStateLifespan: time.Minute},
+	}, states
+}
+
+func TestOidcLogin(t *testing.T) {
+	s, states := newTestOidcService(t)
+
+	authURL, state, err := s.Login("google")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	if u.Query().Get("state") != state {
+		t.Errorf("Login() url state = %q, want %q", u.Query().Get("state"), state)
+	}
+
+	if len(states.states) != 1 || states.states[0].StateHash != auth.HashToken(state) || states.states[0].Provider != "google" {
+		t.Errorf("Login() stored %+v, want the hashed state for google", states.states)
+	}
+
+	if auth.CodeChallenge(states.states[0].CodeVerifier) != u.Query().Get("code_challenge") {
+		t.Errorf("Login() code challenge does not match the stored verifier")
+	}
+
+	if _, _, err := s.Login("unknown"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Login() unknown provider error = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestOidcCallbackState(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		state    func(s *oidcService, states *memoryOidcStateRepository) string
+		want     error
+	}{
+		{"unknown provider", "unknown", func(s *oidcService, states *memoryOidcStateRepository) string {
+			_, state, _ := s.Login("google")
+			return state
+		}, ErrUnknownProvider},
+		{"unknown state", "google", func(s *oidcService, states *memoryOidcStateRepository) string {
+			return "forged"
+		}, ErrInvalidState},
+		{"other provider", "github", func(s *oidcService, states *memoryOidcStateRepository) string {
+			_, state, _ := s.Login("google")
+			return state
+		}, ErrInvalidState},
+		{"expired", "google", func(s *oidcService, states *memoryOidcStateRepository) string {
+			_, state, _ := s.Login("google")
+			states.states[0].ExpiresAt = time.Now().Add(-time.Second)
+			return state
+		}, ErrInvalidState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, states := newTestOidcService(t)
+
+			_, err := s.Callback(tt.provider, tt.state(s, states), "code", models.ClientInfo{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Callback() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOidcCallbackConsumesState(t *testing.T) {
+	s, states := newTestOidcService(t)
+
+	_, state, err := s.Login("google")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	// The provider rejects the code, the state is used up all the same.
+	if _, err := s.Callback("google", state, "code", models.ClientInfo{}); err == nil || errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Callback() error = %v, want the exchange to fail", err)
+	}
+
+	if len(states.states) != 0 {
+		t.Errorf("Callback() left %d states, want none", len(states.states))
+	}
+
+	if _, err := s.Callback("google", state, "code", models.ClientInfo{}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Callback() replay error = %v, want ErrInvalidState", err)
+	}
+}
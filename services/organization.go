@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidSlug      = errors.New("slugs are 2 to 50 lowercase letters, digits or -")
+	ErrSlugTaken        = errors.New("organization slug is already taken")
+	ErrNotMember        = errors.New("not a member of the organization")
+	ErrUnknownMember    = errors.New("unknown member")
+	ErrOrgRoleForbidden = errors.New("only owners can grant, change or remove the owner role")
+	ErrOrgAdminRequired = errors.New("only owners and admins can remove other members")
+	ErrLastOwner        = errors.New("an organization needs at least one owner")
+)
+
+var orgSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+type OrganizationService interface {
+	Create(userId string, organization models.CreateOrganization) (models.Organization, error)
+	FindAll(userId string) ([]models.Organization, error)
+	FindById(id string) (models.Organization, error)
+	FindMembership(organizationId, userId string) (models.Membership, error)
+	FindMembers(organizationId string) ([]models.Membership, error)
+	UpdateMember(organizationId string, actor models.Membership, userId string, update models.UpdateMembership) (models.Membership, error)
+	RemoveMember(organizationId string, actor models.Membership, userId string) error
+}
+
+func NewOrganizationService(repository repositories.OrganizationRepository, membershipRepository repositories.MembershipRepository) OrganizationService {
+	log.Info().Msg("Creating new organization service")
+
+	return &organizationService{
+		repository:           repository,
+		membershipRepository: membershipRepository,
+	}
+}
+
+type organizationService struct {
+	repository           repositories.OrganizationRepository
+	membershipRepository repositories.MembershipRepository
+}
+
+// Create makes the user the first owner of the new organization.
+func (s *organizationService) Create(userId string, organization models.CreateOrganization) (models.Organization, error) {
+	if !orgSlug.MatchString(organization.Slug) {
+		return models.Organization{}, ErrInvalidSlug
+	}
+
+	_, err := s.repository.FindBySlug(organization.Slug)
+	if err == nil {
+		return models.Organization{}, ErrSlugTaken
+	}
+
+	newOrganization := models.Organization{
+		Name: organization.Name,
+		Slug: organization.Slug,
+	}
+
+	err = s.repository.CreateWithOwner(&newOrganization, &models.Membership{
+		UserID: userId,
+		Role:   models.OrgRoleOwner,
+	})
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return models.Organization{}, ErrSlugTaken
+	}
+	if err != nil {
+		return newOrganization, err
+	}
+
+	newOrganization.Role = models.OrgRoleOwner
+	return newOrganization, nil
+}
+
+func (s *organizationService) FindAll(userId string) ([]models.Organization, error) {
+	return s.repository.FindAllByUserId(userId)
+}
+
+func (s *organizationService) FindById(id string) (models.Organization, error) {
+	return s.repository.FindById(id)
+}
+
+func (s *organizationService) FindMembership(organizationId, userId string) (models.Membership, error) {
+	membership, err := s.membershipRepository.FindByUserId(organizationId, userId)
+	if err != nil {
+		return membership, ErrNotMember
+	}
+
+	return membership, nil
+}
+
+func (s *organizationService) FindMembers(organizationId string) ([]models.Membership, error) {
+	return s.membershipRepository.FindAll(organizationId)
+}
+
+// UpdateMember changes the role of a member. Admins manage members and
+// admins, only owners can touch the owner role.
+func (s *organizationService) UpdateMember(organizationId string, actor models.Membership, userId string, update models.UpdateMembership) (models.Membership, error) {
+	membership, err := s.membershipRepository.FindByUserId(organizationId, userId)
+	if err != nil {
+		return membership, ErrUnknownMember
+	}
+
+	if membership.Role == update.Role {
+		return membership, nil
+	}
+
+	involvesOwner := membership.Role == models.OrgRoleOwner || update.Role == models.OrgRoleOwner
+	if involvesOwner && actor.Role != models.OrgRoleOwner {
+		return membership, ErrOrgRoleForbidden
+	}
+
+	wasOwner := membership.Role == models.OrgRoleOwner
+	membership.Role = update.Role
+
+	if wasOwner {
+		ok, err := s.membershipRepository.UpdateOwner(organizationId, &membership)
+		if err == nil && !ok {
+			err = ErrLastOwner
+		}
+
+		return membership, err
+	}
+
+	err = s.membershipRepository.Update(organizationId, &membership)
+	return membership, err
+}
+
+// RemoveMember removes a member from the organization. Members may always
+// leave, removing others takes an admin, removing an owner an owner.
+func (s *organizationService) RemoveMember(organizationId string, actor models.Membership, userId string) error {
+	membership, err := s.membershipRepository.FindByUserId(organizationId, userId)
+	if err != nil {
+		return ErrUnknownMember
+	}
+
+	if actor.UserID != userId {
+		if actor.Role == models.OrgRoleMember {
+			return ErrOrgAdminRequired
+		}
+
+		if membership.Role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+			return ErrOrgRoleForbidden
+		}
+	}
+
+	if membership.Role == models.OrgRoleOwner {
+		ok, err := s.membershipRepository.DeleteOwner(organizationId, &membership)
+		if err == nil && !ok {
+			err = ErrLastOwner
+		}
+
+		return err
+	}
+
+	return s.membershipRepository.Delete(organizationId, &membership)
+}
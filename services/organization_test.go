@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memoryMembershipRepository keeps the memberships of every organization.
+type memoryMembershipRepository struct {
+	repositories.MembershipRepository
+	memberships []models.Membership
+}
+
+func (r *memoryMembershipRepository) FindByUserId(organizationId, userId string) (models.Membership, error) {
+	for _, m := range r.memberships {
+		if m.OrganizationID == organizationId && m.UserID == userId {
+			return m, nil
+		}
+	}
+
+	return models.Membership{}, errors.New("record not found")
+}
+
+func (r *memoryMembershipRepository) Update(organizationId string, t *models.Membership) error {
+	for i, m := range r.memberships {
+		if m.OrganizationID == organizationId && m.ID == t.ID {
+			r.memberships[i] = *t
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryMembershipRepository) Delete(organizationId string, t *models.Membership) error {
+	for i, m := range r.memberships {
+		if m.OrganizationID == organizationId && m.ID == t.ID {
+			r.memberships = append(r.memberships[:i], r.memberships[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryMembershipRepository) otherOwners(organizationId string, t *models.Membership) bool {
+	for _, m := range r.memberships {
+		if m.OrganizationID == organizationId && m.ID != t.ID && m.Role == models.OrgRoleOwner {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *memoryMembershipRepository) UpdateOwner(organizationId string, t *models.Membership) (bool, error) {
+	if !r.otherOwners(organizationId, t) {
+		return false, nil
+	}
+
+	return true, r.Update(organizationId, t)
+}
+
+func (r *memoryMembershipRepository) DeleteOwner(organizationId string, t *models.Membership) (bool, error) {
+	if !r.otherOwners(organizationId, t) {
+		return false, nil
+	}
+
+	return true, r.Delete(organizationId, t)
+}
+
+// fixedOrganizationRepository serves a fixed set of organizations.
+type fixedOrganizationRepository struct {
+	repositories.OrganizationRepository
+	organizations []models.Organization
+}
+
+func (r *fixedOrganizationRepository) FindBySlug(slug string) (models.Organization, error) {
+	for _, o := range r.organizations {
+		if o.Slug == slug {
+			return o, nil
+		}
+	}
+
+	return models.Organization{}, errors.New("record not found")
+}
+
+func (r *fixedOrganizationRepository) CreateWithOwner(t *models.Organization, owner *models.Membership) error {
+	r.organizations = append(r.organizations, *t)
+	return nil
+}
+
+func membership(id, organizationId, userId, role string) models.Membership {
+	return models.Membership{Base: models.Base{ID: id}, OrganizationID: organizationId, UserID: userId, Role: role}
+}
+
+func newTestOrganizationService(memberships ...models.Membership) *organizationService {
+	return &organizationService{
+		repository:           &fixedOrganizationRepository{organizations: []models.Organization{{Base: models.Base{ID: "o1"}, Slug: "acme"}}},
+		membershipRepository: &memoryMembershipRepository{memberships: memberships},
+	}
+}
+
+func TestOrganizationCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		slug string
+		want error
+	}{
+		{"valid", "globex", nil},
+		{"taken", "acme", ErrSlugTaken},
+		{"upper case", "Globex", ErrInvalidSlug},
+		{"too short", "g", ErrInvalidSlug},
+		{"leading dash", "-globex", ErrInvalidSlug},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrganizationService()
+
+			organization, err := s.Create("u1", models.CreateOrganization{Name: "Globex", Slug: tt.slug})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.want)
+			}
+
+			if tt.want == nil && organization.Role != models.OrgRoleOwner {
+				t.Errorf("Create() role = %q, want owner", organization.Role)
+			}
+		})
+	}
+}
+
+func TestOrganizationUpdateMember(t *testing.T) {
+	owner := membership("m1", "o1", "owner", models.OrgRoleOwner)
+	admin := membership("m2", "o1", "admin", models.OrgRoleAdmin)
+	member := membership("m3", "o1", "member", models.OrgRoleMember)
+	outsider := membership("m4", "o2", "outsider", models.OrgRoleMember)
+
+	tests := []struct {
+		name   string
+		actor  models.Membership
+		userId string
+		role   string
+		extra  []models.Membership
+		want   error
+	}{
+		{"admin promotes member", admin, "member", models.OrgRoleAdmin, nil, nil},
+		{"admin makes owner", admin, "member", models.OrgRoleOwner, nil, ErrOrgRoleForbidden},
+		{"admin demotes owner", admin, "owner", models.OrgRoleMember, nil, ErrOrgRoleForbidden},
+		{"owner makes owner", owner, "member", models.OrgRoleOwner, nil, nil},
+		{"last owner steps down", owner, "owner", models.OrgRoleAdmin, nil, ErrLastOwner},
+		{"owner steps down", owner, "owner", models.OrgRoleAdmin, []models.Membership{membership("m5", "o1", "second", models.OrgRoleOwner)}, nil},
+		{"owner of another organization does not count", owner, "owner", models.OrgRoleAdmin, []models.Membership{membership("m5", "o2", "second", models.OrgRoleOwner)}, ErrLastOwner},
+		{"member of another organization", owner, "outsider", models.OrgRoleAdmin, nil, ErrUnknownMember},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrganizationService(append([]models.Membership{owner, admin, member, outsider}, tt.extra...)...)
+
+			_, err := s.UpdateMember("o1", tt.actor, tt.userId, models.UpdateMembership{Role: tt.role})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("UpdateMember() error = %v, want %v", err, tt.want)
+			}
+
+			stored, _ := s.membershipRepository.FindByUserId("o1", tt.userId)
+			if changed := stored.Role == tt.role; changed != (tt.want == nil) {
+				t.Errorf("UpdateMember() stored role = %q, want changed %v", stored.Role, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestOrganizationRemoveMember(t *testing.T) {
+	owner := membership("m1", "o1", "owner", models.OrgRoleOwner)
+	admin := membership("m2", "o1", "admin", models.OrgRoleAdmin)
+	member := membership("m3", "o1", "member", models.OrgRoleMember)
+	second := membership("m5", "o1", "second", models.OrgRoleOwner)
+
+	tests := []struct {
+		name   string
+		actor  models.Membership
+		userId string
+		extra  []models.Membership
+		want   error
+	}{
+		{"member leaves", member, "member", nil, nil},
+		{"member removes member", member, "admin", nil, ErrOrgAdminRequired},
+		{"admin removes member", admin, "member", nil, nil},
+		{"admin removes owner", admin, "owner", []models.Membership{second}, ErrOrgRoleForbidden},
+		{"owner removes owner", owner, "second", []models.Membership{second}, nil},
+		{"last owner leaves", owner, "owner", nil, ErrLastOwner},
+		{"owner leaves", owner, "owner", []models.Membership{second}, nil},
+		{"unknown member", owner, "nobody", nil, ErrUnknownMember},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOrganizationService(append([]models.Membership{owner, admin, member}, tt.extra...)...)
+
+			err := s.RemoveMember("o1", tt.actor, tt.userId)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("RemoveMember() error = %v, want %v", err, tt.want)
+			}
+
+			_, err = s.membershipRepository.FindByUserId("o1", tt.userId)
+			if removed := err != nil; removed != (tt.want == nil || errors.Is(tt.want, ErrUnknownMember)) {
+				t.Errorf("RemoveMember() removed = %v, want %v", removed, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestOrganizationFindMembershipIsScoped(t *testing.T) {
+	s := newTestOrganizationService(membership("m1", "o1", "u1", models.OrgRoleOwner))
+
+	if _, err := s.FindMembership("o2", "u1"); !errors.Is(err, ErrNotMember) {
+		t.Errorf("FindMembership() error = %v, want ErrNotMember", err)
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/webauthn"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidCeremony = errors.New("invalid or expired webauthn ceremony")
+	ErrUnknownPasskey  = errors.New("unknown passkey")
+)
+
+type PasskeyService interface {
+	BeginRegistration(userId string) (webauthn.CreationOptions, error)
+	FinishRegistration(userId string, passkey models.RegisterPasskey) (models.Passkey, error)
+	FindAll(userId string) ([]models.Passkey, error)
+	Delete(userId, id string) error
+	BeginLogin() (webauthn.RequestOptions, error)
+	FinishLogin(res webauthn.AssertionResponse, client models.ClientInfo) (models.Token, error)
+	DeleteExpired() error
+}
+
+func NewPasskeyService(repository repositories.PasskeyRepository, sessionRepository repositories.WebauthnSessionRepository, userRepository repositories.UserRepository, tokenService TokenService, mfaService MfaService, cfg config.Config) PasskeyService {
+	log.Info().Msg("Creating new passkey service")
+
+	return &passkeyService{
+		repository:        repository,
+		sessionRepository: sessionRepository,
+		userRepository:    userRepository,
+		tokenService:      tokenService,
+		mfaService:        mfaService,
+		rp: &webauthn.RelyingParty{
+			ID:      cfg.WebauthnRPID,
+			Name:    cfg.WebauthnRPName,
+			Origins: cfg.WebauthnOrigins,
+		},
+		cfg: cfg,
+	}
+}
+
+type passkeyService struct {
+	repository        repositories.PasskeyRepository
+	sessionRepository repositories.WebauthnSessionRepository
+	userRepository    repositories.UserRepository
+	tokenService      TokenService
+	mfaService        MfaService
+	rp                *webauthn.RelyingParty
+	cfg               config.Config
+}
+
+func (s *passkeyService) BeginRegistration(userId string) (webauthn.CreationOptions, error) {
+	var options webauthn.CreationOptions
+
+	user, err := s.userRepository.FindById(userId)
+	if err != nil {
+		return options, err
+	}
+
+	passkeys, err := s.repository.FindByUserId(userId)
+	if err != nil {
+		return options, err
+	}
+
+	exclude := make([]string, 0, len(passkeys))
+	for _, p := range passkeys {
+		exclude = append(exclude, p.CredentialID)
+	}
+
+	challenge, err := s.begin(models.CeremonyRegistration, user.ID)
+	if err != nil {
+		return options, err
+	}
+
+	return s.rp.NewCreationOptions(challenge, user.ID, user.Email, user.Name, exclude, s.cfg.WebauthnTimeout), nil
+}
+
+func (s *passkeyService) FinishRegistration(userId string, passkey models.RegisterPasskey) (models.Passkey, error) {
+	var newPasskey models.Passkey
+
+	challenge, err := s.finish(models.CeremonyRegistration, passkey.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return newPasskey, err
+	}
+
+	if challenge.UserID != userId {
+		return newPasskey, ErrInvalidCeremony
+	}
+
+	cred, err := s.rp.VerifyRegistration(challenge.value, passkey.Credential)
+	if err != nil {
+		return newPasskey, err
+	}
+
+	name := passkey.Name
+	if name == "" {
+		name = "Passkey"
+	}
+
+	newPasskey = models.Passkey{
+		UserID:       userId,
+		Name:         name,
+		CredentialID: base64.RawURLEncoding.EncodeToString(cred.ID),
+		PublicKey:    cred.PublicKey,
+		Algorithm:    cred.Algorithm,
+		SignCount:    cred.SignCount,
+		AAGUID:       hex.EncodeToString(cred.AAGUID),
+	}
+
+	err = s.repository.Create(&newPasskey)
+	return newPasskey, err
+}
+
+func (s *passkeyService) FindAll(userId string) ([]models.Passkey, error) {
+	return s.repository.FindByUserId(userId)
+}
+
+func (s *passkeyService) Delete(userId, id string) error {
+	passkeys, err := s.repository.FindByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range passkeys {
+		if p.ID == id {
+			return s.repository.Delete(&p)
+		}
+	}
+
+	return ErrUnknownPasskey
+}
+
+func (s *passkeyService) BeginLogin() (webauthn.RequestOptions, error) {
+	challenge, err := s.begin(models.CeremonyLogin, "")
+	if err != nil {
+		return webauthn.RequestOptions{}, err
+	}
+
+	return s.rp.NewRequestOptions(challenge, nil, s.cfg.WebauthnTimeout), nil
+}
+
+// FinishLogin verifies the assertion of a discoverable passkey. A passkey
+// that verified the user counts as multi-factor, otherwise the usual second
+// factor challenge applies.
+func (s *passkeyService) FinishLogin(res webauthn.AssertionResponse, client models.ClientInfo) (models.Token, error) {
+	var token models.Token
+
+	challenge, err := s.finish(models.CeremonyLogin, res.Response.ClientDataJSON)
+	if err != nil {
+		return token, err
+	}
+
+	passkey, err := s.repository.FindByCredentialId(strings.TrimRight(res.ID, "="))
+	if err != nil {
+		return token, ErrUnknownPasskey
+	}
+
+	if res.Response.UserHandle != "" {
+		userHandle := base64.RawURLEncoding.EncodeToString([]byte(passkey.UserID))
+		if strings.TrimRight(res.Response.UserHandle, "=") != userHandle {
+			return token, ErrUnknownPasskey
+		}
+	}
+
+	assertion, err := s.rp.VerifyAssertion(challenge.value, res, passkey.PublicKey, passkey.SignCount)
+	if err != nil {
+		return token, err
+	}
+
+	ok, err := s.repository.UpdateSignCount(passkey.ID, passkey.SignCount, assertion.SignCount)
+	if err != nil {
+		return token, err
+	}
+
+	if !ok {
+		return token, webauthn.ErrSignCount
+	}
+
+	user, err := s.userRepository.FindById(passkey.UserID)
+	if err != nil {
+		return token, err
+	}
+
+	if !assertion.UserVerified {
+		err = s.mfaService.Challenge(user, auth.AuthMethodHardware)
+		if err != nil {
+			return token, err
+		}
+
+		return s.tokenService.Issue(user, client, auth.AuthMethodHardware)
+	}
+
+	return s.tokenService.Issue(user, client, auth.AuthMethodHardware, auth.AuthMethodMFA)
+}
+
+func (s *passkeyService) DeleteExpired() error {
+	return s.sessionRepository.DeleteExpired()
+}
+
+// pendingChallenge is a ceremony session together with its challenge.
+type pendingChallenge struct {
+	models.WebauthnSession
+	value string
+}
+
+func (s *passkeyService) begin(ceremony, userId string) (string, error) {
+	challenge, err := auth.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.sessionRepository.Create(&models.WebauthnSession{
+		ChallengeHash: auth.HashToken(challenge),
+		Ceremony:      ceremony,
+		UserID:        userId,
+		ExpiresAt:     time.Now().Add(s.cfg.WebauthnTimeout),
+	})
+
+	return challenge, err
+}
+
+// finish looks up and consumes the session of the challenge echoed by the
+// browser, the challenge is single use whether or not verification passes.
+func (s *passkeyService) finish(ceremony, clientDataJSON string) (pendingChallenge, error) {
+	var pending pendingChallenge
+
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return pending, err
+	}
+
+	session, err := s.sessionRepository.FindByChallengeHash(auth.HashToken(challenge))
+	if err != nil {
+		return pending, ErrInvalidCeremony
+	}
+
+	ok, err := s.sessionRepository.Consume(session.ID)
+	if err != nil {
+		return pending, err
+	}
+
+	if !ok || session.Ceremony != ceremony || time.Now().After(session.ExpiresAt) {
+		return pending, ErrInvalidCeremony
+	}
+
+	pending.WebauthnSession = session
+	pending.value = challenge
+
+	return pending, nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/password"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/mailer"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	ErrWrongPassword     = errors.New("current password is wrong")
+	ErrReauthRequired    = errors.New("sign in again to set a password")
+)
+
+type PasswordService interface {
+	Forgot(email string) error
+	Reset(reset models.ResetPassword) error
+	Change(claims *auth.Claims, change models.ChangePassword) error
+	DeleteExpired() error
+}
+
+func NewPasswordService(repository repositories.PasswordResetRepository, userRepository repositories.UserRepository, tokenService TokenService, mailer mailer.Mailer, policy *password.Policy, hasher password.Hasher, cfg config.Config) PasswordService {
+	log.Info().Msg("Creating new password service")
+
+	return &passwordService{
+		repository:     repository,
+		userRepository: userRepository,
+		tokenService:   tokenService,
+		mailer:         mailer,
+		policy:         policy,
+		hasher:         hasher,
+		cfg:            cfg,
+	}
+}
+
+type passwordService struct {
+	repository     repositories.PasswordResetRepository
+	userRepository repositories.UserRepository
+	tokenService   TokenService
+	mailer         mailer.Mailer
+	policy         *password.Policy
+	hasher         password.Hasher
+	cfg            config.Config
+}
+
+// Forgot mails a reset link if the email belongs to a user. The link is
+// sent in the background and failures are only logged, so neither the
+// response nor its timing tells whether the account exists.
+func (s *passwordService) Forgot(email string) error {
+	user, err := s.userRepository.FindByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	go func() {
+		err := s.sendResetLink(user)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to send password reset email")
+		}
+	}()
+
+	return nil
+}
+
+// Reset sets a new password and signs the user out everywhere. Following
+// the emailed link also proves ownership of the address.
+func (s *passwordService) Reset(reset models.ResetPassword) error {
+	token, err := s.repository.FindByHash(auth.HashToken(reset.Token))
+	if err != nil || time.Now().After(token.ExpiresAt) || token.UsedAt != nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepository.FindById(token.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	// The token is only spent once the new password is acceptable.
+	err = s.policy.Validate(reset.Password, user.Email, user.Name)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.repository.MarkUsed(token.ID)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidResetToken
+	}
+
+	err = s.setPassword(&user, reset.Password)
+	if err != nil {
+		return err
+	}
+
+	if user.VerifiedAt == nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+
+	err = s.userRepository.Update(&user)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DeleteByUserId(user.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.tokenService.LogoutAll(user.ID)
+}
+
+// Change sets a new password for a signed in user and signs them out
+// everywhere. Passwordless accounts can set a first password without the
+// current one if they signed in recently.
+func (s *passwordService) Change(claims *auth.Claims, change models.ChangePassword) error {
+	user, err := s.userRepository.FindById(claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" {
+		ok, err := s.hasher.Verify(change.CurrentPassword, user.Password)
+		if err != nil || !ok {
+			return ErrWrongPassword
+		}
+	} else if claims.AuthTime == nil || time.Since(claims.AuthTime.Time) > s.cfg.PasswordSetMaxAuthAge {
+		return ErrReauthRequired
+	}
+
+	err = s.policy.Validate(change.NewPassword, user.Email, user.Name)
+	if err != nil {
+		return err
+	}
+
+	err = s.setPassword(&user, change.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepository.Update(&user)
+	if err != nil {
+		return err
+	}
+
+	return s.tokenService.LogoutAll(user.ID)
+}
+
+func (s *passwordService) DeleteExpired() error {
+	return s.repository.DeleteExpired()
+}
+
+func (s *passwordService) setPassword(user *models.User, password string) error {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	return nil
+}
+
+func (s *passwordService) sendResetLink(user models.User) error {
+	token, err := auth.RandomToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.PasswordResetTokenLifespan),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.PasswordResetUrl + "?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %s. If you did not ask for this you can ignore this email.\n",
+			user.Name, link, s.cfg.PasswordResetTokenLifespan),
+	})
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/password"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memoryPasswordResetRepository keeps reset tokens in memory.
+type memoryPasswordResetRepository struct {
+	repositories.PasswordResetRepository
+	tokens []*models.PasswordResetToken
+}
+
+func (r *memoryPasswordResetRepository) FindByHash(hash string) (models.PasswordResetToken, error) {
+	for _, t := range r.tokens {
+		if t.TokenHash == hash {
+			return *t, nil
+		}
+	}
+
+	return models.PasswordResetToken{}, errors.New("record not found")
+}
+
+func (r *memoryPasswordResetRepository) MarkUsed(id string) (bool, error) {
+	for _, t := range r.tokens {
+		if t.ID == id && t.UsedAt == nil {
+			now := time.Now()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryPasswordResetRepository) DeleteByUserId(userId string) error {
+	return nil
+}
+
+// logoutTokenService records whose tokens were revoked.
+type logoutTokenService struct {
+	TokenService
+	loggedOut []string
+}
+
+func (s *logoutTokenService) LogoutAll(userId string) error {
+	s.loggedOut = append(s.loggedOut, userId)
+	return nil
+}
+
+func newTestPasswordService(t *testing.T, users ...models.User) (*passwordService, *memoryPasswordResetRepository, *logoutTokenService) {
+	hasher, err := password.NewHasher(password.HasherConfig{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+
+	resets := &memoryPasswordResetRepository{tokens: []*models.PasswordResetToken{
+		{Base: models.Base{ID: "r1"}, UserID: "u1", TokenHash: auth.HashToken("valid"), ExpiresAt: time.Now().Add(time.Hour)},
+		{Base: models.Base{ID: "r2"}, UserID: "u1", TokenHash: auth.HashToken("expired"), ExpiresAt: time.Now().Add(-time.Second)},
+	}}
+	tokens := &logoutTokenService{}
+
+	return &passwordService{
+		repository:     resets,
+		userRepository: &updatingUserRepository{fixedUserRepository{users: users}},
+		tokenService:   tokens,
+		policy:         &password.Policy{MinLength: 8, MaxLength: 72},
+		hasher:         hasher,
+		cfg:            config.Config{PasswordSetMaxAuthAge: 5 * time.Minute},
+	}, resets, tokens
+}
+
+func TestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		want     error
+	}{
+		{"valid", "valid", "correct horse battery", nil},
+		{"unknown", "forged", "correct horse battery", ErrInvalidResetToken},
+		{"expired", "expired", "correct horse battery", ErrInvalidResetToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, tokens := newTestPasswordService(t, models.User{Base: models.Base{ID: "u1"}, Email: "user@example.com"})
+
+			err := s.Reset(models.ResetPassword{Token: tt.token, Password: tt.password})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Reset() error = %v, want %v", err, tt.want)
+			}
+
+			if loggedOut := len(tokens.loggedOut) == 1; loggedOut != (tt.want == nil) {
+				t.Errorf("Reset() logged out = %v, want %v", loggedOut, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestPasswordResetIsSingleUse(t *testing.T) {
+	s, _, _ := newTestPasswordService(t, models.User{Base: models.Base{ID: "u1"}, Email: "user@example.com"})
+
+	if err := s.Reset(models.ResetPassword{Token: "valid", Password: "correct horse battery"}); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+
+	user, _ := s.userRepository.FindById("u1")
+	if ok, _ := s.hasher.Verify("correct horse battery", user.Password); !ok || user.VerifiedAt == nil {
+		t.Errorf("Reset() user = %+v, want the new password and a verified email", user)
+	}
+
+	if err := s.Reset(models.ResetPassword{Token: "valid", Password: "another horse battery"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Reset() replay error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestPasswordResetKeepsTokenOnPolicyViolation(t *testing.T) {
+	s, resets, _ := newTestPasswordService(t, models.User{Base: models.Base{ID: "u1"}, Email: "user@example.com"})
+
+	var policyErr *password.PolicyError
+	if err := s.Reset(models.ResetPassword{Token: "valid", Password: "short"}); !errors.As(err, &policyErr) {
+		t.Fatalf("Reset() error = %v, want PolicyError", err)
+	}
+
+	if resets.tokens[0].UsedAt != nil {
+		t.Errorf("Reset() spent the token on a rejected password")
+	}
+}
+
+func TestPasswordChange(t *testing.T) {
+	s, _, _ := newTestPasswordService(t)
+
+	hashed, err := s.hasher.Hash("current password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	recent := jwt.NewNumericDate(time.Now())
+	stale := jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name     string
+		user     models.User
+		authTime *jwt.NumericDate
+		current  string
+		want     error
+	}{
+		{"current password", models.User{Password: hashed}, stale, "current password", nil},
+		{"wrong password", models.User{Password: hashed}, recent, "wrong password", ErrWrongPassword},
+		{"first password after recent sign in", models.User{}, recent, "", nil},
+		{"first password after stale sign in", models.User{}, stale, "", ErrReauthRequired},
+		{"first password without auth time", models.User{}, nil, "", ErrReauthRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.ID = "u1"
+			s.userRepository = &updatingUserRepository{fixedUserRepository{users: []models.User{user}}}
+
+			claims := &auth.Claims{UserID: "u1", AuthTime: tt.authTime}
+			err := s.Change(claims, models.ChangePassword{CurrentPassword: tt.current, NewPassword: "correct horse battery"})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Change() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
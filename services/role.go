@@ -0,0 +1,542 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrUnknownRole       = errors.New("unknown role")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrInvalidRoleName   = errors.New("role names are 2 to 32 lowercase letters, digits, - or _")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrBuiltInRole       = errors.New("built-in roles can not be deleted")
+	ErrAdminRole         = errors.New("the permissions of the admin role can not be changed")
+	ErrRoleInUse         = errors.New("role is still assigned to users or groups or inherited by roles")
+	ErrRoleCycle         = errors.New("roles can not inherit themselves")
+	ErrUnknownInherited  = errors.New("inherited role does not exist")
+	ErrRoleNotHeld       = errors.New("roles can only be granted by those holding all of their permissions")
+)
+
+var roleName = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// defaultRoles are created on startup when missing.
+var defaultRoles = []models.Role{
+	{Name: models.AdminRole, Description: "Full access", Permissions: []string{"*"}, Inherits: []string{models.UserRole}},
+	{Name: models.UserRole, Description: "Every registered user", Permissions: []string{}, Inherits: []string{}},
+}
+
+type RoleService interface {
+	Seed() error
+	FindAll() ([]models.Role, error)
+	FindByName(name string) (models.Role, error)
+	Create(claims *auth.Claims, role models.CreateRole) (models.Role, error)
+	Update(claims *auth.Claims, name string, role models.UpdateRole) (models.Role, error)
+	Delete(name string) error
+	AddPermission(claims *auth.Claims, name, permission string) (models.Role, error)
+	RemovePermission(claims *auth.Claims, name, permission string) (models.Role, error)
+	HasPermission(roles []string, permission string) (bool, error)
+	Can(claims *auth.Claims, permission string) (bool, error)
+	CanGrant(claims *auth.Claims, roles []string) error
+	Expand(roles []string) ([]string, error)
+}
+
+func NewRoleService(repository repositories.RoleRepository, userRepository repositories.UserRepository, groupRepository repositories.GroupRepository, cfg config.Config) RoleService {
+	log.Info().Msg("Creating new role service")
+
+	return &roleService{
+		repository:      repository,
+		userRepository:  userRepository,
+		groupRepository: groupRepository,
+		cfg:             cfg,
+	}
+}
+
+type roleService struct {
+	repository      repositories.RoleRepository
+	userRepository  repositories.UserRepository
+	groupRepository repositories.GroupRepository
+	cfg             config.Config
+
+	mu       sync.RWMutex
+	cache    map[string]models.Role
+	cachedAt time.Time
+}
+
+func (s *roleService) Seed() error {
+	err := s.repository.Seed(defaultRoles)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// FindAll returns the roles with the number of users holding each, on
+// their own, through a group or by inheriting it.
+func (s *roleService) FindAll() ([]models.Role, error) {
+	roles, err := s.repository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	sets, err := s.userRepository.CountByRoleSets()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := s.roles()
+	if err != nil {
+		return nil, err
+	}
+
+	// Expanding a set yields every role once, so each user counts once.
+	counts := make(map[string]int64)
+	for _, set := range sets {
+		for _, role := range expand(cache, set.Roles) {
+			counts[role] += set.Users
+		}
+	}
+
+	for i := range roles {
+		roles[i].Members = counts[roles[i].Name]
+	}
+
+	return roles, nil
+}
+
+func (s *roleService) FindByName(name string) (models.Role, error) {
+	role, err := s.repository.FindByName(name)
+	if err != nil {
+		return role, ErrUnknownRole
+	}
+
+	return role, nil
+}
+
+// Create adds a role. Like every change to a role it may only grant or
+// inherit what the caller holds.
+func (s *roleService) Create(claims *auth.Claims, role models.CreateRole) (models.Role, error) {
+	if !roleName.MatchString(role.Name) {
+		return models.Role{}, ErrInvalidRoleName
+	}
+
+	_, err := s.repository.FindByName(role.Name)
+	if err == nil {
+		return models.Role{}, ErrRoleExists
+	}
+
+	permissions, err := validPermissions(role.Permissions)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	inherits, err := s.validInherits(role.Name, role.Inherits)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	err = s.canChange(claims, nil, permissions, nil, inherits)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	newRole := models.Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		Inherits:    inherits,
+	}
+
+	err = s.repository.Create(&newRole)
+	if err != nil {
+		return newRole, err
+	}
+
+	s.invalidate()
+	return newRole, nil
+}
+
+func (s *roleService) Update(claims *auth.Claims, name string, role models.UpdateRole) (models.Role, error) {
+	existing, err := s.FindByName(name)
+	if err != nil {
+		return existing, err
+	}
+
+	permissions, err := validPermissions(role.Permissions)
+	if err != nil {
+		return existing, err
+	}
+
+	if name == models.AdminRole && !sameSet(permissions, existing.Permissions) {
+		return existing, ErrAdminRole
+	}
+
+	inherits, err := s.validInherits(name, role.Inherits)
+	if err != nil {
+		return existing, err
+	}
+
+	err = s.canChange(claims, existing.Permissions, permissions, existing.Inherits, inherits)
+	if err != nil {
+		return existing, err
+	}
+
+	existing.Description = role.Description
+	existing.Permissions = permissions
+	existing.Inherits = inherits
+
+	return s.save(existing)
+}
+
+// Delete removes a role that no user or group holds anymore.
+func (s *roleService) Delete(name string) error {
+	if name == models.AdminRole || name == models.UserRole {
+		return ErrBuiltInRole
+	}
+
+	role, err := s.FindByName(name)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.userRepository.CountByRole(name)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	count, err = s.groupRepository.CountByRole(name)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	cache, err := s.roles()
+	if err != nil {
+		return err
+	}
+
+	for _, other := range cache {
+		for _, inherited := range other.Inherits {
+			if inherited == name {
+				return ErrRoleInUse
+			}
+		}
+	}
+
+	err = s.repository.Delete(&role)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+func (s *roleService) AddPermission(claims *auth.Claims, name, permission string) (models.Role, error) {
+	role, err := s.FindByName(name)
+	if err != nil {
+		return role, err
+	}
+
+	if !models.IsPermission(permission) {
+		return role, ErrUnknownPermission
+	}
+
+	if name == models.AdminRole {
+		return role, ErrAdminRole
+	}
+
+	for _, p := range role.Permissions {
+		if p == permission {
+			return role, nil
+		}
+	}
+
+	err = s.canChange(claims, nil, []string{permission}, nil, nil)
+	if err != nil {
+		return role, err
+	}
+
+	role.Permissions = append(role.Permissions, permission)
+
+	return s.save(role)
+}
+
+func (s *roleService) RemovePermission(claims *auth.Claims, name, permission string) (models.Role, error) {
+	role, err := s.FindByName(name)
+	if err != nil {
+		return role, err
+	}
+
+	if name == models.AdminRole {
+		return role, ErrAdminRole
+	}
+
+	for i, p := range role.Permissions {
+		if p == permission {
+			err = s.canChange(claims, []string{permission}, nil, nil, nil)
+			if err != nil {
+				return role, err
+			}
+
+			role.Permissions = append(role.Permissions[:i], role.Permissions[i+1:]...)
+			return s.save(role)
+		}
+	}
+
+	return role, nil
+}
+
+// HasPermission reports whether any of the roles, or a role they inherit,
+// grants the permission. Roles are cached so checking does not hit the
+// database on every request, changes made by other instances are picked up
+// once the cache expires.
+func (s *roleService) HasPermission(roles []string, permission string) (bool, error) {
+	cache, err := s.roles()
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range expand(cache, roles) {
+		role, ok := cache[name]
+		if ok && role.Grants(permission) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Can reports whether the principal of the claims holds the permission.
+// Machine clients hold the permissions of their scopes, OAuth clients
+// acting for a user hold none. API keys without the admin scope hold the
+// permissions of their owner's roles that their scopes grant as well.
+func (s *roleService) Can(claims *auth.Claims, permission string) (bool, error) {
+	switch {
+	case claims.IsClient():
+		return models.ScopesGrant(claims.Scope, permission), nil
+	case claims.Delegated():
+		return false, nil
+	case claims.Type == auth.TokenTypeApiKey && !models.HasScope(claims.Scope, models.ApiScopeAdmin) && !models.ScopesGrant(claims.Scope, permission):
+		return false, nil
+	}
+
+	return s.HasPermission(claims.Roles, permission)
+}
+
+// CanGrant returns ErrRoleNotHeld unless the principal of the claims holds
+// every permission of the roles and of the roles they inherit, so nobody
+// can hand out more than they have.
+func (s *roleService) CanGrant(claims *auth.Claims, roles []string) error {
+	cache, err := s.roles()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range expand(cache, roles) {
+		for _, permission := range cache[name].Permissions {
+			allowed, err := s.Can(claims, permission)
+			if err != nil {
+				return err
+			}
+
+			if !allowed {
+				return ErrRoleNotHeld
+			}
+		}
+	}
+
+	return nil
+}
+
+// canChange returns ErrRoleNotHeld unless the principal of the claims holds
+// every permission added to or removed from a role and every role it starts
+// or stops inheriting, so the registry can not be used to grant yourself
+// more than you have.
+func (s *roleService) canChange(claims *auth.Claims, permissions, newPermissions, inherits, newInherits []string) error {
+	for _, permission := range changed(permissions, newPermissions) {
+		allowed, err := s.Can(claims, permission)
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			return ErrRoleNotHeld
+		}
+	}
+
+	return s.CanGrant(claims, changed(inherits, newInherits))
+}
+
+// Expand adds every role inherited by the roles, directly or through
+// other roles. Unknown roles are kept as they are.
+func (s *roleService) Expand(roles []string) ([]string, error) {
+	cache, err := s.roles()
+	if err != nil {
+		return nil, err
+	}
+
+	return expand(cache, roles), nil
+}
+
+// validInherits checks that the inherited roles exist and that inheriting
+// them does not lead back to the role itself.
+func (s *roleService) validInherits(name string, inherits []string) ([]string, error) {
+	cache, err := s.roles()
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make([]string, 0, len(inherits))
+	for _, inherited := range inherits {
+		if _, ok := cache[inherited]; !ok {
+			return nil, ErrUnknownInherited
+		}
+
+		valid = append(valid, inherited)
+	}
+
+	for _, role := range expand(cache, valid) {
+		if role == name {
+			return nil, ErrRoleCycle
+		}
+	}
+
+	return valid, nil
+}
+
+func (s *roleService) roles() (map[string]models.Role, error) {
+	s.mu.RLock()
+	cache, cachedAt := s.cache, s.cachedAt
+	s.mu.RUnlock()
+
+	if cache != nil && time.Since(cachedAt) < s.cfg.RoleCacheTTL {
+		return cache, nil
+	}
+
+	roles, err := s.repository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cache = make(map[string]models.Role, len(roles))
+	for _, role := range roles {
+		cache[role.Name] = role
+	}
+
+	s.mu.Lock()
+	s.cache, s.cachedAt = cache, time.Now()
+	s.mu.Unlock()
+
+	return cache, nil
+}
+
+func (s *roleService) save(role models.Role) (models.Role, error) {
+	err := s.repository.Update(&role)
+	if err != nil {
+		return role, err
+	}
+
+	s.invalidate()
+	return role, nil
+}
+
+func (s *roleService) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+func expand(cache map[string]models.Role, roles []string) []string {
+	seen := make(map[string]bool, len(roles))
+	expanded := make([]string, 0, len(roles))
+
+	queue := append([]string(nil), roles...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		expanded = append(expanded, name)
+		queue = append(queue, cache[name].Inherits...)
+	}
+
+	return expanded
+}
+
+func validPermissions(permissions []string) ([]string, error) {
+	valid := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		if !models.IsPermission(p) {
+			return nil, ErrUnknownPermission
+		}
+
+		valid = append(valid, p)
+	}
+
+	return valid, nil
+}
+
+// changed returns the values in only one of a and b.
+func changed(a, b []string) []string {
+	var diff []string
+	for _, v := range a {
+		if !contains(b, v) {
+			diff = append(diff, v)
+		}
+	}
+
+	for _, v := range b {
+		if !contains(a, v) {
+			diff = append(diff, v)
+		}
+	}
+
+	return diff
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sameSet(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+
+	for _, v := range b {
+		if !set[v] {
+			return false
+		}
+	}
+
+	return len(set) == len(b)
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// roleSetUserRepository serves fixed role sets to the role service.
+type roleSetUserRepository struct {
+	repositories.UserRepository
+	sets []repositories.RoleSet
+}
+
+func (r *roleSetUserRepository) CountByRoleSets() ([]repositories.RoleSet, error) {
+	return r.sets, nil
+}
+
+func TestFindAllCountsEveryHolderOnce(t *testing.T) {
+	s := &roleService{
+		repository: &defaultRoleRepository{},
+		userRepository: &roleSetUserRepository{sets: []repositories.RoleSet{
+			{Roles: []string{models.UserRole}, Users: 3},
+			{Roles: []string{models.AdminRole}, Users: 1},
+			{Roles: []string{models.AdminRole, models.UserRole}, Users: 2},
+		}},
+		cfg: config.Config{RoleCacheTTL: time.Minute},
+	}
+
+	roles, err := s.FindAll()
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+
+	want := map[string]int64{models.AdminRole: 3, models.UserRole: 6}
+	for _, role := range roles {
+		if role.Members != want[role.Name] {
+			t.Errorf("FindAll() %s members = %d, want %d", role.Name, role.Members, want[role.Name])
+		}
+	}
+}
+
+// fixedRoleRepository serves a fixed set of roles to the role service.
+type fixedRoleRepository struct {
+	repositories.RoleRepository
+	roles []models.Role
+}
+
+func (r *fixedRoleRepository) FindAll() ([]models.Role, error) {
+	return r.roles, nil
+}
+
+func (r *fixedRoleRepository) FindByName(name string) (models.Role, error) {
+	for _, role := range r.roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+
+	return models.Role{}, errors.New("record not found")
+}
+
+func (r *fixedRoleRepository) Create(t *models.Role) error {
+	r.roles = append(r.roles, *t)
+	return nil
+}
+
+func (r *fixedRoleRepository) Update(t *models.Role) error {
+	for i, role := range r.roles {
+		if role.Name == t.Name {
+			r.roles[i] = *t
+		}
+	}
+
+	return nil
+}
+
+func (r *fixedRoleRepository) Delete(t *models.Role) error {
+	for i, role := range r.roles {
+		if role.Name == t.Name {
+			r.roles = append(r.roles[:i], r.roles[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// roleHolders counts the holders of each role.
+type roleHolders map[string]int64
+
+type roleHoldingUserRepository struct {
+	repositories.UserRepository
+	holders roleHolders
+}
+
+func (r *roleHoldingUserRepository) CountByRole(role string) (int64, error) {
+	return r.holders[role], nil
+}
+
+type roleHoldingGroupRepository struct {
+	repositories.GroupRepository
+	holders roleHolders
+}
+
+func (r *roleHoldingGroupRepository) CountByRole(role string) (int64, error) {
+	return r.holders[role], nil
+}
+
+func newFixedRoleService(roles ...models.Role) *roleService {
+	return &roleService{
+		repository: &fixedRoleRepository{roles: append(append([]models.Role(nil), defaultRoles...), roles...)},
+		cfg:        config.Config{RoleCacheTTL: time.Minute},
+	}
+}
+
+func TestCanChangeRoles(t *testing.T) {
+	s := newFixedRoleService(
+		models.Role{Name: "manager", Permissions: []string{models.PermissionRolesManage, models.PermissionUsersRead}},
+		models.Role{Name: "support", Permissions: []string{models.PermissionUsersImpersonate}},
+	)
+
+	manager := &auth.Claims{UserID: "m1", Roles: []string{"manager"}, Type: auth.TokenTypeAccess}
+	admin := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name           string
+		claims         *auth.Claims
+		permissions    []string
+		newPermissions []string
+		inherits       []string
+		newInherits    []string
+		want           error
+	}{
+		{"add held permission", manager, nil, []string{models.PermissionUsersRead}, nil, nil, nil},
+		{"add permission not held", manager, nil, []string{models.PermissionUsersRoles}, nil, nil, ErrRoleNotHeld},
+		{"add wildcard", manager, nil, []string{"*"}, nil, nil, ErrRoleNotHeld},
+		{"add resource wildcard", manager, nil, []string{"users:*"}, nil, nil, ErrRoleNotHeld},
+		{"remove permission not held", manager, []string{models.PermissionUsersImpersonate}, nil, nil, nil, ErrRoleNotHeld},
+		{"keep permission not held", manager, []string{models.PermissionUsersImpersonate}, []string{models.PermissionUsersImpersonate, models.PermissionUsersRead}, nil, nil, nil},
+		{"inherit held role", manager, nil, nil, nil, []string{"manager"}, nil},
+		{"inherit admin", manager, nil, nil, nil, []string{models.AdminRole}, ErrRoleNotHeld},
+		{"inherit role not held", manager, nil, nil, nil, []string{"support"}, ErrRoleNotHeld},
+		{"admin adds anything", admin, nil, []string{"*"}, nil, []string{"support"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.canChange(tt.claims, tt.permissions, tt.newPermissions, tt.inherits, tt.newInherits)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("canChange() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddPermissionNotHeld(t *testing.T) {
+	s := newFixedRoleService(
+		models.Role{Name: "manager", Permissions: []string{models.PermissionRolesManage}},
+	)
+
+	manager := &auth.Claims{UserID: "m1", Roles: []string{"manager"}, Type: auth.TokenTypeAccess}
+
+	_, err := s.AddPermission(manager, "manager", models.PermissionUsersRoles)
+	if !errors.Is(err, ErrRoleNotHeld) {
+		t.Errorf("AddPermission() error = %v, want ErrRoleNotHeld", err)
+	}
+}
+
+func TestRoleCreate(t *testing.T) {
+	manager := &auth.Claims{UserID: "m1", Roles: []string{"manager"}, Type: auth.TokenTypeAccess}
+	admin := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		role   models.CreateRole
+		want   error
+	}{
+		{"valid", manager, models.CreateRole{Name: "reader", Permissions: []string{models.PermissionUsersRead}, Inherits: []string{models.UserRole}}, nil},
+		{"invalid name", admin, models.CreateRole{Name: "Reader"}, ErrInvalidRoleName},
+		{"existing", admin, models.CreateRole{Name: "manager"}, ErrRoleExists},
+		{"unknown permission", admin, models.CreateRole{Name: "reader", Permissions: []string{"users:fly"}}, ErrUnknownPermission},
+		{"unknown inherited role", admin, models.CreateRole{Name: "reader", Inherits: []string{"ghost"}}, ErrUnknownInherited},
+		{"permission not held", manager, models.CreateRole{Name: "deleter", Permissions: []string{models.PermissionUsersDelete}}, ErrRoleNotHeld},
+		{"inherits admin", manager, models.CreateRole{Name: "shadow", Inherits: []string{models.AdminRole}}, ErrRoleNotHeld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFixedRoleService(models.Role{Name: "manager", Permissions: []string{models.PermissionRolesManage, models.PermissionUsersRead}})
+
+			_, err := s.Create(tt.claims, tt.role)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.want)
+			}
+
+			// Only the existing role may be found after a failed create.
+			_, err = s.FindByName(tt.role.Name)
+			want := tt.want == nil || errors.Is(tt.want, ErrRoleExists)
+			if found := err == nil; found != want {
+				t.Errorf("FindByName() found = %v, want %v", found, want)
+			}
+		})
+	}
+}
+
+func TestRoleUpdate(t *testing.T) {
+	admin := &auth.Claims{UserID: "a1", Roles: []string{models.AdminRole}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name string
+		role string
+		in   models.UpdateRole
+		want error
+	}{
+		{"valid", "support", models.UpdateRole{Permissions: []string{models.PermissionUsersRead}, Inherits: []string{models.UserRole}}, nil},
+		{"admin permissions", models.AdminRole, models.UpdateRole{Permissions: []string{models.PermissionUsersRead}}, ErrAdminRole},
+		{"admin description", models.AdminRole, models.UpdateRole{Description: "Everything", Permissions: []string{"*"}, Inherits: []string{models.UserRole}}, nil},
+		{"inherits itself", "support", models.UpdateRole{Permissions: []string{}, Inherits: []string{"support"}}, ErrRoleCycle},
+		{"inherits an heir", models.UserRole, models.UpdateRole{Permissions: []string{}, Inherits: []string{models.AdminRole}}, ErrRoleCycle},
+		{"unknown role", "ghost", models.UpdateRole{Permissions: []string{}}, ErrUnknownRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFixedRoleService(models.Role{Name: "support", Permissions: []string{models.PermissionUsersImpersonate}})
+
+			_, err := s.Update(admin, tt.role, tt.in)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Update() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   string
+		users  roleHolders
+		groups roleHolders
+		want   error
+	}{
+		{"unused", "support", nil, nil, nil},
+		{"admin", models.AdminRole, nil, nil, ErrBuiltInRole},
+		{"user", models.UserRole, nil, nil, ErrBuiltInRole},
+		{"held by a user", "support", roleHolders{"support": 1}, nil, ErrRoleInUse},
+		{"held by a group", "support", nil, roleHolders{"support": 1}, ErrRoleInUse},
+		{"inherited", "reader", nil, nil, ErrRoleInUse},
+		{"unknown", "ghost", nil, nil, ErrUnknownRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFixedRoleService(
+				models.Role{Name: "support", Permissions: []string{models.PermissionUsersImpersonate}},
+				models.Role{Name: "reader", Permissions: []string{models.PermissionUsersRead}},
+				models.Role{Name: "auditor", Permissions: []string{models.PermissionAuditRead}, Inherits: []string{"reader"}},
+			)
+			s.userRepository = &roleHoldingUserRepository{holders: tt.users}
+			s.groupRepository = &roleHoldingGroupRepository{holders: tt.groups}
+
+			err := s.Delete(tt.role)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Delete() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasPermissionInherits(t *testing.T) {
+	s := newFixedRoleService(
+		models.Role{Name: "reader", Permissions: []string{models.PermissionUsersRead}},
+		models.Role{Name: "auditor", Permissions: []string{"audit:*"}, Inherits: []string{"reader"}},
+	)
+
+	tests := []struct {
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{[]string{"auditor"}, models.PermissionAuditRead, true},
+		{[]string{"auditor"}, models.PermissionUsersRead, true},
+		{[]string{"auditor"}, models.PermissionUsersDelete, false},
+		{[]string{"reader"}, models.PermissionAuditRead, false},
+		{[]string{models.AdminRole}, models.PermissionUsersDelete, true},
+		{[]string{"ghost"}, models.PermissionUsersRead, false},
+	}
+
+	for _, tt := range tests {
+		got, err := s.HasPermission(tt.roles, tt.permission)
+		if err != nil {
+			t.Fatalf("HasPermission() error = %v", err)
+		}
+
+		if got != tt.want {
+			t.Errorf("HasPermission(%v, %s) = %v, want %v", tt.roles, tt.permission, got, tt.want)
+		}
+	}
+}
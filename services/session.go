@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+type SessionService interface {
+	FindAll(userId, currentId string) ([]models.Session, error)
+	Revoke(userId, id string) error
+	RevokeOthers(userId, currentId string) error
+	DeleteExpired() error
+}
+
+func NewSessionService(repository repositories.SessionRepository, tokenService TokenService) SessionService {
+	log.Info().Msg("Creating new session service")
+
+	return &sessionService{
+		repository:   repository,
+		tokenService: tokenService,
+	}
+}
+
+type sessionService struct {
+	repository   repositories.SessionRepository
+	tokenService TokenService
+}
+
+// FindAll lists the active sessions of the user and flags the one the
+// request was made from.
+func (s *sessionService) FindAll(userId, currentId string) ([]models.Session, error) {
+	sessions, err := s.repository.FindActiveByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentId
+	}
+
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(userId, id string) error {
+	return s.tokenService.RevokeSession(userId, id)
+}
+
+// RevokeOthers signs the user out of every device but the current one.
+func (s *sessionService) RevokeOthers(userId, currentId string) error {
+	sessions, err := s.repository.FindActiveByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == currentId {
+			continue
+		}
+
+		err = s.tokenService.RevokeSession(userId, session.ID)
+		if err != nil && !errors.Is(err, ErrUnknownSession) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sessionService) DeleteExpired() error {
+	return s.repository.DeleteExpired()
+}
+
+// deviceName describes the client of a session from its user agent, e.g.
+// "Firefox on Windows".
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := firstMatch(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	})
+
+	os := firstMatch(userAgent, [][2]string{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+
+	// Non browser clients such as "curl/8.0" are named by their product.
+	product, _, _ := strings.Cut(userAgent, "/")
+	return strings.TrimSpace(product)
+}
+
+func firstMatch(s string, patterns [][2]string) string {
+	for _, p := range patterns {
+		if strings.Contains(s, p[0]) {
+			return p[1]
+		}
+	}
+
+	return ""
+}
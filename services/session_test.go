@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+// memorySessionRepository keeps device sessions in memory.
+type memorySessionRepository struct {
+	repositories.SessionRepository
+	sessions []*models.Session
+}
+
+func (r *memorySessionRepository) FindActiveByUserId(userId string) ([]models.Session, error) {
+	var sessions []models.Session
+	for _, s := range r.sessions {
+		if s.UserID == userId && s.RevokedAt == nil {
+			sessions = append(sessions, *s)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) Revoke(userId, id string) (bool, error) {
+	for _, s := range r.sessions {
+		if s.ID == id && s.UserID == userId && s.RevokedAt == nil {
+			now := time.Now()
+			s.RevokedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// familyRefreshTokenRepository records the revoked token families.
+type familyRefreshTokenRepository struct {
+	repositories.RefreshTokenRepository
+	revoked []string
+}
+
+func (r *familyRefreshTokenRepository) RevokeFamily(familyId string) error {
+	r.revoked = append(r.revoked, familyId)
+	return nil
+}
+
+func newTestSessionService() (*sessionService, *familyRefreshTokenRepository, repositories.RevocationRepository) {
+	sessions := &memorySessionRepository{sessions: []*models.Session{
+		{Base: models.Base{ID: "s1"}, UserID: "u1"},
+		{Base: models.Base{ID: "s2"}, UserID: "u1"},
+		{Base: models.Base{ID: "s3"}, UserID: "u1"},
+		{Base: models.Base{ID: "o1"}, UserID: "u2"},
+	}}
+	families := &familyRefreshTokenRepository{}
+	revocations := repositories.NewMemoryRevocationRepository()
+
+	return &sessionService{
+		repository: sessions,
+		tokenService: &tokenService{
+			repository:           families,
+			revocationRepository: revocations,
+			sessionRepository:    sessions,
+			cfg:                  config.Config{AccessTokenLifespan: time.Minute},
+		},
+	}, families, revocations
+}
+
+func TestSessionRevoke(t *testing.T) {
+	tests := []struct {
+		name   string
+		userId string
+		id     string
+		want   error
+	}{
+		{"own session", "u1", "s2", nil},
+		{"session of another user", "u1", "o1", ErrUnknownSession},
+		{"unknown session", "u1", "missing", ErrUnknownSession},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, families, revocations := newTestSessionService()
+
+			err := s.Revoke(tt.userId, tt.id)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Revoke() error = %v, want %v", err, tt.want)
+			}
+
+			revoked, err := revocations.IsRevoked("jti", tt.id, tt.userId, "", time.Now())
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+
+			if revoked != (tt.want == nil) || (len(families.revoked) == 1) != (tt.want == nil) {
+				t.Errorf("Revoke() revoked access = %v, families = %v, want revoked %v", revoked, families.revoked, tt.want == nil)
+			}
+		})
+	}
+}
+
+func TestSessionRevokeOthersKeepsCurrent(t *testing.T) {
+	s, families, _ := newTestSessionService()
+
+	if err := s.RevokeOthers("u1", "s1"); err != nil {
+		t.Fatalf("RevokeOthers() error = %v", err)
+	}
+
+	sessions, err := s.FindAll("u1", "s1")
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+
+	if len(sessions) != 1 || sessions[0].ID != "s1" || !sessions[0].Current {
+		t.Errorf("FindAll() = %+v, want only the current session", sessions)
+	}
+
+	if len(families.revoked) != 2 {
+		t.Errorf("RevokeOthers() revoked families %v, want s2 and s3", families.revoked)
+	}
+
+	other, _ := s.FindAll("u2", "")
+	if len(other) != 1 {
+		t.Errorf("RevokeOthers() touched the sessions of another user")
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"", "Unknown device"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0", "Firefox on Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0", "Edge on macOS"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", "Safari on iOS"},
+		{"curl/8.0.1", "curl"},
+	}
+
+	for _, tt := range tests {
+		if got := deviceName(tt.userAgent); got != tt.want {
+			t.Errorf("deviceName(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
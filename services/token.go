@@ -0,0 +1,592 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidApiKey       = errors.New("invalid api key")
+	ErrUnverifiedEmail     = errors.New("email address is not verified")
+	ErrUnknownSession      = errors.New("unknown session")
+	ErrInvalidAudience     = errors.New("token has an invalid audience")
+)
+
+type TokenService interface {
+	Issue(user models.User, client models.ClientInfo, methods ...string) (models.Token, error)
+	Refresh(refreshToken string, client models.ClientInfo) (models.Token, error)
+	IssueForClient(user models.User, clientId, scope, familyId string) (models.Token, error)
+	RefreshForClient(refreshToken, clientId string) (models.Token, string, error)
+	IssueForMachine(clientId, scope string) (string, error)
+	RevokeFamily(familyId string) error
+	RevokeSession(userId, sessionId string) error
+	IssueImpersonation(actorId string, user models.User) (models.ImpersonationToken, error)
+	ValidateAccessToken(accessToken string) (*auth.Claims, error)
+	ValidateApiKey(key, ip string) (*auth.Claims, error)
+	Introspect(token, hint string) (*auth.Claims, error)
+	Logout(claims *auth.Claims, refreshToken string) error
+	LogoutAll(userId string) error
+	RevokeClient(clientId string) error
+	IssueChallenge(userId, tokenType string, lifespan time.Duration, methods ...string) (string, error)
+	ValidateChallenge(challenge, tokenType string) (*auth.Claims, error)
+	RevokeChallenge(claims *auth.Claims) error
+}
+
+func NewTokenService(repository repositories.RefreshTokenRepository, revocationRepository repositories.RevocationRepository, sessionRepository repositories.SessionRepository, apiKeyRepository repositories.ApiKeyRepository, userRepository repositories.UserRepository, groupService GroupService, keyService KeyService, cfg config.Config) TokenService {
+	log.Info().Msg("Creating new token service")
+
+	return &tokenService{
+		repository:           repository,
+		revocationRepository: revocationRepository,
+		sessionRepository:    sessionRepository,
+		apiKeyRepository:     apiKeyRepository,
+		userRepository:       userRepository,
+		groupService:         groupService,
+		accessKeys:           keyService.KeySet(),
+		refreshKeys:          auth.NewSecretKeySet(cfg.RefreshTokenSecret),
+		opts:                 newTokenOptions(cfg),
+		delegatedOpts:        newDelegatedTokenOptions(cfg),
+		cfg:                  cfg,
+	}
+}
+
+type tokenService struct {
+	repository           repositories.RefreshTokenRepository
+	revocationRepository repositories.RevocationRepository
+	sessionRepository    repositories.SessionRepository
+	apiKeyRepository     repositories.ApiKeyRepository
+	userRepository       repositories.UserRepository
+	groupService         GroupService
+	accessKeys           *auth.KeySet
+	refreshKeys          *auth.KeySet
+	opts                 auth.TokenOptions
+	delegatedOpts        auth.TokenOptions
+	cfg                  config.Config
+}
+
+// grant describes what a token pair is issued for. Refresh tokens keep it
+// so rotated tokens carry the same claims.
+type grant struct {
+	familyId  string
+	sessionId string
+	clientId  string
+	scope     string
+	methods   []string
+	authTime  time.Time
+}
+
+// Issue starts a new session and refresh token family for the user on the
+// client. The methods are the RFC 8176 references of how the user
+// authenticated.
+func (s *tokenService) Issue(user models.User, client models.ClientInfo, methods ...string) (models.Token, error) {
+	now := time.Now()
+	session := models.Session{
+		Base:       models.Base{ID: uuid.New().String()},
+		UserID:     user.ID,
+		DeviceName: deviceName(client.UserAgent),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.cfg.RefreshTokenLifespan),
+	}
+
+	err := s.sessionRepository.Create(&session)
+	if err != nil {
+		return models.Token{}, err
+	}
+
+	return s.issue(user, grant{familyId: session.ID, sessionId: session.ID, methods: methods, authTime: now})
+}
+
+// Refresh rotates the refresh token. Each refresh token can be used once,
+// presenting it again revokes its session.
+func (s *tokenService) Refresh(refreshToken string, client models.ClientInfo) (models.Token, error) {
+	token, _, err := s.refresh(refreshToken, "", client)
+	return token, err
+}
+
+// IssueForClient issues tokens to an OAuth client acting for the user. The
+// access token carries the client ID and granted scope but no roles, and is
+// issued for the OAuth audience so the API only accepts it where a route
+// asks for the scope.
+func (s *tokenService) IssueForClient(user models.User, clientId, scope, familyId string) (models.Token, error) {
+	return s.issue(user, grant{familyId: familyId, clientId: clientId, scope: scope})
+}
+
+// RefreshForClient rotates a refresh token that was issued to the client and
+// returns the scope granted to its family.
+func (s *tokenService) RefreshForClient(refreshToken, clientId string) (models.Token, string, error) {
+	return s.refresh(refreshToken, clientId, models.ClientInfo{})
+}
+
+// IssueForMachine issues an access token to a machine client acting on its
+// own behalf. There is no refresh token, the client asks for a new token.
+func (s *tokenService) IssueForMachine(clientId, scope string) (string, error) {
+	return s.accessKeys.Sign(auth.NewClientClaims(clientId, scope, s.cfg.AccessTokenLifespan, s.opts))
+}
+
+// RevokeFamily revokes the refresh tokens of an OAuth client's family and
+// the access tokens issued with them, which carry the family as their sid.
+func (s *tokenService) RevokeFamily(familyId string) error {
+	err := s.repository.RevokeFamily(familyId)
+	if err != nil {
+		return err
+	}
+
+	return s.revocationRepository.RevokeSession(familyId, time.Now().Add(s.cfg.AccessTokenLifespan))
+}
+
+// RevokeSession revokes the refresh tokens of the session and every access
+// token issued to it.
+func (s *tokenService) RevokeSession(userId, sessionId string) error {
+	ok, err := s.sessionRepository.Revoke(userId, sessionId)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrUnknownSession
+	}
+
+	err = s.repository.RevokeFamily(sessionId)
+	if err != nil {
+		return err
+	}
+
+	return s.revocationRepository.RevokeSession(sessionId, time.Now().Add(s.cfg.AccessTokenLifespan))
+}
+
+// IssueImpersonation issues a short lived access token for the user that
+// names the actor. No refresh token or session is created with it.
+func (s *tokenService) IssueImpersonation(actorId string, user models.User) (models.ImpersonationToken, error) {
+	roles, err := s.groupService.EffectiveRoles(user)
+	if err != nil {
+		return models.ImpersonationToken{}, err
+	}
+
+	claims := auth.NewClaims(user.ID, roles, auth.TokenTypeAccess, s.cfg.ImpersonationLifespan, s.opts)
+	claims.EmailVerified = user.VerifiedAt != nil
+	claims.Actor = &auth.Actor{Subject: actorId}
+
+	accessToken, err := s.accessKeys.Sign(claims)
+	if err != nil {
+		return models.ImpersonationToken{}, err
+	}
+
+	return models.ImpersonationToken{
+		Token:     accessToken,
+		ExpiresAt: claims.ExpiresAt.Time,
+		User:      user,
+	}, nil
+}
+
+func (s *tokenService) refresh(refreshToken, clientId string, client models.ClientInfo) (models.Token, string, error) {
+	var token models.Token
+
+	_, err := auth.Validate(refreshToken, auth.TokenTypeRefresh, s.refreshKeys, s.opts)
+	if err != nil {
+		return token, "", ErrInvalidRefreshToken
+	}
+
+	record, err := s.repository.FindByHash(auth.HashToken(refreshToken))
+	if err != nil {
+		return token, "", ErrInvalidRefreshToken
+	}
+
+	if record.ClientID != clientId || record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+		return token, "", ErrInvalidRefreshToken
+	}
+
+	if record.UsedAt != nil {
+		return token, "", s.revokeReused(record)
+	}
+
+	ok, err := s.repository.MarkUsed(record.ID)
+	if err != nil {
+		return token, "", err
+	}
+
+	if !ok {
+		return token, "", s.revokeReused(record)
+	}
+
+	user, err := s.userRepository.FindById(record.UserID)
+	if err != nil {
+		return token, "", err
+	}
+
+	// Families issued to the user's own devices are sessions, those of
+	// OAuth clients are not.
+	var sessionId string
+	if record.ClientID == "" {
+		sessionId = record.FamilyID
+
+		err = s.sessionRepository.Touch(sessionId, client, time.Now().Add(s.cfg.RefreshTokenLifespan))
+		if err != nil {
+			return token, "", err
+		}
+	}
+
+	token, err = s.issue(user, grant{
+		familyId:  record.FamilyID,
+		sessionId: sessionId,
+		clientId:  record.ClientID,
+		scope:     record.Scope,
+		methods:   strings.Fields(record.AuthMethods),
+		authTime:  record.AuthTime,
+	})
+
+	return token, record.Scope, err
+}
+
+// ValidateAccessToken checks the access token and that it has not been
+// revoked by a logout. Tokens of OAuth clients acting for a user must be
+// issued for the OAuth audience and all others for the API.
+func (s *tokenService) ValidateAccessToken(accessToken string) (*auth.Claims, error) {
+	claims, err := auth.Validate(accessToken, auth.TokenTypeAccess, s.accessKeys, s.opts)
+	if errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		claims, err = auth.Validate(accessToken, auth.TokenTypeAccess, s.accessKeys, s.delegatedOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Delegated() != hasAudience(claims, s.delegatedOpts.Audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	revoked, err := s.revocationRepository.IsRevoked(claims.ID, claims.SessionID, claims.UserID, claims.ClientID, claims.IssuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	if s.cfg.RequireVerifiedEmail && !claims.IsClient() && !claims.EmailVerified {
+		return nil, ErrUnverifiedEmail
+	}
+
+	return claims, nil
+}
+
+// Introspect returns the claims of an active access or refresh token. The
+// hint only decides which kind is tried first.
+func (s *tokenService) Introspect(token, hint string) (*auth.Claims, error) {
+	if hint == "refresh_token" {
+		claims, err := s.introspectRefresh(token)
+		if err == nil {
+			return claims, nil
+		}
+
+		return s.ValidateAccessToken(token)
+	}
+
+	claims, err := s.ValidateAccessToken(token)
+	if err == nil {
+		return claims, nil
+	}
+
+	return s.introspectRefresh(token)
+}
+
+// introspectRefresh checks a refresh token against its stored record, the
+// record holds the grant the token would be rotated with.
+func (s *tokenService) introspectRefresh(refreshToken string) (*auth.Claims, error) {
+	claims, err := auth.Validate(refreshToken, auth.TokenTypeRefresh, s.refreshKeys, s.opts)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	record, err := s.repository.FindByHash(auth.HashToken(refreshToken))
+	if err != nil || record.UserID != claims.UserID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if record.RevokedAt != nil || record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	claims.ClientID = record.ClientID
+	claims.Scope = record.Scope
+	claims.AuthMethods = strings.Fields(record.AuthMethods)
+
+	return claims, nil
+}
+
+// ValidateApiKey looks up an API key and returns claims for its owner with
+// all of the owner's roles. RoleService.Can limits what keys without the
+// admin scope may do with them.
+func (s *tokenService) ValidateApiKey(key, ip string) (*auth.Claims, error) {
+	apiKey, err := s.apiKeyRepository.FindByHash(auth.HashToken(key))
+	if err != nil {
+		return nil, ErrInvalidApiKey
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt)) {
+		return nil, ErrInvalidApiKey
+	}
+
+	user, err := s.userRepository.FindById(apiKey.UserID)
+	if err != nil {
+		return nil, ErrInvalidApiKey
+	}
+
+	roles, err := s.groupService.EffectiveRoles(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage is recorded at most once a minute per address to spare the
+	// database a write on every request.
+	if apiKey.LastUsedAt == nil || apiKey.LastUsedIP != ip || now.Sub(*apiKey.LastUsedAt) > time.Minute {
+		err = s.apiKeyRepository.Touch(apiKey.ID, ip)
+		if err != nil {
+			log.Error().Err(err).Str("api_key_id", apiKey.ID).Msg("Failed to record api key usage")
+		}
+	}
+
+	claims := &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       apiKey.ID,
+			Subject:  user.ID,
+			IssuedAt: jwt.NewNumericDate(apiKey.CreatedAt),
+		},
+		Authorized: true,
+		UserID:     user.ID,
+		Roles:      roles,
+		Type:       auth.TokenTypeApiKey,
+		Scope:      strings.Join(apiKey.Scopes, " "),
+
+		EmailVerified: user.VerifiedAt != nil,
+	}
+
+	if s.cfg.RequireVerifiedEmail && !claims.EmailVerified {
+		return nil, ErrUnverifiedEmail
+	}
+
+	if apiKey.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*apiKey.ExpiresAt)
+	}
+
+	return claims, nil
+}
+
+// Logout ends the session of the access token. Tokens without a session
+// revoke the access token and, when given, the family of the refresh token
+// issued alongside it.
+func (s *tokenService) Logout(claims *auth.Claims, refreshToken string) error {
+	if claims.SessionID != "" {
+		err := s.RevokeSession(claims.UserID, claims.SessionID)
+		if !errors.Is(err, ErrUnknownSession) {
+			return err
+		}
+	}
+
+	err := s.revocationRepository.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	record, err := s.repository.FindByHash(auth.HashToken(refreshToken))
+	if err != nil || record.UserID != claims.UserID {
+		return ErrInvalidRefreshToken
+	}
+
+	return s.repository.RevokeFamily(record.FamilyID)
+}
+
+// LogoutAll revokes every access and refresh token issued to the user so far.
+func (s *tokenService) LogoutAll(userId string) error {
+	err := s.revocationRepository.RevokeUser(userId, time.Now().Add(s.cfg.AccessTokenLifespan))
+	if err != nil {
+		return err
+	}
+
+	err = s.sessionRepository.RevokeAllByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.RevokeAllByUserId(userId)
+}
+
+// RevokeClient revokes every access and refresh token issued to the OAuth
+// client so far, both those it holds for users and its own.
+func (s *tokenService) RevokeClient(clientId string) error {
+	err := s.revocationRepository.RevokeClient(clientId, time.Now().Add(s.cfg.AccessTokenLifespan))
+	if err != nil {
+		return err
+	}
+
+	return s.repository.RevokeAllByClientId(clientId)
+}
+
+// IssueChallenge signs a short lived token used between the steps of a
+// multi-step flow. Challenges are signed with the refresh token key since
+// they are never verified outside of this service. The methods record how the
+// user authenticated before the challenge.
+func (s *tokenService) IssueChallenge(userId, tokenType string, lifespan time.Duration, methods ...string) (string, error) {
+	claims := auth.NewClaims(userId, nil, tokenType, lifespan, s.opts)
+	claims.AuthMethods = methods
+
+	return s.refreshKeys.Sign(claims)
+}
+
+func (s *tokenService) ValidateChallenge(challenge, tokenType string) (*auth.Claims, error) {
+	claims, err := auth.Validate(challenge, tokenType, s.refreshKeys, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.revocationRepository.IsRevoked(claims.ID, "", "", "", claims.IssuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+func (s *tokenService) RevokeChallenge(claims *auth.Claims) error {
+	return s.revocationRepository.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+}
+
+func (s *tokenService) issue(user models.User, g grant) (models.Token, error) {
+	var token models.Token
+
+	// OAuth clients act within their granted scope, the roles of the user
+	// are only given to the user's own tokens.
+	var roles []string
+	opts := s.delegatedOpts
+	if g.clientId == "" {
+		var err error
+		roles, err = s.groupService.EffectiveRoles(user)
+		if err != nil {
+			return token, err
+		}
+
+		opts = s.opts
+	}
+
+	claims := auth.NewClaims(user.ID, roles, auth.TokenTypeAccess, s.cfg.AccessTokenLifespan, opts)
+	claims.ClientID = g.clientId
+	claims.Scope = g.scope
+	claims.AuthMethods = g.methods
+	claims.EmailVerified = user.VerifiedAt != nil
+	claims.SessionID = g.sessionId
+	if g.clientId != "" {
+		// OAuth clients have no session, the family stands in for it so
+		// the access tokens can be revoked along with it.
+		claims.SessionID = g.familyId
+	}
+	if !g.authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(g.authTime)
+	}
+
+	accessToken, err := s.accessKeys.Sign(claims)
+	if err != nil {
+		return token, err
+	}
+
+	refreshClaims := auth.NewClaims(user.ID, nil, auth.TokenTypeRefresh, s.cfg.RefreshTokenLifespan, s.opts)
+	refreshClaims.SessionID = g.sessionId
+
+	refreshToken, err := s.refreshKeys.Sign(refreshClaims)
+	if err != nil {
+		return token, err
+	}
+
+	record := models.RefreshTokenRecord{
+		UserID:      user.ID,
+		FamilyID:    g.familyId,
+		ClientID:    g.clientId,
+		Scope:       g.scope,
+		AuthMethods: strings.Join(g.methods, " "),
+		AuthTime:    g.authTime,
+		TokenHash:   auth.HashToken(refreshToken),
+		ExpiresAt:   time.Now().Add(s.cfg.RefreshTokenLifespan),
+	}
+
+	err = s.repository.Create(&record)
+	if err != nil {
+		return token, err
+	}
+
+	token.Token = accessToken
+	token.RefreshToken = refreshToken
+	token.User = user
+
+	return token, nil
+}
+
+func (s *tokenService) revokeReused(record models.RefreshTokenRecord) error {
+	log.Warn().Str("user_id", record.UserID).Str("family_id", record.FamilyID).Msg("Refresh token reuse detected, revoking family")
+
+	// The access tokens of a session are revoked with it, they could have
+	// been obtained with the stolen refresh token.
+	if record.ClientID == "" {
+		err := s.RevokeSession(record.UserID, record.FamilyID)
+		if err == nil {
+			return ErrRefreshTokenReused
+		}
+		if !errors.Is(err, ErrUnknownSession) {
+			return err
+		}
+	}
+
+	err := s.RevokeFamily(record.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func newTokenOptions(cfg config.Config) auth.TokenOptions {
+	return auth.TokenOptions{
+		Issuer:   cfg.JwtIssuer,
+		Audience: cfg.JwtAudience,
+		Leeway:   cfg.JwtLeeway,
+	}
+}
+
+// newDelegatedTokenOptions are the options of access tokens issued to OAuth
+// clients acting for a user.
+func newDelegatedTokenOptions(cfg config.Config) auth.TokenOptions {
+	opts := newTokenOptions(cfg)
+	opts.Audience = cfg.OAuthAudience
+
+	return opts
+}
+
+func hasAudience(claims *auth.Claims, audience string) bool {
+	for _, aud := range claims.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
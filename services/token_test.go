@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/google/uuid"
+)
+
+// memoryRefreshTokenRepository keeps refresh token records in memory.
+type memoryRefreshTokenRepository struct {
+	repositories.RefreshTokenRepository
+	records []*models.RefreshTokenRecord
+}
+
+func (r *memoryRefreshTokenRepository) Create(t *models.RefreshTokenRecord) error {
+	t.ID = uuid.New().String()
+	r.records = append(r.records, t)
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) FindByHash(hash string) (models.RefreshTokenRecord, error) {
+	for _, t := range r.records {
+		if t.TokenHash == hash {
+			return *t, nil
+		}
+	}
+
+	return models.RefreshTokenRecord{}, errors.New("record not found")
+}
+
+func (r *memoryRefreshTokenRepository) MarkUsed(id string) (bool, error) {
+	for _, t := range r.records {
+		if t.ID == id && t.UsedAt == nil {
+			now := time.Now()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeFamily(familyId string) error {
+	now := time.Now()
+	for _, t := range r.records {
+		if t.FamilyID == familyId {
+			t.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func newTestTokenService(t *testing.T, users ...models.User) *tokenService {
+	key, err := auth.GenerateKey(auth.AlgES256)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	accessKeys, err := auth.NewKeySet(key, nil)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	cfg := config.Config{
+		JwtIssuer:            "https://auth.example.com",
+		JwtAudience:          "api",
+		OAuthAudience:        "oauth",
+		AccessTokenLifespan:  time.Minute,
+		RefreshTokenLifespan: time.Hour,
+	}
+
+	return &tokenService{
+		repository:           &memoryRefreshTokenRepository{},
+		revocationRepository: repositories.NewMemoryRevocationRepository(),
+		userRepository:       &fixedUserRepository{users: users},
+		accessKeys:           accessKeys,
+		refreshKeys:          auth.NewSecretKeySet("refresh-secret"),
+		opts:                 newTokenOptions(cfg),
+		delegatedOpts:        newDelegatedTokenOptions(cfg),
+		cfg:                  cfg,
+	}
+}
+
+func TestTokenIntrospect(t *testing.T) {
+	user := models.User{Base: models.Base{ID: "u1"}}
+
+	tests := []struct {
+		name   string
+		token  func(s *tokenService, token models.Token) string
+		hint   string
+		active bool
+	}{
+		{"access token", func(s *tokenService, token models.Token) string { return token.Token }, "", true},
+		{"refresh token", func(s *tokenService, token models.Token) string { return token.RefreshToken }, "refresh_token", true},
+		{"refresh token without hint", func(s *tokenService, token models.Token) string { return token.RefreshToken }, "", true},
+		{"garbage", func(s *tokenService, token models.Token) string { return "garbage" }, "", false},
+		{"rotated refresh token", func(s *tokenService, token models.Token) string {
+			s.RefreshForClient(token.RefreshToken, "c1")
+			return token.RefreshToken
+		}, "refresh_token", false},
+		{"access token of a revoked family", func(s *tokenService, token models.Token) string {
+			s.RevokeFamily("f1")
+			return token.Token
+		}, "", false},
+		{"refresh token of a revoked family", func(s *tokenService, token models.Token) string {
+			s.RevokeFamily("f1")
+			return token.RefreshToken
+		}, "refresh_token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestTokenService(t, user)
+
+			token, err := s.IssueForClient(user, "c1", models.ScopeProfile, "f1")
+			if err != nil {
+				t.Fatalf("IssueForClient() error = %v", err)
+			}
+
+			claims, err := s.Introspect(tt.token(s, token), tt.hint)
+			if active := err == nil; active != tt.active {
+				t.Fatalf("Introspect() error = %v, want active %v", err, tt.active)
+			}
+
+			if tt.active && (claims.ClientID != "c1" || claims.Scope != models.ScopeProfile || claims.UserID != "u1") {
+				t.Errorf("Introspect() claims = %+v, want c1 acting for u1 with %q", claims, models.ScopeProfile)
+			}
+		})
+	}
+}
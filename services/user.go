@@ -2,51 +2,99 @@ package services
 
 import (
 	"errors"
+	"time"
 
 	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/auth/password"
+	"github.com/Marcel-MD/clean-api/auth/policy"
 	"github.com/Marcel-MD/clean-api/config"
 	"github.com/Marcel-MD/clean-api/data/repositories"
 	"github.com/Marcel-MD/clean-api/models"
 	"github.com/rs/zerolog/log"
+)
 
-	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidExpiry      = errors.New("role grants must expire in the future")
 )
 
 type UserService interface {
-	FindAll(query models.PaginationQuery) ([]models.User, error)
-	FindById(id string) (models.User, error)
-	Register(user models.RegisterUser) (models.Token, error)
-	Login(user models.LoginUser) (models.Token, error)
-	RefreshToken(refreshToken string) (models.Token, error)
-	Delete(id string) error
-	AssignRole(id, role string) error
-	RemoveRole(id, role string) error
+	FindAll(claims *auth.Claims, query models.PaginationQuery) ([]models.User, error)
+	FindById(claims *auth.Claims, id string) (models.User, error)
+	Register(user models.RegisterUser, client models.ClientInfo) (models.Token, error)
+	NewUser(user models.RegisterUser) (models.User, []string, error)
+	Login(user models.LoginUser, client models.ClientInfo) (models.Token, error)
+	RefreshToken(refreshToken string, client models.ClientInfo) (models.Token, error)
+	Logout(claims *auth.Claims, refreshToken string) error
+	LogoutAll(id string) error
+	Verify(token string) error
+	ResendVerification(email string) error
+	Delete(claims *auth.Claims, id string) error
+	AssignRole(claims *auth.Claims, id, role string, assign models.AssignRole) error
+	RemoveRole(claims *auth.Claims, id, role string) error
+	RemoveExpiredRoles() error
 }
 
-func NewUserService(repository repositories.UserRepository, cfg config.Config) UserService {
+func NewUserService(repository repositories.UserRepository, roleGrantRepository repositories.RoleGrantRepository, roleService RoleService, tokenService TokenService, mfaService MfaService, verificationService VerificationService, lockoutService LockoutService, authorizationService AuthorizationService, passwordPolicy *password.Policy, passwordHasher password.Hasher, cfg config.Config) (UserService, error) {
 	log.Info().Msg("Creating new user service")
 
-	return &userService{
-		repository: repository,
-		cfg:        cfg,
+	// dummyHash is verified against when the email is unknown so a failed
+	// login takes the same time whether or not the account exists.
+	dummyHash, err := passwordHasher.Hash("dummy password")
+	if err != nil {
+		return nil, err
 	}
+
+	return &userService{
+		repository:           repository,
+		roleGrantRepository:  roleGrantRepository,
+		roleService:          roleService,
+		tokenService:         tokenService,
+		mfaService:           mfaService,
+		verificationService:  verificationService,
+		lockoutService:       lockoutService,
+		authorizationService: authorizationService,
+		passwordPolicy:       passwordPolicy,
+		passwordHasher:       passwordHasher,
+		dummyHash:            dummyHash,
+		cfg:                  cfg,
+	}, nil
 }
 
 type userService struct {
-	repository repositories.UserRepository
-	cfg        config.Config
+	repository           repositories.UserRepository
+	roleGrantRepository  repositories.RoleGrantRepository
+	roleService          RoleService
+	tokenService         TokenService
+	mfaService           MfaService
+	verificationService  VerificationService
+	lockoutService       LockoutService
+	authorizationService AuthorizationService
+	passwordPolicy       *password.Policy
+	passwordHasher       password.Hasher
+	dummyHash            string
+	cfg                  config.Config
 }
 
-func (s *userService) FindAll(query models.PaginationQuery) ([]models.User, error) {
+func (s *userService) FindAll(claims *auth.Claims, query models.PaginationQuery) ([]models.User, error) {
+	err := s.authorizationService.Authorize(claims, models.ActionUsersList, policy.Resource{Type: models.ResourceUser})
+	if err != nil {
+		return nil, err
+	}
+
 	return s.repository.FindAll(query)
 }
 
-func (s *userService) FindById(id string) (models.User, error) {
+func (s *userService) FindById(claims *auth.Claims, id string) (models.User, error) {
+	err := s.authorizationService.Authorize(claims, models.ActionUsersRead, userResource(id))
+	if err != nil {
+		return models.User{}, err
+	}
+
 	return s.repository.FindById(id)
 }
 
-func (s *userService) Register(user models.RegisterUser) (models.Token, error) {
+func (s *userService) Register(user models.RegisterUser, client models.ClientInfo) (models.Token, error) {
 	var token models.Token
 
 	_, err := s.repository.FindByEmail(user.Email)
@@ -54,121 +102,209 @@ func (s *userService) Register(user models.RegisterUser) (models.Token, error) {
 		return token, errors.New("user already exists")
 	}
 
-	if user.Password == "" {
-		user.Password = uuid.New().String()
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	newUser, methods, err := s.NewUser(user)
 	if err != nil {
 		return token, err
 	}
 
-	newUser := models.User{
-		Email:    user.Email,
-		Name:     user.Name,
-		Password: string(hashedPassword),
-		Roles:    []string{models.UserRole},
-	}
-
 	err = s.repository.Create(&newUser)
 	if err != nil {
 		return token, err
 	}
 
-	accessToken, refreshToken, err := auth.GenerateTokenPair(newUser.ID, newUser.Roles, s.cfg.AccessTokenLifespan, s.cfg.AccessTokenSecret, s.cfg.RefreshTokenSecret)
+	err = s.verificationService.Send(newUser)
 	if err != nil {
-		return token, err
+		log.Error().Err(err).Str("user_id", newUser.ID).Msg("Failed to send verification email")
+	}
+
+	return s.tokenService.Issue(newUser, client, methods...)
+}
+
+// NewUser validates the registration and returns the account to create,
+// without saving it, along with the methods it signs in with.
+func (s *userService) NewUser(user models.RegisterUser) (models.User, []string, error) {
+	newUser := models.User{
+		Email: user.Email,
+		Name:  user.Name,
+		Roles: []string{models.UserRole},
 	}
 
-	token.Token = accessToken
-	token.RefreshToken = refreshToken
-	token.User = newUser
+	// Without a password the account is passwordless and signs in with
+	// login links, passkeys or an external provider.
+	var methods []string
+	if user.Password != "" {
+		err := s.passwordPolicy.Validate(user.Password, user.Email, user.Name)
+		if err != nil {
+			return newUser, nil, err
+		}
 
-	return token, nil
+		newUser.Password, err = s.passwordHasher.Hash(user.Password)
+		if err != nil {
+			return newUser, nil, err
+		}
+
+		methods = append(methods, auth.AuthMethodPassword)
+	}
+
+	return newUser, methods, nil
 }
 
-func (s *userService) Login(user models.LoginUser) (models.Token, error) {
+// Login checks the password. Every attempt is counted towards the lockout of
+// the account and the client address until it succeeds, and every failure
+// returns the same error.
+func (s *userService) Login(user models.LoginUser, client models.ClientInfo) (models.Token, error) {
 	var token models.Token
 
-	existingUser, err := s.repository.FindByEmail(user.Email)
+	err := s.lockoutService.Attempt(user.Email, client.IP)
 	if err != nil {
 		return token, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(user.Password))
+	existingUser, err := s.repository.FindByEmail(user.Email)
 	if err != nil {
-		return token, err
+		_, _ = s.passwordHasher.Verify(user.Password, s.dummyHash)
+		return token, ErrInvalidCredentials
 	}
 
-	accessToken, refreshToken, err := auth.GenerateTokenPair(existingUser.ID, existingUser.Roles, s.cfg.AccessTokenLifespan, s.cfg.AccessTokenSecret, s.cfg.RefreshTokenSecret)
-	if err != nil {
-		return token, err
+	ok, err := s.passwordHasher.Verify(user.Password, existingUser.Password)
+	if err != nil || !ok {
+		return token, ErrInvalidCredentials
 	}
 
-	token.Token = accessToken
-	token.RefreshToken = refreshToken
-	token.User = existingUser
-
-	return token, nil
-}
-
-func (s *userService) RefreshToken(refreshToken string) (models.Token, error) {
-	var token models.Token
+	s.rehash(&existingUser, user.Password)
 
-	t, err := auth.Validate(refreshToken, s.cfg.RefreshTokenSecret)
+	err = s.lockoutService.Succeed(user.Email, client.IP)
 	if err != nil {
 		return token, err
 	}
 
-	uid, err := auth.ExtractId(t)
+	err = s.mfaService.Challenge(existingUser, auth.AuthMethodPassword)
 	if err != nil {
 		return token, err
 	}
 
-	user, err := s.repository.FindById(uid)
+	return s.tokenService.Issue(existingUser, client, auth.AuthMethodPassword)
+}
+
+// rehash upgrades a hash made with an outdated algorithm or cost while the
+// plain password is at hand. Failing to do so does not fail the login.
+func (s *userService) rehash(user *models.User, plain string) {
+	if !s.passwordHasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(plain)
 	if err != nil {
-		return token, err
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to rehash password")
+		return
 	}
 
-	accessToken, refreshToken, err := auth.GenerateTokenPair(user.ID, user.Roles, s.cfg.AccessTokenLifespan, s.cfg.AccessTokenSecret, s.cfg.RefreshTokenSecret)
+	user.Password = hashedPassword
+	err = s.repository.Update(user)
 	if err != nil {
-		return token, err
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to store rehashed password")
+		return
 	}
 
-	token.Token = accessToken
-	token.RefreshToken = refreshToken
-	token.User = user
+	log.Info().Str("user_id", user.ID).Msg("Upgraded password hash")
+}
+
+func (s *userService) RefreshToken(refreshToken string, client models.ClientInfo) (models.Token, error) {
+	return s.tokenService.Refresh(refreshToken, client)
+}
+
+func (s *userService) Logout(claims *auth.Claims, refreshToken string) error {
+	return s.tokenService.Logout(claims, refreshToken)
+}
+
+func (s *userService) LogoutAll(id string) error {
+	return s.tokenService.LogoutAll(id)
+}
+
+func (s *userService) Verify(token string) error {
+	return s.verificationService.Verify(token)
+}
 
-	return token, nil
+func (s *userService) ResendVerification(email string) error {
+	return s.verificationService.Resend(email)
 }
 
-func (s *userService) Delete(id string) error {
+// Delete removes the account and signs it out everywhere. Users may delete
+// their own account, others need the permission.
+func (s *userService) Delete(claims *auth.Claims, id string) error {
+	err := s.authorizationService.Authorize(claims, models.ActionUsersDelete, userResource(id))
+	if err != nil {
+		return err
+	}
+
 	user, err := s.repository.FindById(id)
 	if err != nil {
 		return err
 	}
 
+	err = s.tokenService.LogoutAll(user.ID)
+	if err != nil {
+		return err
+	}
+
 	return s.repository.Delete(&user)
 }
 
-func (s *userService) AssignRole(id, role string) error {
+// AssignRole gives the user a known role, until the expiry when one is
+// set. Assigning a held role again replaces its expiry. Only roles whose
+// permissions the caller holds can be assigned.
+func (s *userService) AssignRole(claims *auth.Claims, id, role string, assign models.AssignRole) error {
+	_, err := s.roleService.FindByName(role)
+	if err != nil {
+		return err
+	}
+
+	err = s.roleService.CanGrant(claims, []string{role})
+	if err != nil {
+		return err
+	}
+
+	if assign.ExpiresAt != nil && !assign.ExpiresAt.After(time.Now()) {
+		return ErrInvalidExpiry
+	}
+
 	user, err := s.repository.FindById(id)
 	if err != nil {
 		return err
 	}
 
-	for _, r := range user.Roles {
-		if r == role {
-			return nil
+	if !user.HasRole(role) {
+		user.Roles = append(user.Roles, role)
+
+		err = s.repository.Update(&user)
+		if err != nil {
+			return err
 		}
 	}
 
-	user.Roles = append(user.Roles, role)
+	if assign.ExpiresAt == nil {
+		return s.roleGrantRepository.Delete(user.ID, role)
+	}
+
+	return s.roleGrantRepository.Save(&models.RoleGrant{
+		UserID:    user.ID,
+		Role:      role,
+		ExpiresAt: *assign.ExpiresAt,
+	})
+}
+
+// RemoveRole takes a role away from the user. Like assigning, it takes
+// holding every permission of the role.
+func (s *userService) RemoveRole(claims *auth.Claims, id, role string) error {
+	err := s.roleService.CanGrant(claims, []string{role})
+	if err != nil {
+		return err
+	}
 
-	return s.repository.Update(&user)
+	return s.removeRole(id, role)
 }
 
-func (s *userService) RemoveRole(id, role string) error {
+func (s *userService) removeRole(id, role string) error {
 	user, err := s.repository.FindById(id)
 	if err != nil {
 		return err
@@ -181,5 +317,37 @@ func (s *userService) RemoveRole(id, role string) error {
 		}
 	}
 
-	return s.repository.Update(&user)
+	err = s.repository.Update(&user)
+	if err != nil {
+		return err
+	}
+
+	return s.roleGrantRepository.Delete(user.ID, role)
+}
+
+// RemoveExpiredRoles takes expired role grants away. Like any role change
+// it applies to tokens issued afterwards. A grant that fails to be removed
+// does not hold up the others, it is retried on the next sweep.
+func (s *userService) RemoveExpiredRoles() error {
+	grants, err := s.roleGrantRepository.FindExpired()
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range grants {
+		err = s.removeRole(grant.UserID, grant.Role)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", grant.UserID).Str("role", grant.Role).Msg("Failed to remove expired role")
+			continue
+		}
+
+		log.Info().Str("user_id", grant.UserID).Str("role", grant.Role).Msg("Removed expired role")
+	}
+
+	return nil
+}
+
+// userResource is a user account, owned by the user itself.
+func userResource(id string) policy.Resource {
+	return policy.Resource{Type: models.ResourceUser, ID: id, OwnerID: id}
 }
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/models"
+)
+
+func TestRoleChangesNeedHeldRoles(t *testing.T) {
+	s := &userService{roleService: newFixedRoleService(
+		models.Role{Name: "manager", Permissions: []string{models.PermissionUsersRoles}},
+	)}
+
+	manager := &auth.Claims{UserID: "m1", Roles: []string{"manager"}, Type: auth.TokenTypeAccess}
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{"assign admin", func() error {
+			return s.AssignRole(manager, "u1", models.AdminRole, models.AssignRole{})
+		}},
+		{"remove admin", func() error {
+			return s.RemoveRole(manager, "a1", models.AdminRole)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); !errors.Is(err, ErrRoleNotHeld) {
+				t.Errorf("error = %v, want ErrRoleNotHeld", err)
+			}
+		})
+	}
+}
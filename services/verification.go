@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Marcel-MD/clean-api/auth"
+	"github.com/Marcel-MD/clean-api/config"
+	"github.com/Marcel-MD/clean-api/data/repositories"
+	"github.com/Marcel-MD/clean-api/mailer"
+	"github.com/Marcel-MD/clean-api/models"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+	ErrVerificationThrottled    = errors.New("verification email was sent recently, try again later")
+)
+
+type VerificationService interface {
+	Send(user models.User) error
+	Verify(token string) error
+	Resend(email string) error
+}
+
+func NewVerificationService(userRepository repositories.UserRepository, tokenService TokenService, mailer mailer.Mailer, cfg config.Config) VerificationService {
+	log.Info().Msg("Creating new verification service")
+
+	return &verificationService{
+		userRepository: userRepository,
+		tokenService:   tokenService,
+		mailer:         mailer,
+		cfg:            cfg,
+	}
+}
+
+type verificationService struct {
+	userRepository repositories.UserRepository
+	tokenService   TokenService
+	mailer         mailer.Mailer
+	cfg            config.Config
+}
+
+// Send mails a signed link to the user, unless one was sent within the
+// resend interval.
+func (s *verificationService) Send(user models.User) error {
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if user.VerificationSentAt != nil && now.Sub(*user.VerificationSentAt) < s.cfg.VerificationResendInterval {
+		return ErrVerificationThrottled
+	}
+
+	token, err := s.tokenService.IssueChallenge(user.ID, auth.TokenTypeVerify, s.cfg.VerificationTokenLifespan)
+	if err != nil {
+		return err
+	}
+
+	user.VerificationSentAt = &now
+	err = s.userRepository.Update(&user)
+	if err != nil {
+		return err
+	}
+
+	link := s.cfg.PublicUrl + "/users/verify?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, link, s.cfg.VerificationTokenLifespan),
+	})
+}
+
+func (s *verificationService) Verify(token string) error {
+	claims, err := s.tokenService.ValidateChallenge(token, auth.TokenTypeVerify)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepository.FindById(claims.UserID)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.VerifiedAt = &now
+
+	return s.userRepository.Update(&user)
+}
+
+// Resend does not reveal whether the email belongs to an account, so
+// throttled and failed sends are only logged.
+func (s *verificationService) Resend(email string) error {
+	user, err := s.userRepository.FindByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	err = s.Send(user)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID).Msg("Failed to resend verification email")
+	}
+
+	return nil
+}